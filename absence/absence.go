@@ -0,0 +1,101 @@
+package absence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// RuleAbsence is the rule name of absence violations
+const RuleAbsence = "absence"
+
+// Span returns the time covered by the absence in loc, from midnight of the
+// first day to midnight after the last day
+func Span(a models.Absence, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", a.StartDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.ParseInLocation("2006-01-02", a.EndDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// Check reports approved absences overlapping the shift. Full days block the
+// shift, half days only produce a warning since the other half can be worked.
+func Check(absences []models.Absence, shift models.Shift, loc *time.Location) []models.Violation {
+	if shift.UserID == 0 {
+		return nil
+	}
+	var violations []models.Violation
+	for _, a := range absences {
+		if a.UserID != shift.UserID || a.Status != models.AbsenceApproved {
+			continue
+		}
+		start, end, err := Span(a, loc)
+		if err != nil || !start.Before(shift.EndTime) || !end.After(shift.StartTime) {
+			continue
+		}
+		severity, days := models.SeverityViolation, a.StartDate
+		if a.HalfDay {
+			severity, days = models.SeverityWarning, a.StartDate+" (half day)"
+		} else if a.EndDate != a.StartDate {
+			days = a.StartDate + " - " + a.EndDate
+		}
+		violations = append(violations, models.Violation{
+			Rule:     RuleAbsence,
+			Severity: severity,
+			UserID:   shift.UserID,
+			ShiftIDs: []uint{shift.ID},
+			Message:  fmt.Sprintf("User is absent (%s) on %s", a.Type, days),
+		})
+	}
+	return violations
+}
+
+// Days counts the working days of the absence within [from, to), half days as
+// 0.5. Weekends and days for which skip returns true are not counted.
+func Days(a models.Absence, from, to time.Time, loc *time.Location, skip func(time.Time) bool) float64 {
+	start, end, err := Span(a, loc)
+	if err != nil {
+		return 0
+	}
+	if from.After(start) {
+		start = from
+	}
+	if to.Before(end) {
+		end = to
+	}
+
+	var days float64
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		if skip != nil && skip(day) {
+			continue
+		}
+		if a.HalfDay {
+			days += 0.5
+		} else {
+			days++
+		}
+	}
+	return days
+}
+
+// Constraint keeps the schedule generator from planning absent users
+type Constraint struct {
+	Entries  map[uint][]models.Absence
+	Location *time.Location
+}
+
+func (c *Constraint) Name() string { return RuleAbsence }
+
+func (c *Constraint) Allows(userID uint, shift models.Shift) bool {
+	shift.UserID = userID
+	return len(Check(c.Entries[userID], shift, c.Location)) == 0
+}
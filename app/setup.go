@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/ptmmeiningen/schichtplaner/auth"
 	"github.com/ptmmeiningen/schichtplaner/config"
 	"github.com/ptmmeiningen/schichtplaner/database"
 	"github.com/ptmmeiningen/schichtplaner/router"
@@ -31,6 +32,18 @@ func SetupAndRunApp() error {
 		return err
 	}
 
+	// hash passwords stored in plain text by older versions
+	err = auth.HashPlainPasswords(database.GetDB())
+	if err != nil {
+		return err
+	}
+
+	// create the initial administrator
+	err = auth.EnsureAdmin(database.GetDB())
+	if err != nil {
+		return err
+	}
+
 	// defer closing database
 	defer database.CloseDB()
 
@@ -47,7 +60,7 @@ func SetupAndRunApp() error {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*", // Erlaubt alle Ursprünge
 		AllowMethods: "GET,POST,PUT,DELETE",
-		AllowHeaders: "Origin,Content-Type,Accept",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
 	}))
 
 	// setup routes
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// EnsureAdmin creates an administrator from ADMIN_EMAIL and ADMIN_PASSWORD if
+// there are no users yet, so a fresh installation can be logged into
+func EnsureAdmin(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	email, password := os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		log.Println("No users exist yet, set ADMIN_EMAIL and ADMIN_PASSWORD to create an administrator")
+		return nil
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return errors.New("ADMIN_PASSWORD: " + err.Error())
+	}
+	return tx.Create(&models.User{
+		FirstName: "Admin",
+		LastName:  "Admin",
+		Email:     email,
+		Password:  hash,
+		Color:     "#000000",
+		IsAdmin:   true,
+	}).Error
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// MinPasswordLength is the minimum number of characters of a new password
+const MinPasswordLength = 8
+
+// ErrPasswordTooShort is returned for new passwords below MinPasswordLength
+var ErrPasswordTooShort = errors.New("Password must be at least 8 characters long")
+
+// HashPassword hashes a new password with bcrypt using the configured cost
+func HashPassword(password string) (string, error) {
+	if len([]rune(password)) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword compares the password with the stored hash. If it matches but
+// the hash uses another cost than configured, rehash holds a fresh hash to
+// store instead.
+func VerifyPassword(stored, password string) (ok bool, rehash string) {
+	if !isBcrypt(stored) {
+		return false, ""
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, ""
+	}
+	if cost, err := bcrypt.Cost([]byte(stored)); err == nil && cost != config.BcryptCost() {
+		return true, mustHash(password)
+	}
+	return true, ""
+}
+
+// HashPlainPasswords hashes the passwords older versions stored in plain text,
+// so none is kept readable in the database. Users without password keep none.
+func HashPlainPasswords(tx *gorm.DB) error {
+	var users []models.User
+	if err := tx.Select("id", "password").Where("password <> ''").Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if isBcrypt(user.Password) {
+			continue
+		}
+		hash := mustHash(user.Password)
+		if hash == "" {
+			return errors.New("Failed to hash the password of user " + strconv.FormatUint(uint64(user.ID), 10))
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("password", hash).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// CompareDummy takes as long as verifying a real password, so unknown emails
+// cannot be told apart from wrong passwords by the response time
+func CompareDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), config.BcryptCost())
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// mustHash hashes an already accepted password, ignoring the length policy
+func mustHash(password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost())
+	if err != nil {
+		return ""
+	}
+	return string(hash)
+}
+
+func isBcrypt(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// Errors of the token handling
+var (
+	ErrInvalidToken   = errors.New("Invalid or expired token")
+	ErrSessionRevoked = errors.New("Session has been revoked")
+)
+
+// Claims are the claims of an access token
+type Claims struct {
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int    `json:"expires_in" example:"900"` // lifetime of the access token in seconds
+}
+
+var (
+	secretOnce sync.Once
+	secret     []byte
+)
+
+// signingKey returns JWT_SECRET or, if unset, a random key valid until the process ends
+func signingKey() []byte {
+	secretOnce.Do(func() {
+		if value := os.Getenv("JWT_SECRET"); value != "" {
+			secret = []byte(value)
+			return
+		}
+		log.Println("JWT_SECRET is not set, using a random secret; tokens become invalid on restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	})
+	return secret
+}
+
+// NewSession starts a session for the user and issues its first token pair
+func NewSession(tx *gorm.DB, user *models.User) (TokenPair, error) {
+	session := models.Session{
+		ID:        randomString(16),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL()),
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return TokenPair{}, err
+	}
+	return issue(tx, &session)
+}
+
+// Refresh exchanges a refresh token for a new pair. Every refresh token can be
+// used only once; presenting a used one again revokes the whole session, since
+// the token has most likely been stolen.
+func Refresh(tx *gorm.DB, refreshToken string) (TokenPair, uint, error) {
+	var token models.RefreshToken
+	if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+		return TokenPair{}, 0, ErrInvalidToken
+	}
+
+	var session models.Session
+	if err := tx.Where("id = ?", token.SessionID).First(&session).Error; err != nil {
+		return TokenPair{}, 0, ErrInvalidToken
+	}
+	if session.RevokedAt != nil {
+		return TokenPair{}, 0, ErrSessionRevoked
+	}
+	now := time.Now()
+	if token.UsedAt != nil {
+		if err := Revoke(tx, session.ID); err != nil {
+			return TokenPair{}, 0, err
+		}
+		return TokenPair{}, 0, ErrSessionRevoked
+	}
+	if now.After(token.ExpiresAt) || now.After(session.ExpiresAt) {
+		return TokenPair{}, 0, ErrInvalidToken
+	}
+
+	// only one concurrent request may use the token
+	result := tx.Model(&models.RefreshToken{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", now)
+	if result.Error != nil {
+		return TokenPair{}, 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return TokenPair{}, 0, ErrInvalidToken
+	}
+
+	pair, err := issue(tx, &session)
+	return pair, session.UserID, err
+}
+
+// Revoke ends a session, its access and refresh tokens are rejected afterwards
+func Revoke(tx *gorm.DB, sessionID string) error {
+	return tx.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAll ends all sessions of a user except the given one, e.g. after a password change
+func RevokeAll(tx *gorm.DB, userID uint, except string) error {
+	return tx.Model(&models.Session{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, except).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ParseAccessToken verifies the signature and expiry of an access token
+func ParseAccessToken(value string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(value, claims, func(t *jwt.Token) (interface{}, error) {
+		return signingKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// UserID returns the ID of the user the token was issued to
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// issue creates a new access and refresh token for the session
+func issue(tx *gorm.DB, session *models.Session) (TokenPair, error) {
+	now := time.Now()
+	ttl := config.AccessTokenTTL()
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(session.UserID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}).SignedString(signingKey())
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refresh := randomString(32)
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: session.ExpiresAt,
+	}).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ttl.Seconds()),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// NewSecretToken returns a random token for links like calendar feeds and the
+// hash to store instead of it
+func NewSecretToken() (token, hash string) {
+	token = randomString(32)
+	return token, hashToken(token)
+}
+
+// HashSecretToken returns the stored hash of a token issued by NewSecretToken
+func HashSecretToken(token string) string {
+	return hashToken(token)
+}
@@ -0,0 +1,119 @@
+package authz
+
+import (
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// Subject is an authenticated user together with their department roles
+type Subject struct {
+	User  *models.User
+	roles map[uint]string
+}
+
+// Load reads the department roles of the user
+func Load(tx *gorm.DB, user *models.User) (*Subject, error) {
+	var memberships []models.UserDepartment
+	if err := tx.Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	s := &Subject{User: user, roles: map[uint]string{}}
+	for _, m := range memberships {
+		s.roles[m.DepartmentID] = m.Role
+	}
+	return s, nil
+}
+
+// IsAdmin reports whether the subject may do everything
+func (s *Subject) IsAdmin() bool {
+	return s.User.IsAdmin
+}
+
+// Role returns the role of the subject in the department, "" if not a member
+func (s *Subject) Role(departmentID uint) string {
+	return s.roles[departmentID]
+}
+
+// CanView reports whether the subject may read the plan of the department
+func (s *Subject) CanView(departmentID uint) bool {
+	return s.IsAdmin() || s.roles[departmentID] != ""
+}
+
+// CanWork reports whether the subject may take shifts of the department: its
+// employees and managers, but not its viewers
+func (s *Subject) CanWork(departmentID uint) bool {
+	role := s.roles[departmentID]
+	return role == models.RoleEmployee || role == models.RoleManager
+}
+
+// CanManage reports whether the subject may plan the department and decide on absences of its members
+func (s *Subject) CanManage(departmentID uint) bool {
+	return s.IsAdmin() || s.roles[departmentID] == models.RoleManager
+}
+
+// Departments returns the departments the subject is a member of, nil for administrators meaning all
+func (s *Subject) Departments() []uint {
+	if s.IsAdmin() {
+		return nil
+	}
+	ids := []uint{}
+	for id := range s.roles {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ManagedDepartments returns the departments the subject manages, nil for administrators meaning all
+func (s *Subject) ManagedDepartments() []uint {
+	if s.IsAdmin() {
+		return nil
+	}
+	ids := []uint{}
+	for id, role := range s.roles {
+		if role == models.RoleManager {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// CanSeeUser reports whether the subject may see the user: themselves or a member of a shared department
+func (s *Subject) CanSeeUser(tx *gorm.DB, userID uint) bool {
+	if s.IsAdmin() || s.User.ID == userID {
+		return true
+	}
+	return s.anyDepartmentOf(tx, userID, s.CanView)
+}
+
+// CanManageUser reports whether the subject manages a department the user belongs to
+func (s *Subject) CanManageUser(tx *gorm.DB, userID uint) bool {
+	if s.IsAdmin() {
+		return true
+	}
+	return s.anyDepartmentOf(tx, userID, s.CanManage)
+}
+
+func (s *Subject) anyDepartmentOf(tx *gorm.DB, userID uint, allowed func(uint) bool) bool {
+	var departmentIDs []uint
+	if err := tx.Model(&models.UserDepartment{}).Where("user_id = ?", userID).Pluck("department_id", &departmentIDs).Error; err != nil {
+		return false
+	}
+	for _, id := range departmentIDs {
+		if allowed(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserScope limits a query on a table with a user_id column to the rows the
+// subject may see: their own and those of users in their departments
+func (s *Subject) UserScope(tx *gorm.DB, departments []uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if departments == nil {
+			return db
+		}
+		members := tx.Model(&models.UserDepartment{}).Select("user_id").Where("department_id IN ?", departments)
+		return db.Where("user_id = ? OR user_id IN (?)", s.User.ID, members)
+	}
+}
@@ -0,0 +1,161 @@
+package availability
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// RuleAvailability is the rule name of availability violations
+const RuleAvailability = "availability"
+
+// Window is an availability entry resolved to a concrete time span
+type Window struct {
+	AvailabilityID uint      `json:"availability_id"`
+	UserID         uint      `json:"user_id"`
+	Status         string    `json:"status"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Note           string    `json:"note,omitempty"`
+}
+
+// Windows resolves the entries of one user for the given day. Entries for the
+// specific date replace the weekly entries of that weekday.
+func Windows(entries []models.Availability, day time.Time, loc *time.Location) []Window {
+	local := day.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	date := midnight.Format("2006-01-02")
+	weekday := uint(midnight.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+
+	var matching []models.Availability
+	for _, entry := range entries {
+		if entry.Date == date {
+			matching = append(matching, entry)
+		}
+	}
+	if len(matching) == 0 {
+		for _, entry := range entries {
+			if entry.Date == "" && entry.Weekday != nil && *entry.Weekday == weekday {
+				matching = append(matching, entry)
+			}
+		}
+	}
+
+	windows := []Window{}
+	for _, entry := range matching {
+		start, end := midnight, midnight.AddDate(0, 0, 1)
+		if entry.StartTime != "" || entry.EndTime != "" {
+			var err error
+			start, end, err = models.ShiftType{StartTime: entry.StartTime, EndTime: entry.EndTime}.Span(midnight, loc)
+			if err != nil {
+				continue
+			}
+		}
+		windows = append(windows, Window{
+			AvailabilityID: entry.ID,
+			UserID:         entry.UserID,
+			Status:         entry.Status,
+			Start:          start.UTC(),
+			End:            end.UTC(),
+			Note:           entry.Note,
+		})
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows
+}
+
+// Check warns if the shift overlaps a time the user is unavailable, or lies
+// outside the times the user declared as available on the day it starts.
+// Days without any entry are treated as unknown and not reported.
+func Check(entries []models.Availability, shift models.Shift, loc *time.Location) []models.Violation {
+	if shift.UserID == 0 || len(entries) == 0 {
+		return nil
+	}
+
+	var windows []Window
+	for day := shift.StartTime.AddDate(0, 0, -1); day.Before(shift.EndTime); day = day.AddDate(0, 0, 1) {
+		windows = append(windows, Windows(entries, day, loc)...)
+	}
+
+	for _, w := range windows {
+		if w.Status == models.AvailabilityUnavailable && w.Start.Before(shift.EndTime) && w.End.After(shift.StartTime) {
+			return []models.Violation{violation(shift, fmt.Sprintf("User is unavailable from %s to %s",
+				w.Start.In(loc).Format("02.01.2006 15:04"), w.End.In(loc).Format("02.01.2006 15:04")))}
+		}
+	}
+
+	var positive []Window
+	for _, w := range Windows(entries, shift.StartTime, loc) {
+		if w.Status != models.AvailabilityUnavailable {
+			positive = append(positive, w)
+		}
+	}
+	if len(positive) > 0 && !covered(positive, shift.StartTime, shift.EndTime) {
+		return []models.Violation{violation(shift, "Shift lies outside the availability of the user")}
+	}
+	return nil
+}
+
+// Preferred reports whether the shift lies completely within a preferred window
+func Preferred(entries []models.Availability, shift models.Shift, loc *time.Location) bool {
+	var preferred []Window
+	for _, w := range Windows(entries, shift.StartTime, loc) {
+		if w.Status == models.AvailabilityPreferred {
+			preferred = append(preferred, w)
+		}
+	}
+	return len(preferred) > 0 && covered(preferred, shift.StartTime, shift.EndTime)
+}
+
+// covered reports whether the sorted windows cover [start, end) without gaps
+func covered(windows []Window, start, end time.Time) bool {
+	cursor := start
+	for _, w := range windows {
+		if w.Start.After(cursor) {
+			break
+		}
+		if w.End.After(cursor) {
+			cursor = w.End
+		}
+		if !cursor.Before(end) {
+			return true
+		}
+	}
+	return !cursor.Before(end)
+}
+
+func violation(shift models.Shift, message string) models.Violation {
+	return models.Violation{
+		Rule:     RuleAvailability,
+		Severity: models.SeverityWarning,
+		UserID:   shift.UserID,
+		ShiftIDs: []uint{shift.ID},
+		Message:  message,
+	}
+}
+
+// Constraint keeps the schedule generator from planning users when they are unavailable
+type Constraint struct {
+	Entries  map[uint][]models.Availability
+	Location *time.Location
+}
+
+func (c *Constraint) Name() string { return RuleAvailability }
+
+func (c *Constraint) Allows(userID uint, shift models.Shift) bool {
+	shift.UserID = userID
+	return len(Check(c.Entries[userID], shift, c.Location)) == 0
+}
+
+// Score prefers users who marked the shift time as preferred
+func (c *Constraint) Score(userID uint, shift models.Shift) int {
+	if Preferred(c.Entries[userID], shift, c.Location) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,12 @@
+package config
+
+import "os"
+
+// HolidayState returns the federal state whose public holidays apply to
+// departments without an own state (HOLIDAY_STATE, default TH)
+func HolidayState() string {
+	if state := os.Getenv("HOLIDAY_STATE"); state != "" {
+		return state
+	}
+	return "TH"
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// TimeTrackingTolerance returns how far clock in and clock out may differ from
+// the planned shift before it counts as a deviation (TIME_TOLERANCE_MINUTES, default 5)
+func TimeTrackingTolerance() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("TIME_TOLERANCE_MINUTES")); err == nil && minutes >= 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 5 * time.Minute
+}
+
+// MaxCarryOverHours returns how many overtime hours are carried over into the
+// next month at the monthly closing (WORKTIME_MAX_CARRY_OVER_HOURS, default 0 = all)
+func MaxCarryOverHours() float64 {
+	if hours, err := strconv.ParseFloat(os.Getenv("WORKTIME_MAX_CARRY_OVER_HOURS"), 64); err == nil && hours >= 0 {
+		return hours
+	}
+	return 0
+}
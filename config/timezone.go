@@ -0,0 +1,19 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// Location returns the time zone used to interpret dates without offset (TIMEZONE, default Europe/Berlin)
+func Location() *time.Location {
+	name := os.Getenv("TIMEZONE")
+	if name == "" {
+		name = "Europe/Berlin"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -0,0 +1,15 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// VacationDaysPerYear returns the default vacation entitlement of users without
+// an explicit entitlement for a year (VACATION_DAYS_PER_YEAR, default 30)
+func VacationDaysPerYear() float64 {
+	if days, err := strconv.ParseFloat(os.Getenv("VACATION_DAYS_PER_YEAR"), 64); err == nil && days >= 0 {
+		return days
+	}
+	return 30
+}
@@ -0,0 +1,48 @@
+package contract
+
+import (
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/recurrence"
+)
+
+// ValidAt returns the contract valid on the day of t in loc, or nil
+func ValidAt(contracts []models.Contract, t time.Time, loc *time.Location) *models.Contract {
+	date := t.In(loc).Format("2006-01-02")
+	for i := range contracts {
+		c := &contracts[i]
+		if c.ValidFrom <= date && (c.ValidTo == "" || date <= c.ValidTo) {
+			return c
+		}
+	}
+	return nil
+}
+
+// WorksOn reports whether the weekday is a working day of the contract
+func WorksOn(c models.Contract, day time.Weekday) bool {
+	days, err := recurrence.ParseWeekdays(c.WorkingDays)
+	if err != nil {
+		return false
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// DailyHours returns the weekly hours of the contract spread evenly over its
+// working days, or 0 on other days
+func DailyHours(c models.Contract, day time.Weekday) float64 {
+	if len(c.WorkingDays) == 0 || !WorksOn(c, day) {
+		return 0
+	}
+	return c.WeeklyHours / float64(len(c.WorkingDays))
+}
+
+// Overlap reports whether the validity periods of the contracts overlap
+func Overlap(a, b models.Contract) bool {
+	return (b.ValidTo == "" || a.ValidFrom <= b.ValidTo) && (a.ValidTo == "" || b.ValidFrom <= a.ValidTo)
+}
@@ -0,0 +1,182 @@
+package coverage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/holidays"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// Slot states
+const (
+	StatusOK    = "ok"
+	StatusUnder = "under"
+	StatusOver  = "over"
+)
+
+// Slot is one staffing need on a concrete day compared with the planned shifts
+type Slot struct {
+	Date            string    `json:"date" example:"2024-05-06"`
+	Weekday         uint      `json:"weekday" example:"1"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	ShiftTypeID     *uint     `json:"shift_type_id,omitempty"`
+	QualificationID *uint     `json:"qualification_id,omitempty"`
+	RequirementID   *uint     `json:"requirement_id,omitempty"`
+	Required        int       `json:"required"`
+	Planned         int       `json:"planned"`
+	Difference      int       `json:"difference"`
+	Status          string    `json:"status" example:"under"`
+	ShiftIDs        []uint    `json:"shift_ids"`
+	Holiday         string    `json:"holiday,omitempty" example:"Reformationstag"` // public holiday on the slot's date
+}
+
+// Input holds everything needed to compute the coverage of a department
+type Input struct {
+	From         time.Time
+	To           time.Time
+	Location     *time.Location
+	Requirements []models.StaffingRequirement
+	ShiftTypes   []models.ShiftType
+	Shifts       []models.Shift
+	// Qualifications maps user IDs to the IDs of their qualifications
+	Qualifications map[uint]map[uint]bool
+	// Holidays flags slots on public holidays, may be nil
+	Holidays *holidays.Calendar
+}
+
+// ISOWeekday returns the weekday of t with Monday = 1 and Sunday = 7
+func ISOWeekday(t time.Time) uint {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return uint(t.Weekday())
+}
+
+// Slots expands the requirements into slots for every day in [From, To).
+// Shift types with a required headcount act as a daily requirement unless an
+// explicit requirement exists for that shift type on that weekday.
+func Slots(in Input) []Slot {
+	loc := in.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	types := map[uint]models.ShiftType{}
+	for _, t := range in.ShiftTypes {
+		types[t.ID] = t
+	}
+
+	var slots []Slot
+	from := in.From.In(loc)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Before(in.To); day = day.AddDate(0, 0, 1) {
+		weekday := ISOWeekday(day)
+		explicit := map[uint]bool{}
+		holiday := ""
+		if in.Holidays != nil {
+			if h, ok := in.Holidays.On(day, loc); ok {
+				holiday = h.Name
+			}
+		}
+
+		for _, req := range in.Requirements {
+			if req.Weekday != weekday {
+				continue
+			}
+
+			var start, end time.Time
+			var err error
+			if req.ShiftTypeID != nil {
+				t, ok := types[*req.ShiftTypeID]
+				if !ok {
+					continue
+				}
+				explicit[t.ID] = true
+				start, end, err = t.Span(day, loc)
+			} else {
+				start, end, err = models.ShiftType{StartTime: req.StartTime, EndTime: req.EndTime}.Span(day, loc)
+			}
+			if err != nil {
+				continue
+			}
+
+			id := req.ID
+			slots = append(slots, Slot{
+				Date:            day.Format("2006-01-02"),
+				Weekday:         weekday,
+				Start:           start.UTC(),
+				End:             end.UTC(),
+				ShiftTypeID:     req.ShiftTypeID,
+				QualificationID: req.QualificationID,
+				RequirementID:   &id,
+				Required:        int(req.Headcount),
+				Holiday:         holiday,
+			})
+		}
+
+		for _, t := range in.ShiftTypes {
+			if t.RequiredHeadcount == 0 || explicit[t.ID] {
+				continue
+			}
+			start, end, err := t.Span(day, loc)
+			if err != nil {
+				continue
+			}
+			id := t.ID
+			slots = append(slots, Slot{
+				Date:        day.Format("2006-01-02"),
+				Weekday:     weekday,
+				Start:       start.UTC(),
+				End:         end.UTC(),
+				ShiftTypeID: &id,
+				Required:    int(t.RequiredHeadcount),
+				Holiday:     holiday,
+			})
+		}
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+	return slots
+}
+
+// Covers reports whether the shift counts towards the slot: it must belong to
+// the slot's shift type, or span the whole time slot if the slot has no type
+func Covers(slot Slot, shift models.Shift) bool {
+	if slot.ShiftTypeID != nil {
+		return shift.ShiftTypeID != nil && *shift.ShiftTypeID == *slot.ShiftTypeID &&
+			shift.StartTime.Before(slot.End) && shift.EndTime.After(slot.Start)
+	}
+	return !shift.StartTime.After(slot.Start) && !shift.EndTime.Before(slot.End)
+}
+
+// Compute counts the planned shifts per slot and classifies each slot
+func Compute(in Input) []Slot {
+	slots := Slots(in)
+	for i := range slots {
+		slot := &slots[i]
+		slot.ShiftIDs = []uint{}
+		for _, shift := range in.Shifts {
+			if shift.UserID == 0 || !Covers(*slot, shift) {
+				continue
+			}
+			if slot.QualificationID != nil && !in.Qualifications[shift.UserID][*slot.QualificationID] {
+				continue
+			}
+			slot.ShiftIDs = append(slot.ShiftIDs, shift.ID)
+		}
+		slot.Planned = len(slot.ShiftIDs)
+		slot.Difference = slot.Planned - slot.Required
+		switch {
+		case slot.Difference < 0:
+			slot.Status = StatusUnder
+		case slot.Difference > 0:
+			slot.Status = StatusOver
+		default:
+			slot.Status = StatusOK
+		}
+	}
+	return slots
+}
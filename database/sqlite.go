@@ -44,7 +44,22 @@ func CloseDB() {
 }
 
 func AutoMigrate() error {
-	err := db.AutoMigrate(&models.User{}, &models.Todo{}, &models.Department{}, &models.Shift{})
+	// memberships carry the role of the user in the department
+	if err := db.SetupJoinTable(&models.User{}, "Departments", &models.UserDepartment{}); err != nil {
+		return errors.New("Fehler bei der Datenbank-Migration: " + err.Error())
+	}
+	if err := db.SetupJoinTable(&models.Department{}, "Users", &models.UserDepartment{}); err != nil {
+		return errors.New("Fehler bei der Datenbank-Migration: " + err.Error())
+	}
+
+	err := db.AutoMigrate(&models.User{}, &models.Todo{}, &models.Department{}, &models.Shift{}, &models.ShiftSeries{}, &models.ShiftType{},
+		&models.Qualification{}, &models.StaffingRequirement{}, &models.Availability{},
+		&models.Absence{}, &models.VacationEntitlement{}, &models.Session{}, &models.RefreshToken{},
+		&models.PlanPeriod{}, &models.PlanPeriodEvent{}, &models.Notification{},
+		&models.SwapRequest{}, &models.SwapOffer{}, &models.ShiftClaim{},
+		&models.TimeEntry{}, &models.TimeBreak{}, &models.TimeCorrection{},
+		&models.WorkTimeClosing{}, &models.WorkTimeAdjustment{}, &models.Contract{},
+		&models.CalendarFeed{}, &models.ShiftCancellation{})
 	if err != nil {
 		return errors.New("Fehler bei der Datenbank-Migration: " + err.Error())
 	}
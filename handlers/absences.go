@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/absence"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// @Summary Get all absences
+// @Description fetch the own absences and those of members of managed departments, optionally filtered by user, department, type, status and date range
+// @Tags absences
+// @Accept */*
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param department_id query int false "Department ID"
+// @Param type query string false "Type (urlaub, krankheit, fortbildung, sonderurlaub)"
+// @Param status query string false "Status (requested, approved, rejected)"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences [get]
+func HandleAllAbsences(c *fiber.Ctx) error {
+	subject := middleware.CurrentSubject(c)
+	query := database.GetDB().Order("start_date, user_id").Scopes(subject.UserScope(database.GetDB(), subject.ManagedDepartments()))
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("user_id IN (?)", database.GetDB().Table("user_departments").
+			Select("user_id").Where("department_id = ?", departmentID))
+	}
+	if absenceType := c.Query("type"); absenceType != "" {
+		query = query.Where("type = ?", absenceType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("end_date >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("start_date <= ?", to)
+	}
+
+	var absences []models.Absence
+	result := query.Find(&absences)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Absences successfully retrieved",
+		Data:    absences,
+	})
+}
+
+type CreateAbsenceDTO struct {
+	UserID    uint   `json:"user_id"`
+	Type      string `json:"type" example:"urlaub"`
+	StartDate string `json:"start_date" example:"2024-07-01"`
+	EndDate   string `json:"end_date" example:"2024-07-12"`
+	HalfDay   bool   `json:"half_day"`
+	Comment   string `json:"comment"`
+}
+
+// @Summary Request an absence
+// @Description create a new absence request for the current user, or as a manager for a member of the department; it has to be approved before it blocks shifts
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param absence body CreateAbsenceDTO true "Absence to request"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences [post]
+func HandleCreateAbsence(c *fiber.Ctx) error {
+	dto := new(CreateAbsenceDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	entry := models.Absence{Status: models.AbsenceRequested}
+	if dto.UserID == 0 {
+		dto.UserID = middleware.CurrentUser(c).ID
+	}
+	applyAbsenceDTO(&entry, dto)
+	if !canEditAbsence(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateAbsence(&entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result := database.GetDB().Create(&entry)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Absence successfully requested",
+		Data:    entry,
+	})
+}
+
+// @Summary Get a single absence
+// @Description fetch absence by ID
+// @Tags absences
+// @Param id path int true "Absence ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences/{id} [get]
+func HandleGetOneAbsence(c *fiber.Ctx) error {
+	var entry models.Absence
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Absence not found",
+		})
+	}
+	if !canEditAbsence(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Absence successfully retrieved",
+		Data:    entry,
+	})
+}
+
+// @Summary Update an absence request
+// @Description update an absence that has not been decided yet
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param absence body CreateAbsenceDTO true "Absence update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences/{id} [put]
+func HandleUpdateAbsence(c *fiber.Ctx) error {
+	var entry models.Absence
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Absence not found",
+		})
+	}
+	if !canEditAbsence(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+	if entry.Status != models.AbsenceRequested {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Absence has already been decided",
+		})
+	}
+
+	dto := new(CreateAbsenceDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	applyAbsenceDTO(&entry, dto)
+	if !canEditAbsence(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateAbsence(&entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Save(&entry).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Absence successfully updated",
+		Data:    entry,
+	})
+}
+
+// @Summary Delete an absence
+// @Description withdraw an own absence request, or as a manager cancel any absence of a department member
+// @Tags absences
+// @Param id path int true "Absence ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences/{id} [delete]
+func HandleDeleteAbsence(c *fiber.Ctx) error {
+	var entry models.Absence
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Absence not found",
+		})
+	}
+	// decided absences can only be cancelled by a manager
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManageUser(database.GetDB(), entry.UserID) && (entry.UserID != subject.User.ID || entry.Status != models.AbsenceRequested) {
+		return middleware.Forbidden(c)
+	}
+
+	result := database.GetDB().Delete(&entry)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Absence successfully deleted",
+	})
+}
+
+type DecideAbsenceDTO struct {
+	Note string `json:"note"`
+}
+
+// @Summary Approve an absence
+// @Description approve a requested absence; vacation beyond the remaining days is refused unless force is set. Shifts planned during the absence are returned as violations.
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param force query bool false "Approve even if not enough vacation days are left, administrators only"
+// @Param decision body DecideAbsenceDTO true "Decision"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse{data=VacationBalanceDTO}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences/{id}/approve [post]
+func HandleApproveAbsence(c *fiber.Ctx) error {
+	return decideAbsence(c, models.AbsenceApproved)
+}
+
+// @Summary Reject an absence
+// @Description reject a requested absence
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param decision body DecideAbsenceDTO true "Decision"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /absences/{id}/reject [post]
+func HandleRejectAbsence(c *fiber.Ctx) error {
+	return decideAbsence(c, models.AbsenceRejected)
+}
+
+func decideAbsence(c *fiber.Ctx, status string) error {
+	var entry models.Absence
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Absence not found",
+		})
+	}
+	// managers decide on absences of their members, but not on their own
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManageUser(database.GetDB(), entry.UserID) || (entry.UserID == subject.User.ID && !subject.IsAdmin()) {
+		return middleware.Forbidden(c)
+	}
+	if entry.Status != models.AbsenceRequested {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Absence has already been decided",
+		})
+	}
+
+	dto := new(DecideAbsenceDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	entry.DecidedByID = &subject.User.ID
+
+	loc := config.Location()
+	if status == models.AbsenceApproved && entry.Type == models.AbsenceVacation && !forceRequested(c) {
+		isHoliday, err := holidayFilter(database.GetDB(), entry.UserID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		start, _, _ := absence.Span(entry, loc)
+		end, _ := time.ParseInLocation("2006-01-02", entry.EndDate, loc)
+		for year := start.Year(); year <= end.Year(); year++ {
+			balance, err := vacationBalance(database.GetDB(), entry.UserID, year)
+			if err != nil {
+				return c.Status(500).JSON(models.APIResponse{
+					Success: false,
+					Error:   err.Error(),
+				})
+			}
+			from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+			if absence.Days(entry, from, from.AddDate(1, 0, 0), loc, isHoliday) > balance.Remaining {
+				return c.Status(422).JSON(models.APIResponse{
+					Success: false,
+					Error:   "Not enough vacation days left in " + strconv.Itoa(year),
+					Data:    balance,
+				})
+			}
+		}
+	}
+
+	now := time.Now()
+	entry.Status = status
+	entry.DecisionNote = dto.Note
+	entry.DecidedAt = &now
+	if err := database.GetDB().Save(&entry).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// shifts already planned during an approved absence have to be reassigned
+	var violations []models.Violation
+	if status == models.AbsenceApproved {
+		start, end, _ := absence.Span(entry, loc)
+		var shifts []models.Shift
+		database.GetDB().Where("user_id = ? AND end_time > ? AND start_time < ?", entry.UserID, start.UTC(), end.UTC()).
+			Order("start_time").Find(&shifts)
+		for _, shift := range shifts {
+			violations = append(violations, absence.Check([]models.Absence{entry}, shift, loc)...)
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Absence successfully " + status,
+		Data:       entry,
+		Violations: violations,
+	})
+}
+
+type VacationBalanceDTO struct {
+	UserID      uint    `json:"user_id"`
+	Year        int     `json:"year"`
+	Entitlement float64 `json:"entitlement"`
+	Taken       float64 `json:"taken"`     // approved vacation days
+	Requested   float64 `json:"requested"` // vacation days awaiting a decision
+	Remaining   float64 `json:"remaining"` // entitlement minus approved days
+}
+
+// @Summary Get the vacation balance of a user
+// @Description entitlement, approved and requested vacation days of a user in a year
+// @Tags users
+// @Param id path int true "User ID"
+// @Param year query int false "Year, defaults to the current year"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=VacationBalanceDTO}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/vacation [get]
+func HandleGetVacationBalance(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	if subject := middleware.CurrentSubject(c); subject.User.ID != user.ID && !subject.CanManageUser(database.GetDB(), user.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	balance, err := vacationBalance(database.GetDB(), user.ID, c.QueryInt("year", time.Now().In(config.Location()).Year()))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Vacation balance successfully retrieved",
+		Data:    balance,
+	})
+}
+
+type VacationEntitlementDTO struct {
+	Year int     `json:"year" example:"2024"`
+	Days float64 `json:"days" example:"30"`
+}
+
+// @Summary Set the vacation entitlement of a user
+// @Description set the number of vacation days of a user for a year; managers cannot set their own
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param entitlement body VacationEntitlementDTO true "Entitlement"
+// @Success 200 {object} models.APIResponse{data=VacationBalanceDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/vacation [put]
+func HandleSetVacationEntitlement(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	// managers set the entitlements of their members, but not their own
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManageUser(database.GetDB(), user.ID) || (user.ID == subject.User.ID && !subject.IsAdmin()) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(VacationEntitlementDTO)
+	if err := c.BodyParser(dto); err != nil || dto.Year < 1900 || dto.Days < 0 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	var entitlement models.VacationEntitlement
+	database.GetDB().Where("user_id = ? AND year = ?", user.ID, dto.Year).First(&entitlement)
+	entitlement.UserID = user.ID
+	entitlement.Year = dto.Year
+	entitlement.Days = dto.Days
+	if err := database.GetDB().Save(&entitlement).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	balance, err := vacationBalance(database.GetDB(), user.ID, dto.Year)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Vacation entitlement successfully updated",
+		Data:    balance,
+	})
+}
+
+// vacationBalance sums the vacation days of a user within a calendar year
+func vacationBalance(tx *gorm.DB, userID uint, year int) (VacationBalanceDTO, error) {
+	balance := VacationBalanceDTO{UserID: userID, Year: year, Entitlement: config.VacationDaysPerYear()}
+
+	var entitlement models.VacationEntitlement
+	err := tx.Where("user_id = ? AND year = ?", userID, year).First(&entitlement).Error
+	if err == nil {
+		balance.Entitlement = entitlement.Days
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return balance, err
+	}
+
+	loc := config.Location()
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(1, 0, 0)
+
+	isHoliday, err := holidayFilter(tx, userID)
+	if err != nil {
+		return balance, err
+	}
+
+	var absences []models.Absence
+	if err := tx.Where("user_id = ? AND type = ? AND status <> ? AND end_date >= ? AND start_date < ?",
+		userID, models.AbsenceVacation, models.AbsenceRejected, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Find(&absences).Error; err != nil {
+		return balance, err
+	}
+	for _, a := range absences {
+		days := absence.Days(a, from, to, loc, isHoliday)
+		if a.Status == models.AbsenceApproved {
+			balance.Taken += days
+		} else {
+			balance.Requested += days
+		}
+	}
+	balance.Remaining = balance.Entitlement - balance.Taken
+	return balance, nil
+}
+
+// canEditAbsence reports whether the current user may see and change the
+// absence: their own, or one of a member of a department they manage
+func canEditAbsence(c *fiber.Ctx, entry *models.Absence) bool {
+	subject := middleware.CurrentSubject(c)
+	return entry.UserID == subject.User.ID || subject.CanManageUser(database.GetDB(), entry.UserID)
+}
+
+// holidayFilter reports whether a day is a public holiday for the user, such days are no vacation days
+func holidayFilter(tx *gorm.DB, userID uint) (func(time.Time) bool, error) {
+	calendar, err := userCalendar(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc := config.Location()
+	return func(day time.Time) bool {
+		_, ok := calendar.On(day, loc)
+		return ok
+	}, nil
+}
+
+// loadAbsences returns the approved absences of the users overlapping [from, to) grouped by user ID
+func loadAbsences(tx *gorm.DB, userIDs []uint, from, to time.Time) (map[uint][]models.Absence, error) {
+	loc := config.Location()
+	var absences []models.Absence
+	if err := tx.Where("user_id IN ? AND status = ? AND end_date >= ? AND start_date <= ?", userIDs, models.AbsenceApproved,
+		from.In(loc).Format("2006-01-02"), to.In(loc).Format("2006-01-02")).Find(&absences).Error; err != nil {
+		return nil, err
+	}
+	byUser := map[uint][]models.Absence{}
+	for _, a := range absences {
+		byUser[a.UserID] = append(byUser[a.UserID], a)
+	}
+	return byUser, nil
+}
+
+// checkAbsences reports shifts planned during approved absences of their users
+func checkAbsences(tx *gorm.DB, shifts []*models.Shift) ([]models.Violation, error) {
+	var violations []models.Violation
+	loc := config.Location()
+	for _, shift := range shifts {
+		if shift.UserID == 0 {
+			continue
+		}
+		absences, err := loadAbsences(tx, []uint{shift.UserID}, shift.StartTime, shift.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, absence.Check(absences[shift.UserID], *shift, loc)...)
+	}
+	return violations, nil
+}
+
+func applyAbsenceDTO(entry *models.Absence, dto *CreateAbsenceDTO) {
+	entry.UserID = dto.UserID
+	entry.Type = dto.Type
+	entry.StartDate = dto.StartDate
+	entry.EndDate = dto.EndDate
+	entry.HalfDay = dto.HalfDay
+	entry.Comment = dto.Comment
+	if entry.EndDate == "" {
+		entry.EndDate = entry.StartDate
+	}
+}
+
+func validateAbsence(entry *models.Absence) error {
+	switch entry.Type {
+	case models.AbsenceVacation, models.AbsenceSickness, models.AbsenceTraining, models.AbsenceSpecialLeave:
+	default:
+		return errors.New("Type must be urlaub, krankheit, fortbildung or sonderurlaub")
+	}
+	start, err := time.Parse("2006-01-02", entry.StartDate)
+	if err != nil {
+		return errors.New("Invalid start date, expected YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", entry.EndDate)
+	if err != nil {
+		return errors.New("Invalid end date, expected YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return errors.New("End date must not be before start date")
+	}
+	if entry.HalfDay && !end.Equal(start) {
+		return errors.New("Half days must start and end on the same day")
+	}
+	if err := database.GetDB().First(&models.User{}, entry.UserID).Error; err != nil {
+		return errors.New("Invalid user ID")
+	}
+	return nil
+}
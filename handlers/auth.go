@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/auth"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+type LoginDTO struct {
+	Email    string `json:"email" example:"admin@example.com"`
+	Password string `json:"password"`
+}
+
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LoginResponseDTO struct {
+	auth.TokenPair
+	User models.User `json:"user"`
+}
+
+// @Summary Log in
+// @Description verify email and password and issue an access and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginDTO true "Credentials"
+// @Success 200 {object} models.APIResponse{data=LoginResponseDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/login [post]
+func HandleLogin(c *fiber.Ctx) error {
+	dto := new(LoginDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	var user models.User
+	err := database.GetDB().Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(dto.Email))).First(&user).Error
+	if err != nil {
+		auth.CompareDummy(dto.Password)
+	}
+	if err != nil || !checkPassword(&user, dto.Password) {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid email or password",
+		})
+	}
+
+	pair, err := auth.NewSession(database.GetDB(), &user)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Successfully logged in",
+		Data:    LoginResponseDTO{TokenPair: pair, User: user},
+	})
+}
+
+// @Summary Refresh the tokens
+// @Description exchange a refresh token for a new token pair; each refresh token is valid only once and reusing one ends the session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshDTO true "Refresh token"
+// @Success 200 {object} models.APIResponse{data=auth.TokenPair}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/refresh [post]
+func HandleRefresh(c *fiber.Ctx) error {
+	dto := new(RefreshDTO)
+	if err := c.BodyParser(dto); err != nil || dto.RefreshToken == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	pair, _, err := auth.Refresh(database.GetDB(), dto.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrSessionRevoked) {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Tokens successfully refreshed",
+		Data:    pair,
+	})
+}
+
+// @Summary Log out
+// @Description end the current session, its access and refresh tokens become invalid
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/logout [post]
+func HandleLogout(c *fiber.Ctx) error {
+	if err := auth.Revoke(database.GetDB(), middleware.CurrentSession(c)); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Successfully logged out",
+	})
+}
+
+// @Summary Get the current user
+// @Description fetch the authenticated user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /auth/me [get]
+func HandleCurrentUser(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+	database.GetDB().Preload("Departments").Preload("Qualifications").First(user, user.ID)
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "User successfully retrieved",
+		Data:    user,
+	})
+}
+
+// checkPassword verifies the password of the user and stores a fresh hash if
+// the stored one is outdated
+func checkPassword(user *models.User, password string) bool {
+	ok, rehash := auth.VerifyPassword(user.Password, password)
+	if ok && rehash != "" {
+		if err := database.GetDB().Model(user).Update("password", rehash).Error; err == nil {
+			user.Password = rehash
+		}
+	}
+	return ok
+}
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/availability"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// @Summary Get all availabilities
+// @Description fetch the own availability entries and those of members of managed departments, optionally filtered by user
+// @Tags availabilities
+// @Accept */*
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /availabilities [get]
+func HandleAllAvailabilities(c *fiber.Ctx) error {
+	subject := middleware.CurrentSubject(c)
+	query := database.GetDB().Order("user_id, date, weekday, start_time").Scopes(subject.UserScope(database.GetDB(), subject.ManagedDepartments()))
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var availabilities []models.Availability
+	result := query.Find(&availabilities)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Availabilities successfully retrieved",
+		Data:    availabilities,
+	})
+}
+
+type CreateAvailabilityDTO struct {
+	UserID    uint   `json:"user_id"`
+	Weekday   *uint  `json:"weekday" example:"1"`
+	Date      string `json:"date" example:"2024-05-06"`
+	StartTime string `json:"start_time" example:"06:00"`
+	EndTime   string `json:"end_time" example:"14:00"`
+	Status    string `json:"status" example:"available"`
+	Note      string `json:"note"`
+}
+
+// @Summary Create an availability
+// @Description create a weekly (weekday) or one-off (date) availability entry of a user; without start and end time it covers the whole day
+// @Tags availabilities
+// @Accept json
+// @Produce json
+// @Param availability body CreateAvailabilityDTO true "Availability to create"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /availabilities [post]
+func HandleCreateAvailability(c *fiber.Ctx) error {
+	dto := new(CreateAvailabilityDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	entry := models.Availability{}
+	if dto.UserID == 0 {
+		dto.UserID = middleware.CurrentUser(c).ID
+	}
+	applyAvailabilityDTO(&entry, dto)
+	if !canEditAvailability(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateAvailability(&entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result := database.GetDB().Create(&entry)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Availability successfully created",
+		Data:    entry,
+	})
+}
+
+// @Summary Get a single availability
+// @Description fetch availability entry by ID
+// @Tags availabilities
+// @Param id path int true "Availability ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /availabilities/{id} [get]
+func HandleGetOneAvailability(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var entry models.Availability
+	if err := database.GetDB().Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Availability not found",
+		})
+	}
+	if !canEditAvailability(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Availability successfully retrieved",
+		Data:    entry,
+	})
+}
+
+// @Summary Update an availability
+// @Description update availability entry by ID
+// @Tags availabilities
+// @Accept json
+// @Produce json
+// @Param id path int true "Availability ID"
+// @Param availability body CreateAvailabilityDTO true "Availability update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /availabilities/{id} [put]
+func HandleUpdateAvailability(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var entry models.Availability
+	if err := database.GetDB().Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Availability not found",
+		})
+	}
+	if !canEditAvailability(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(CreateAvailabilityDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	applyAvailabilityDTO(&entry, dto)
+	if !canEditAvailability(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateAvailability(&entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Save(&entry).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Availability successfully updated",
+		Data:    entry,
+	})
+}
+
+// @Summary Delete an availability
+// @Description delete availability entry by ID
+// @Tags availabilities
+// @Param id path int true "Availability ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /availabilities/{id} [delete]
+func HandleDeleteAvailability(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var entry models.Availability
+	if err := database.GetDB().Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Availability not found",
+		})
+	}
+	if !canEditAvailability(c, &entry) {
+		return middleware.Forbidden(c)
+	}
+
+	result := database.GetDB().Delete(&entry)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Availability successfully deleted",
+	})
+}
+
+// Availability summaries of a user on one day
+const (
+	AvailabilityUnknown = "unknown"
+	AvailabilityPartial = "partial"
+)
+
+type UserAvailabilityDTO struct {
+	UserID    uint                  `json:"user_id"`
+	FirstName string                `json:"first_name"`
+	LastName  string                `json:"last_name"`
+	Status    string                `json:"status" example:"available"` // available, preferred, unavailable, partial or unknown
+	Windows   []availability.Window `json:"windows"`
+}
+
+// @Summary Get the availability of a department on a day
+// @Description list the availability windows of every department member for the given day
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Param date query string true "Day (YYYY-MM-DD)"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=[]UserAvailabilityDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/availability [get]
+func HandleDepartmentAvailability(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Preload("Users").Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	loc := config.Location()
+	day, err := time.ParseInLocation("2006-01-02", c.Query("date"), loc)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid date, expected YYYY-MM-DD",
+		})
+	}
+
+	userIDs := []uint{}
+	for _, user := range department.Users {
+		userIDs = append(userIDs, user.ID)
+	}
+	entries, err := loadAvailabilities(database.GetDB(), userIDs)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	members := []UserAvailabilityDTO{}
+	for _, user := range department.Users {
+		windows := availability.Windows(entries[user.ID], day, loc)
+		members = append(members, UserAvailabilityDTO{
+			UserID:    user.ID,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Status:    summarizeAvailability(windows),
+			Windows:   windows,
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Availability successfully retrieved",
+		Data:    members,
+	})
+}
+
+// summarizeAvailability returns the common status of the windows, or partial if they differ
+func summarizeAvailability(windows []availability.Window) string {
+	if len(windows) == 0 {
+		return AvailabilityUnknown
+	}
+	status := windows[0].Status
+	for _, w := range windows[1:] {
+		if w.Status != status {
+			return AvailabilityPartial
+		}
+	}
+	return status
+}
+
+// canEditAvailability reports whether the current user may see and change the
+// entry: their own, or one of a member of a department they manage
+func canEditAvailability(c *fiber.Ctx, entry *models.Availability) bool {
+	subject := middleware.CurrentSubject(c)
+	return entry.UserID == subject.User.ID || subject.CanManageUser(database.GetDB(), entry.UserID)
+}
+
+// loadAvailabilities returns the availability entries of the users grouped by user ID
+func loadAvailabilities(tx *gorm.DB, userIDs []uint) (map[uint][]models.Availability, error) {
+	var entries []models.Availability
+	if err := tx.Where("user_id IN ?", userIDs).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	byUser := map[uint][]models.Availability{}
+	for _, entry := range entries {
+		byUser[entry.UserID] = append(byUser[entry.UserID], entry)
+	}
+	return byUser, nil
+}
+
+// checkAvailability warns about shifts planned outside the availability of their users
+func checkAvailability(tx *gorm.DB, shifts []*models.Shift) ([]models.Violation, error) {
+	var userIDs []uint
+	for _, shift := range shifts {
+		if shift.UserID != 0 {
+			userIDs = append(userIDs, shift.UserID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	entries, err := loadAvailabilities(tx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []models.Violation
+	loc := config.Location()
+	for _, shift := range shifts {
+		violations = append(violations, availability.Check(entries[shift.UserID], *shift, loc)...)
+	}
+	return violations, nil
+}
+
+func applyAvailabilityDTO(entry *models.Availability, dto *CreateAvailabilityDTO) {
+	entry.UserID = dto.UserID
+	entry.Weekday = dto.Weekday
+	entry.Date = dto.Date
+	entry.StartTime = dto.StartTime
+	entry.EndTime = dto.EndTime
+	entry.Status = dto.Status
+	entry.Note = dto.Note
+}
+
+func validateAvailability(entry *models.Availability) error {
+	if (entry.Weekday == nil) == (entry.Date == "") {
+		return errors.New("Either weekday or date is required")
+	}
+	if entry.Weekday != nil && (*entry.Weekday < 1 || *entry.Weekday > 7) {
+		return errors.New("Weekday must be between 1 (Monday) and 7 (Sunday)")
+	}
+	if entry.Date != "" {
+		if _, err := time.Parse("2006-01-02", entry.Date); err != nil {
+			return errors.New("Invalid date, expected YYYY-MM-DD")
+		}
+	}
+	if entry.StartTime != "" || entry.EndTime != "" {
+		if _, err := models.ParseClock(entry.StartTime); err != nil {
+			return err
+		}
+		if _, err := models.ParseClock(entry.EndTime); err != nil {
+			return err
+		}
+	}
+	switch entry.Status {
+	case models.AvailabilityAvailable, models.AvailabilityUnavailable, models.AvailabilityPreferred:
+	default:
+		return errors.New("Status must be available, unavailable or preferred")
+	}
+	if err := database.GetDB().First(&models.User{}, entry.UserID).Error; err != nil {
+		return errors.New("Invalid user ID")
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/auth"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/ical"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// Time span around today covered by calendar feeds
+const (
+	calendarPastDays   = 90
+	calendarFutureDays = 365
+)
+
+// CalendarFeedDTO is the subscription link of a calendar feed, the token is only shown once
+type CalendarFeedDTO struct {
+	URL   string `json:"url" example:"https://schichtplaner.example.com/calendar/3q2-7wEjhB0.ics"`
+	Token string `json:"token"`
+}
+
+// @Summary Create the calendar feed of a user
+// @Description create a secret iCalendar subscription link for the shifts of a user; an existing link stops working
+// @Tags users
+// @Param id path int true "User ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=CalendarFeedDTO}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/calendar-feed [post]
+func HandleCreateUserCalendarFeed(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	if subject := middleware.CurrentSubject(c); subject.User.ID != user.ID && !subject.IsAdmin() {
+		return middleware.Forbidden(c)
+	}
+	return createCalendarFeed(c, models.CalendarFeed{UserID: &user.ID}, "user_id = ?", user.ID)
+}
+
+// @Summary Delete the calendar feed of a user
+// @Description revoke the iCalendar subscription link of a user
+// @Tags users
+// @Param id path int true "User ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/calendar-feed [delete]
+func HandleDeleteUserCalendarFeed(c *fiber.Ctx) error {
+	if subject := middleware.CurrentSubject(c); fmt.Sprint(subject.User.ID) != c.Params("id") && !subject.IsAdmin() {
+		return middleware.Forbidden(c)
+	}
+	return deleteCalendarFeed(c, "user_id = ?", c.Params("id"))
+}
+
+// @Summary Create the calendar feed of a department
+// @Description create a secret iCalendar subscription link for the shifts of the whole department; an existing link stops working
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=CalendarFeedDTO}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/calendar-feed [post]
+func HandleCreateDepartmentCalendarFeed(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(department.ID) {
+		return middleware.Forbidden(c)
+	}
+	return createCalendarFeed(c, models.CalendarFeed{DepartmentID: &department.ID}, "department_id = ?", department.ID)
+}
+
+// @Summary Delete the calendar feed of a department
+// @Description revoke the iCalendar subscription link of a department
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/calendar-feed [delete]
+func HandleDeleteDepartmentCalendarFeed(c *fiber.Ctx) error {
+	departmentID, err := c.ParamsInt("id")
+	if err != nil || !middleware.CurrentSubject(c).CanManage(uint(departmentID)) {
+		return middleware.Forbidden(c)
+	}
+	return deleteCalendarFeed(c, "department_id = ?", departmentID)
+}
+
+// @Summary Get a calendar feed
+// @Description iCalendar feed of the shifts of a user or department from 90 days ago to a year ahead, including cancelled events for removed shifts. Authenticated by the secret token in the link.
+// @Tags calendar
+// @Param token path string true "Secret token of the feed"
+// @Produce text/calendar
+// @Success 200 {string} string "iCalendar data"
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /calendar/{token}.ics [get]
+func HandleCalendarFeed(c *fiber.Ctx) error {
+	var feed models.CalendarFeed
+	if err := database.GetDB().Where("token_hash = ?", auth.HashSecretToken(c.Params("token"))).First(&feed).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Calendar not found",
+		})
+	}
+
+	now := time.Now().UTC()
+	from, to := now.AddDate(0, 0, -calendarPastDays), now.AddDate(0, 0, calendarFutureDays)
+	shifts := database.GetDB().Preload("User").Preload("ShiftType").Scopes(publishedShifts).
+		Where("end_time > ? AND start_time < ?", from, to).Order("start_time")
+	cancellations := database.GetDB().Where("start_time >= ? AND start_time < ?", from, to).Order("id")
+
+	var calendar ical.Calendar
+	departments := map[uint]models.Department{}
+	if feed.DepartmentID != nil {
+		var department models.Department
+		if err := database.GetDB().First(&department, *feed.DepartmentID).Error; err != nil {
+			return c.Status(404).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Calendar not found",
+			})
+		}
+		departments[department.ID] = department
+		calendar = ical.Calendar{Name: department.Name, Description: department.Description, Color: department.Color}
+		shifts = shifts.Where("department_id = ?", department.ID)
+		cancellations = cancellations.Where("department_id = ?", department.ID)
+	} else {
+		var user models.User
+		if err := database.GetDB().First(&user, *feed.UserID).Error; err != nil {
+			return c.Status(404).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Calendar not found",
+			})
+		}
+		calendar = ical.Calendar{Name: user.FirstName + " " + user.LastName, Color: user.Color}
+		shifts = shifts.Where("user_id = ?", user.ID)
+		cancellations = cancellations.Where("user_id = ?", user.ID)
+	}
+
+	var planned []models.Shift
+	if err := shifts.Find(&planned).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	var removed []models.ShiftCancellation
+	if err := cancellations.Find(&removed).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if feed.UserID != nil {
+		var departmentList []models.Department
+		database.GetDB().Find(&departmentList)
+		for _, department := range departmentList {
+			departments[department.ID] = department
+		}
+	}
+
+	active := map[uint]bool{}
+	for _, shift := range planned {
+		active[shift.ID] = true
+		calendar.Events = append(calendar.Events, shiftEvent(shift, departments[shift.DepartmentID], feed.DepartmentID != nil))
+	}
+	// only the latest cancellation of a shift still missing from the calendar counts
+	latest := map[uint]models.ShiftCancellation{}
+	var order []uint
+	for _, cancellation := range removed {
+		if active[cancellation.ShiftID] {
+			continue
+		}
+		if _, ok := latest[cancellation.ShiftID]; !ok {
+			order = append(order, cancellation.ShiftID)
+		}
+		latest[cancellation.ShiftID] = cancellation
+	}
+	for _, shiftID := range order {
+		cancellation := latest[shiftID]
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:       shiftUID(shiftID),
+			Sequence:  cancellation.Sequence,
+			Stamp:     cancellation.CreatedAt,
+			Start:     cancellation.StartTime,
+			End:       cancellation.EndTime,
+			Summary:   "Cancelled",
+			Cancelled: true,
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="schichtplan.ics"`)
+	return c.Send(calendar.Marshal())
+}
+
+// createCalendarFeed replaces the feed matching the query with a new one and returns its link
+func createCalendarFeed(c *fiber.Ctx, feed models.CalendarFeed, query string, args ...interface{}) error {
+	token, hash := auth.NewSecretToken()
+	feed.TokenHash = hash
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(query, args...).Delete(&models.CalendarFeed{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&feed).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Calendar feed successfully created",
+		Data:    CalendarFeedDTO{URL: c.BaseURL() + "/calendar/" + token + ".ics", Token: token},
+	})
+}
+
+func deleteCalendarFeed(c *fiber.Ctx, query string, args ...interface{}) error {
+	if err := database.GetDB().Where(query, args...).Delete(&models.CalendarFeed{}).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Calendar feed successfully deleted",
+	})
+}
+
+// shiftEvent turns a shift into a calendar event, department feeds name the assigned user
+func shiftEvent(shift models.Shift, department models.Department, withUser bool) ical.Event {
+	summary := department.Name
+	if shift.ShiftType != nil {
+		summary = shift.ShiftType.Name
+	}
+	if withUser {
+		if shift.User != nil {
+			summary += ": " + shift.User.FirstName + " " + shift.User.LastName
+		} else {
+			summary += ": open shift"
+		}
+	}
+
+	var description []string
+	for _, text := range []string{shift.Description, department.Description} {
+		if text != "" {
+			description = append(description, text)
+		}
+	}
+	return ical.Event{
+		UID:          shiftUID(shift.ID),
+		Sequence:     shift.Sequence,
+		Stamp:        shift.UpdatedAt,
+		Start:        shift.StartTime,
+		End:          shift.EndTime,
+		Summary:      summary,
+		Description:  strings.Join(description, "\n\n"),
+		Location:     department.Name,
+		Color:        department.Color,
+		LastModified: shift.UpdatedAt,
+	}
+}
+
+// shiftUID is the stable calendar UID of a shift
+func shiftUID(shiftID uint) string {
+	return fmt.Sprintf("shift-%d@schichtplaner", shiftID)
+}
+
+// cancelShiftEvents records that the stored shift disappears from calendar
+// feeds: from all of them if it is deleted (updated is nil), otherwise from
+// the feed of its previous user or department
+func cancelShiftEvents(tx *gorm.DB, stored models.Shift, updated *models.Shift) error {
+	cancellation := models.ShiftCancellation{
+		ShiftID:   stored.ID,
+		StartTime: stored.StartTime,
+		EndTime:   stored.EndTime,
+		Sequence:  stored.Sequence + 1,
+	}
+	if updated == nil || updated.UserID != stored.UserID {
+		cancellation.UserID = stored.UserID
+	}
+	if updated == nil || updated.DepartmentID != stored.DepartmentID {
+		cancellation.DepartmentID = stored.DepartmentID
+	}
+	if cancellation.UserID == 0 && cancellation.DepartmentID == 0 {
+		return nil
+	}
+	return tx.Create(&cancellation).Error
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/ical"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+	"gorm.io/gorm"
+)
+
+// Outcomes of an imported calendar event
+const (
+	importNew     = "new"     // the event becomes one shift per matched user
+	importSkipped = "skipped" // imported before, cancelled or repeated in the file
+	importInvalid = "invalid" // the event cannot become a shift
+)
+
+// CalendarMappingDTO assigns events whose summary matches the pattern to a user
+type CalendarMappingDTO struct {
+	Pattern string `json:"pattern" example:"(?i)frühdienst.*anna"` // regular expression
+	Email   string `json:"email" example:"anna@example.com"`
+}
+
+// ImportedEventDTO is the outcome of a single calendar event
+type ImportedEventDTO struct {
+	UID                 string             `json:"uid"`
+	Summary             string             `json:"summary"`
+	StartTime           time.Time          `json:"start_time"`
+	EndTime             time.Time          `json:"end_time"`
+	Status              string             `json:"status" example:"new"`
+	Note                string             `json:"note,omitempty"`
+	Shifts              []models.Shift     `json:"shifts"`
+	ConflictingShiftIDs []uint             `json:"conflicting_shift_ids,omitempty"` // existing shifts of the users
+	ConflictingUIDs     []string           `json:"conflicting_uids,omitempty"`      // other events of the import
+	Violations          []models.Violation `json:"violations,omitempty"`
+}
+
+// calendarPattern assigns events whose summary matches to a department member
+type calendarPattern struct {
+	pattern *regexp.Regexp
+	userID  uint
+}
+
+// CalendarImportDTO is the preview or result of a calendar import
+type CalendarImportDTO struct {
+	DepartmentID uint               `json:"department_id"`
+	Committed    bool               `json:"committed"`
+	Created      int                `json:"created"` // shifts
+	Skipped      int                `json:"skipped"`
+	Invalid      int                `json:"invalid"`
+	Events       []ImportedEventDTO `json:"events"`
+}
+
+// errImportPreview rolls back the transaction of a preview
+var errImportPreview = errors.New("preview only")
+
+// @Summary Import shifts from a calendar
+// @Description turn the events of an iCalendar file (Outlook, Google) into shifts of the department. Attendees are matched to department members by e-mail, the mappings assign events by a pattern on their summary; events without a member become open shifts. Recurring events are expanded from `from` (default: their start) until `to` (default: 12 weeks ahead). Without `commit` only a preview with conflicts and rule violations is returned; with `commit` all shifts are created in one transaction, which overlaps and blocking violations roll back unless forced. Events imported before are recognized by their UID and skipped.
+// @Tags departments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Department ID"
+// @Param file formData file true "iCalendar file"
+// @Param mappings formData string false "JSON list of summary patterns, e.g. [{\"pattern\":\"Anna\",\"email\":\"anna@example.com\"}]"
+// @Param from query string false "Only events starting at or after (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Only events starting before, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param commit query bool false "Create the shifts instead of previewing them"
+// @Param force query bool false "Create the shifts despite overlaps and blocking rule violations, administrators only"
+// @Success 200 {object} models.APIResponse{data=CalendarImportDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=CalendarImportDTO}
+// @Failure 422 {object} models.APIResponse{data=CalendarImportDTO}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/calendar-import [post]
+func HandleImportCalendar(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Preload("Users").Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	data, err := readUpload(c, "file")
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	events, err := ical.Parse(data, config.Location())
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	members := map[string]uint{}
+	for _, user := range department.Users {
+		members[strings.ToLower(user.Email)] = user.ID
+	}
+	patterns, err := parseCalendarMappings(c.FormValue("mappings"), members)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	from, to := time.Time{}, time.Now().Add(defaultSeriesHorizon)
+	if value := c.Query("from"); value != "" {
+		if from, err = parseTimeParam(value, false); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid from, expected YYYY-MM-DD or RFC3339",
+			})
+		}
+	}
+	singleTo := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	if value := c.Query("to"); value != "" {
+		if to, err = parseTimeParam(value, true); err != nil || !to.After(from) {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid to, expected YYYY-MM-DD or RFC3339 after from",
+			})
+		}
+		singleTo = to
+	}
+
+	report := CalendarImportDTO{DepartmentID: department.ID, Committed: c.QueryBool("commit")}
+	force := forceRequested(c)
+	var violations []models.Violation
+	var conflicts bool
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var imported []string
+		if err := tx.Model(&models.Shift{}).Where("department_id = ? AND import_uid <> ''", department.ID).
+			Pluck("import_uid", &imported).Error; err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		for _, uid := range imported {
+			seen[uid] = true
+		}
+
+		// events replacing single occurrences of a recurring event take their place
+		overrides := map[string]bool{}
+		for _, event := range events {
+			if event.RecurrenceID != nil {
+				overrides[calendarImportUID(event)] = true
+			}
+		}
+		var entries []ImportedEventDTO
+		for _, event := range events {
+			if event.RRule == "" {
+				if event.Start.Before(from) || !event.Start.Before(singleTo) {
+					continue
+				}
+				entries = append(entries, calendarImportEntry(event, department.ID, members, patterns, seen))
+				continue
+			}
+			occurrences, err := event.Occurrences(from, to)
+			if err != nil {
+				entries = append(entries, ImportedEventDTO{UID: event.UID, Summary: event.Summary, StartTime: event.Start.UTC(),
+					EndTime: event.End.UTC(), Status: importInvalid, Note: err.Error(), Shifts: []models.Shift{}})
+				continue
+			}
+			for _, occurrence := range occurrences {
+				if !overrides[calendarImportUID(occurrence)] {
+					entries = append(entries, calendarImportEntry(occurrence, department.ID, members, patterns, seen))
+				}
+			}
+		}
+
+		var shifts []*models.Shift
+		owner := map[uint]int{}
+		for i := range entries {
+			entry := &entries[i]
+			for j := range entry.Shifts {
+				if err := checkUnlocked(tx, entry.Shifts[j]); err != nil {
+					var locked *periodLockedError
+					if !errors.As(err, &locked) {
+						return err
+					}
+					entry.Status, entry.Note, entry.Shifts = importInvalid, locked.Error(), []models.Shift{}
+					break
+				}
+			}
+		}
+		for i := range entries {
+			for j := range entries[i].Shifts {
+				shifts = append(shifts, &entries[i].Shifts[j])
+			}
+		}
+		report.Events = entries
+		if len(shifts) == 0 {
+			return nil
+		}
+
+		// the shifts are stored to judge them together with the plan, a preview rolls back afterwards
+		var err error
+		violations, err = saveShifts(tx, shifts, true)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			for j := range entries[i].Shifts {
+				owner[entries[i].Shifts[j].ID] = i
+			}
+		}
+		for i := range entries {
+			entry := &entries[i]
+			for _, shift := range entry.Shifts {
+				ids, err := findOverlappingShiftIDs(tx, &shift)
+				if err != nil {
+					return err
+				}
+				for _, id := range ids {
+					if other, ok := owner[id]; ok {
+						entry.ConflictingUIDs = append(entry.ConflictingUIDs, entries[other].UID)
+					} else {
+						entry.ConflictingShiftIDs = append(entry.ConflictingShiftIDs, id)
+					}
+					conflicts = true
+				}
+			}
+		}
+		for _, violation := range violations {
+			added := map[int]bool{}
+			for _, id := range violation.ShiftIDs {
+				if i, ok := owner[id]; ok && !added[i] {
+					added[i] = true
+					entries[i].Violations = append(entries[i].Violations, violation)
+				}
+			}
+		}
+		if !report.Committed {
+			return errImportPreview
+		}
+		if !force && (conflicts || rules.HasBlocking(violations)) {
+			return errBlockingViolations
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportPreview) && !errors.Is(err, errBlockingViolations) {
+		return shiftSaveError(c, err, violations)
+	}
+	if err != nil {
+		report.Committed = false
+	}
+
+	for i := range report.Events {
+		entry := &report.Events[i]
+		switch entry.Status {
+		case importNew:
+			report.Created += len(entry.Shifts)
+		case importSkipped:
+			report.Skipped++
+		case importInvalid:
+			report.Invalid++
+		}
+		if !report.Committed {
+			for j := range entry.Shifts {
+				entry.Shifts[j].ID = 0
+			}
+		}
+	}
+	if report.Events == nil {
+		report.Events = []ImportedEventDTO{}
+	}
+
+	if errors.Is(err, errBlockingViolations) {
+		if conflicts {
+			return c.Status(409).JSON(models.APIResponse{
+				Success:    false,
+				Error:      "Imported shifts overlap with other shifts of their users",
+				Data:       report,
+				Violations: violations,
+			})
+		}
+		return c.Status(422).JSON(models.APIResponse{
+			Success:    false,
+			Error:      "Imported shifts violate planning rules",
+			Data:       report,
+			Violations: violations,
+		})
+	}
+	message := "Calendar import preview successfully created"
+	if report.Committed {
+		message = "Calendar successfully imported"
+	}
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    message,
+		Data:       report,
+		Violations: violations,
+	})
+}
+
+// calendarImportEntry turns an event into the shifts of the matched members,
+// marking the UID as seen so repeated events are skipped
+func calendarImportEntry(event ical.Event, departmentID uint, members map[string]uint,
+	patterns []calendarPattern, seen map[string]bool) ImportedEventDTO {
+	uid := calendarImportUID(event)
+	entry := ImportedEventDTO{
+		UID:       uid,
+		Summary:   event.Summary,
+		StartTime: event.Start.UTC(),
+		EndTime:   event.End.UTC(),
+		Status:    importNew,
+		Shifts:    []models.Shift{},
+	}
+	switch {
+	case event.UID == "":
+		entry.Status, entry.Note = importInvalid, "Event has no UID"
+	case seen[uid]:
+		entry.Status, entry.Note = importSkipped, "Event has already been imported"
+	case event.Cancelled:
+		entry.Status, entry.Note = importSkipped, "Event is cancelled"
+	case event.AllDay:
+		entry.Status, entry.Note = importInvalid, "All-day events are not imported"
+	case !event.End.After(event.Start):
+		entry.Status, entry.Note = importInvalid, errInvertedShift.Error()
+	}
+	if entry.Status != importNew {
+		return entry
+	}
+	seen[uid] = true
+
+	var userIDs []uint
+	added := map[uint]bool{}
+	for _, email := range event.Attendees {
+		if id, ok := members[email]; ok && !added[id] {
+			added[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	for _, p := range patterns {
+		if p.pattern.MatchString(event.Summary) && !added[p.userID] {
+			added[p.userID] = true
+			userIDs = append(userIDs, p.userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		entry.Note = "No department member matched, the event becomes an open shift"
+		userIDs = []uint{0}
+	}
+
+	description := event.Summary
+	if event.Description != "" {
+		description += "\n\n" + event.Description
+	}
+	for _, userID := range userIDs {
+		shift := models.Shift{
+			StartTime:    entry.StartTime,
+			EndTime:      entry.EndTime,
+			Description:  description,
+			UserID:       userID,
+			DepartmentID: departmentID,
+			ImportUID:    uid,
+		}
+		if shift.IsOpen() {
+			shift.ClaimMode = models.ClaimApproval
+		}
+		entry.Shifts = append(entry.Shifts, shift)
+	}
+	return entry
+}
+
+// calendarImportUID identifies an event, occurrences of recurring events by their start
+func calendarImportUID(event ical.Event) string {
+	if event.RecurrenceID == nil {
+		return event.UID
+	}
+	return event.UID + "/" + event.RecurrenceID.UTC().Format("20060102T150405Z")
+}
+
+// parseCalendarMappings compiles the summary patterns of the mappings to the members they assign
+func parseCalendarMappings(value string, members map[string]uint) ([]calendarPattern, error) {
+	var patterns []calendarPattern
+	if value == "" {
+		return patterns, nil
+	}
+	var mappings []CalendarMappingDTO
+	if err := json.Unmarshal([]byte(value), &mappings); err != nil {
+		return nil, errors.New("Invalid mappings, expected a JSON list of pattern and email")
+	}
+	for _, mapping := range mappings {
+		pattern, err := regexp.Compile(mapping.Pattern)
+		if err != nil || mapping.Pattern == "" {
+			return nil, errors.New("Invalid pattern " + mapping.Pattern)
+		}
+		id, ok := members[strings.ToLower(mapping.Email)]
+		if !ok {
+			return nil, errors.New("No department member with email " + mapping.Email)
+		}
+		patterns = append(patterns, calendarPattern{pattern: pattern, userID: id})
+	}
+	return patterns, nil
+}
+
+// readUpload returns the content of the uploaded file in the form field, or the
+// request body if the request is no multipart form
+func readUpload(c *fiber.Ctx, field string) ([]byte, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		if body := c.Body(); len(body) > 0 && !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+			return body, nil
+		}
+		return nil, errors.New("File " + field + " is required")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/contract"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/recurrence"
+	"gorm.io/gorm"
+)
+
+// @Summary Get all contracts
+// @Description fetch the own employment contracts and those of members of managed departments, optionally filtered by user and the day they are valid on
+// @Tags contracts
+// @Accept */*
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param date query string false "Only contracts valid on this day (YYYY-MM-DD)"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /contracts [get]
+func HandleAllContracts(c *fiber.Ctx) error {
+	subject := middleware.CurrentSubject(c)
+	query := database.GetDB().Order("user_id, valid_from").Scopes(subject.UserScope(database.GetDB(), subject.ManagedDepartments()))
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if date := c.Query("date"); date != "" {
+		query = query.Where("valid_from <= ? AND (valid_to = '' OR valid_to >= ?)", date, date)
+	}
+
+	var contracts []models.Contract
+	result := query.Find(&contracts)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Contracts successfully retrieved",
+		Data:    contracts,
+	})
+}
+
+type CreateContractDTO struct {
+	UserID      uint     `json:"user_id"`
+	Type        string   `json:"type" example:"teilzeit"`
+	WeeklyHours float64  `json:"weekly_hours" example:"30"`
+	WorkingDays []string `json:"working_days" example:"MO,TU,WE,TH,FR"`
+	HourlyWage  float64  `json:"hourly_wage" example:"15.50"`
+	ValidFrom   string   `json:"valid_from" example:"2024-01-01"`
+	ValidTo     string   `json:"valid_to" example:"2024-12-31"`
+}
+
+// @Summary Create a contract
+// @Description create an employment contract of a user; the validity must not overlap another contract of the user
+// @Tags contracts
+// @Accept json
+// @Produce json
+// @Param contract body CreateContractDTO true "Contract to create"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /contracts [post]
+func HandleCreateContract(c *fiber.Ctx) error {
+	dto := new(CreateContractDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	entry := models.Contract{}
+	applyContractDTO(&entry, dto)
+	if err := validateContract(database.GetDB(), &entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err := checkContractOverlap(database.GetDB(), &entry); err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result := database.GetDB().Create(&entry)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Contract successfully created",
+		Data:    entry,
+	})
+}
+
+// @Summary Get a single contract
+// @Description fetch employment contract by ID
+// @Tags contracts
+// @Param id path int true "Contract ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /contracts/{id} [get]
+func HandleGetOneContract(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var entry models.Contract
+	if err := database.GetDB().Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Contract not found",
+		})
+	}
+	if subject := middleware.CurrentSubject(c); subject.User.ID != entry.UserID && !subject.CanManageUser(database.GetDB(), entry.UserID) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Contract successfully retrieved",
+		Data:    entry,
+	})
+}
+
+// @Summary Update a contract
+// @Description update employment contract by ID
+// @Tags contracts
+// @Accept json
+// @Produce json
+// @Param id path int true "Contract ID"
+// @Param contract body CreateContractDTO true "Contract update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /contracts/{id} [put]
+func HandleUpdateContract(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var entry models.Contract
+	if err := database.GetDB().Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Contract not found",
+		})
+	}
+
+	dto := new(CreateContractDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	applyContractDTO(&entry, dto)
+	if err := validateContract(database.GetDB(), &entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err := checkContractOverlap(database.GetDB(), &entry); err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Save(&entry).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Contract successfully updated",
+		Data:    entry,
+	})
+}
+
+// @Summary Delete a contract
+// @Description delete employment contract by ID
+// @Tags contracts
+// @Param id path int true "Contract ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /contracts/{id} [delete]
+func HandleDeleteContract(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var entry models.Contract
+	if err := database.GetDB().Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Contract not found",
+		})
+	}
+
+	result := database.GetDB().Delete(&entry)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Contract successfully deleted",
+	})
+}
+
+// loadContracts returns the contracts of the users grouped by user ID
+func loadContracts(tx *gorm.DB, userIDs []uint) (map[uint][]models.Contract, error) {
+	var contracts []models.Contract
+	if err := tx.Where("user_id IN ?", userIDs).Order("valid_from").Find(&contracts).Error; err != nil {
+		return nil, err
+	}
+	byUser := map[uint][]models.Contract{}
+	for _, c := range contracts {
+		byUser[c.UserID] = append(byUser[c.UserID], c)
+	}
+	return byUser, nil
+}
+
+// checkContractOverlap rejects a second contract of the user for the same days
+func checkContractOverlap(tx *gorm.DB, entry *models.Contract) error {
+	var others []models.Contract
+	if err := tx.Where("user_id = ? AND id <> ?", entry.UserID, entry.ID).Find(&others).Error; err != nil {
+		return err
+	}
+	for _, other := range others {
+		if contract.Overlap(*entry, other) {
+			return errors.New("The user already has a contract valid from " + other.ValidFrom)
+		}
+	}
+	return nil
+}
+
+func applyContractDTO(entry *models.Contract, dto *CreateContractDTO) {
+	entry.UserID = dto.UserID
+	entry.Type = dto.Type
+	entry.WeeklyHours = dto.WeeklyHours
+	entry.WorkingDays = dto.WorkingDays
+	entry.HourlyWage = dto.HourlyWage
+	entry.ValidFrom = dto.ValidFrom
+	entry.ValidTo = dto.ValidTo
+}
+
+func validateContract(tx *gorm.DB, entry *models.Contract) error {
+	switch entry.Type {
+	case models.ContractFullTime, models.ContractPartTime, models.ContractMinijob, models.ContractApprentice:
+	default:
+		return errors.New("Type must be vollzeit, teilzeit, minijob or azubi")
+	}
+	if entry.WeeklyHours < 0 || entry.WeeklyHours > 168 {
+		return errors.New("Invalid weekly hours")
+	}
+	if entry.HourlyWage < 0 {
+		return errors.New("Invalid hourly wage")
+	}
+	if len(entry.WorkingDays) == 0 {
+		return errors.New("At least one working day is required")
+	}
+	days, err := recurrence.ParseWeekdays(entry.WorkingDays)
+	if err != nil {
+		return err
+	}
+	seen := map[time.Weekday]bool{}
+	for _, day := range days {
+		if seen[day] {
+			return errors.New("Working days must not repeat")
+		}
+		seen[day] = true
+	}
+	if _, err := time.Parse("2006-01-02", entry.ValidFrom); err != nil {
+		return errors.New("Invalid valid_from date, expected YYYY-MM-DD")
+	}
+	if entry.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", entry.ValidTo); err != nil {
+			return errors.New("Invalid valid_to date, expected YYYY-MM-DD")
+		}
+		if entry.ValidTo < entry.ValidFrom {
+			return errors.New("valid_to must not be before valid_from")
+		}
+	}
+	if err := tx.First(&models.User{}, entry.UserID).Error; err != nil {
+		return errors.New("Invalid user ID")
+	}
+	return nil
+}
@@ -1,22 +1,32 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/holidays"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
 	"github.com/ptmmeiningen/schichtplaner/models"
 )
 
 // @Summary Get all departments
-// @Description fetch all departments
+// @Description fetch all departments the user is a member of, administrators get all
 // @Tags departments
 // @Accept */*
 // @Produce json
 // @Success 200 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /departments [get]
 func HandleAllDepartments(c *fiber.Ctx) error {
+	query := database.GetDB().Preload("Users")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("id IN ?", subject.Departments())
+	}
+
 	var departments []models.Department
-	result := database.GetDB().Preload("Users").Find(&departments)
+	result := query.Find(&departments)
 	if result.Error != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -34,6 +44,7 @@ type CreateDepartmentDTO struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Color       string `json:"color"`
+	State       string `json:"state" example:"TH"`
 }
 
 // @Summary Create a department
@@ -44,7 +55,9 @@ type CreateDepartmentDTO struct {
 // @Param department body CreateDepartmentDTO true "Department to create"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /departments [post]
 func HandleCreateDepartment(c *fiber.Ctx) error {
 	department := new(models.Department)
@@ -55,6 +68,17 @@ func HandleCreateDepartment(c *fiber.Ctx) error {
 		})
 	}
 
+	if department.State != "" {
+		state, err := holidays.NormalizeState(department.State)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		department.State = state
+	}
+
 	result := database.GetDB().Create(&department)
 	if result.Error != nil {
 		return c.Status(500).JSON(models.APIResponse{
@@ -76,18 +100,23 @@ func HandleCreateDepartment(c *fiber.Ctx) error {
 // @Param id path int true "Department ID"
 // @Produce json
 // @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /departments/{id} [get]
 func HandleGetOneDepartment(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var department models.Department
-	if err := database.GetDB().Preload("Users").Where("id = ?", id).First(&department).Error; err != nil {
+	if err := database.GetDB().Preload("Users").Preload("ShiftTypes").Where("id = ?", id).First(&department).Error; err != nil {
 		return c.Status(404).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Department not found",
 		})
 	}
+	if !middleware.CurrentSubject(c).CanView(department.ID) {
+		return middleware.Forbidden(c)
+	}
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -105,7 +134,9 @@ func HandleGetOneDepartment(c *fiber.Ctx) error {
 // @Param department body CreateDepartmentDTO true "Department update data"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /departments/{id} [put]
 func HandleUpdateDepartment(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -125,6 +156,17 @@ func HandleUpdateDepartment(c *fiber.Ctx) error {
 		})
 	}
 
+	if department.State != "" {
+		state, err := holidays.NormalizeState(department.State)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		department.State = state
+	}
+
 	database.GetDB().Save(&department)
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -139,7 +181,9 @@ func HandleUpdateDepartment(c *fiber.Ctx) error {
 // @Param id path int true "Department ID"
 // @Produce json
 // @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /departments/{id} [delete]
 func HandleDeleteDepartment(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -157,3 +201,164 @@ func HandleDeleteDepartment(c *fiber.Ctx) error {
 		Message: "Department successfully deleted",
 	})
 }
+
+type DepartmentMemberDTO struct {
+	UserID    uint   `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Role      string `json:"role" example:"employee"`
+}
+
+// @Summary Get the members of a department
+// @Description list the users of the department with their roles
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=[]DepartmentMemberDTO}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/members [get]
+func HandleDepartmentMembers(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanView(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	members := []DepartmentMemberDTO{}
+	result := database.GetDB().Table("users").
+		Select("users.id AS user_id, users.first_name, users.last_name, users.email, user_departments.role").
+		Joins("JOIN user_departments ON user_departments.user_id = users.id").
+		Where("user_departments.department_id = ? AND users.deleted_at IS NULL", department.ID).
+		Order("users.last_name, users.first_name").
+		Scan(&members)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Members successfully retrieved",
+		Data:    members,
+	})
+}
+
+type DepartmentRoleDTO struct {
+	Role string `json:"role" example:"manager"`
+}
+
+// @Summary Set the role of a department member
+// @Description add the user to the department or change their role there (manager, employee or viewer)
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path int true "Department ID"
+// @Param userId path int true "User ID"
+// @Param role body DepartmentRoleDTO true "Role"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/members/{userId} [put]
+func HandleSetDepartmentMember(c *fiber.Ctx) error {
+	membership, err := loadMembership(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	dto := new(DepartmentRoleDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	switch dto.Role {
+	case models.RoleManager, models.RoleEmployee, models.RoleViewer:
+	default:
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Role must be manager, employee or viewer",
+		})
+	}
+
+	membership.Role = dto.Role
+	if err := database.GetDB().Save(membership).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Member successfully updated",
+		Data:    membership,
+	})
+}
+
+// @Summary Remove a member from a department
+// @Description remove the user from the department
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Param userId path int true "User ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/members/{userId} [delete]
+func HandleRemoveDepartmentMember(c *fiber.Ctx) error {
+	membership, err := loadMembership(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result := database.GetDB().Where("user_id = ? AND department_id = ?", membership.UserID, membership.DepartmentID).
+		Delete(&models.UserDepartment{})
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Member successfully removed",
+	})
+}
+
+// loadMembership returns the membership addressed by the id and userId params,
+// a new one with the employee role if the user is not a member yet
+func loadMembership(c *fiber.Ctx) (*models.UserDepartment, error) {
+	var department models.Department
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return nil, errors.New("Department not found")
+	}
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("userId")).First(&user).Error; err != nil {
+		return nil, errors.New("User not found")
+	}
+
+	membership := models.UserDepartment{UserID: user.ID, DepartmentID: department.ID, Role: models.RoleEmployee}
+	database.GetDB().Where("user_id = ? AND department_id = ?", user.ID, department.ID).Find(&membership)
+	return &membership, nil
+}
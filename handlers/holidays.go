@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/holidays"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+type HolidayListDTO struct {
+	Year      int                `json:"year" example:"2024"`
+	State     string             `json:"state" example:"TH"`
+	StateName string             `json:"state_name" example:"Thüringen"`
+	Holidays  []holidays.Holiday `json:"holidays"`
+}
+
+// @Summary Get public holidays
+// @Description list the public holidays of a federal state in a year. Without state the state of the department or the installation default is used.
+// @Tags holidays
+// @Produce json
+// @Param year query int false "Year, defaults to the current year"
+// @Param state query string false "Federal state code, e.g. TH"
+// @Param department_id query int false "Department whose state is used"
+// @Success 200 {object} models.APIResponse{data=HolidayListDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /holidays [get]
+func HandleAllHolidays(c *fiber.Ctx) error {
+	year := c.QueryInt("year", time.Now().In(config.Location()).Year())
+	if year < 1583 || year > 9999 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid year",
+		})
+	}
+
+	state := c.Query("state")
+	if state == "" {
+		calendar, err := departmentCalendar(database.GetDB(), uint(c.QueryInt("department_id")))
+		if err != nil {
+			return c.Status(404).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		state = calendar.State
+	}
+
+	days, err := holidays.For(year, state)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	state, _ = holidays.NormalizeState(state)
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Holidays successfully retrieved",
+		Data: HolidayListDTO{
+			Year:      year,
+			State:     state,
+			StateName: holidays.States[state],
+			Holidays:  days,
+		},
+	})
+}
+
+// departmentCalendar returns the holiday calendar of the department, or of the
+// installation if the department has no state or departmentID is 0
+func departmentCalendar(tx *gorm.DB, departmentID uint) (*holidays.Calendar, error) {
+	state := ""
+	if departmentID != 0 {
+		var department models.Department
+		if err := tx.First(&department, departmentID).Error; err != nil {
+			return nil, err
+		}
+		state = department.State
+	}
+	if state == "" {
+		state = config.HolidayState()
+	}
+	return holidays.NewCalendar(state)
+}
+
+// userCalendar returns the holiday calendar of the first department of the user
+// with a state, or of the installation
+func userCalendar(tx *gorm.DB, userID uint) (*holidays.Calendar, error) {
+	var departmentIDs []uint
+	if err := tx.Table("user_departments").Where("user_id = ?", userID).Order("department_id").
+		Pluck("department_id", &departmentIDs).Error; err != nil {
+		return nil, err
+	}
+	var departments []models.Department
+	if err := tx.Where("id IN ? AND state <> ''", departmentIDs).Order("id").Find(&departments).Error; err != nil {
+		return nil, err
+	}
+	if len(departments) > 0 {
+		return holidays.NewCalendar(departments[0].State)
+	}
+	return holidays.NewCalendar(config.HolidayState())
+}
+
+// checkHolidays warns about shifts on public holidays of their department
+func checkHolidays(tx *gorm.DB, shifts []*models.Shift) ([]models.Violation, error) {
+	var violations []models.Violation
+	calendars := map[uint]*holidays.Calendar{}
+	loc := config.Location()
+	for _, shift := range shifts {
+		calendar, ok := calendars[shift.DepartmentID]
+		if !ok {
+			var err error
+			if calendar, err = departmentCalendar(tx, shift.DepartmentID); err != nil {
+				return nil, err
+			}
+			calendars[shift.DepartmentID] = calendar
+		}
+		violations = append(violations, calendar.Check(*shift, loc)...)
+	}
+	return violations, nil
+}
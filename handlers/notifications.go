@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// @Summary Get the own notifications
+// @Description fetch the notifications of the current user, newest first
+// @Tags notifications
+// @Accept */*
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /notifications [get]
+func HandleAllNotifications(c *fiber.Ctx) error {
+	query := database.GetDB().Where("user_id = ?", middleware.CurrentUser(c).ID).Order("created_at desc, id desc")
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	result := query.Find(&notifications)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notifications successfully retrieved",
+		Data:    notifications,
+	})
+}
+
+// @Summary Mark a notification as read
+// @Description mark a notification of the current user as read
+// @Tags notifications
+// @Param id path int true "Notification ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /notifications/{id}/read [post]
+func HandleReadNotification(c *fiber.Ctx) error {
+	var notification models.Notification
+	if err := database.GetDB().Where("id = ? AND user_id = ?", c.Params("id"), middleware.CurrentUser(c).ID).
+		First(&notification).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Notification not found",
+		})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := database.GetDB().Save(&notification).Error; err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notification marked as read",
+		Data:    notification,
+	})
+}
+
+// @Summary Mark all notifications as read
+// @Description mark all unread notifications of the current user as read
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /notifications/read-all [post]
+func HandleReadAllNotifications(c *fiber.Ctx) error {
+	result := database.GetDB().Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", middleware.CurrentUser(c).ID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notifications marked as read",
+	})
+}
+
+// notify creates a copy of the notification for every recipient once,
+// skipping the user who caused it
+func notify(tx *gorm.DB, recipients []uint, senderID uint, notification models.Notification) error {
+	seen := map[uint]bool{senderID: true}
+	var notifications []models.Notification
+	for _, userID := range recipients {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		n := notification
+		n.UserID = userID
+		notifications = append(notifications, n)
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	return tx.Create(&notifications).Error
+}
+
+// departmentManagers returns the IDs of the managers of the department
+func departmentManagers(tx *gorm.DB, departmentID uint) ([]uint, error) {
+	var managers []uint
+	err := tx.Model(&models.UserDepartment{}).Where("department_id = ? AND role = ?", departmentID, models.RoleManager).
+		Pluck("user_id", &managers).Error
+	return managers, err
+}
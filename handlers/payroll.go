@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/payroll"
+	"gorm.io/gorm"
+)
+
+// Sources of the payroll hours
+const (
+	payrollActual  = "actual"  // recorded times
+	payrollPlanned = "planned" // published shifts
+)
+
+// PayrollDTO is the payroll of a user for a month
+type PayrollDTO struct {
+	UserID          uint   `json:"user_id"`
+	PersonnelNumber string `json:"personnel_number" example:"1001"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Month           string `json:"month" example:"2024-05"`
+	Source          string `json:"source" example:"actual"`
+	payroll.Statement
+}
+
+// @Summary Export the payroll of a month
+// @Description hours of all users (or the members of a department) split into normal, night, Sunday and holiday hours with the configured surcharges and their tax free part under §3b EStG, based on the recorded times or the published shifts. Formats are json and datev, the movement data import of DATEV Lohn und Gehalt (semicolon separated with decimal comma); it requires a personnel number for every user with hours.
+// @Tags users
+// @Param month query string false "Month (YYYY-MM), defaults to the previous month"
+// @Param source query string false "actual (default) or planned"
+// @Param department_id query int false "Only members of the department"
+// @Param format query string false "json (default) or datev"
+// @Produce json
+// @Produce text/csv
+// @Success 200 {object} models.APIResponse{data=[]PayrollDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/payroll [get]
+func HandleExportPayroll(c *fiber.Ctx) error {
+	month, source, err := parsePayrollParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	format := strings.ToLower(c.Query("format", "json"))
+	if format != "json" && format != "datev" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Format must be json or datev",
+		})
+	}
+
+	query := database.GetDB().Order("last_name, first_name, id")
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("id IN (?)", database.GetDB().Table("user_departments").Select("user_id").Where("department_id = ?", departmentID))
+	}
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	cfg := payroll.ConfigFromEnv()
+	result := []PayrollDTO{}
+	var records []payroll.Record
+	var missing []string
+	for _, user := range users {
+		entry, err := userPayroll(database.GetDB(), cfg, user, month, source)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		result = append(result, entry)
+		if entry.Hours.Total > 0 && user.PersonnelNumber == "" {
+			missing = append(missing, user.FirstName+" "+user.LastName)
+		}
+		records = append(records, payroll.Record{PersonnelNumber: user.PersonnelNumber, Statement: entry.Statement})
+	}
+
+	if format == "json" {
+		return c.JSON(models.APIResponse{
+			Success: true,
+			Message: "Payroll successfully computed",
+			Data:    result,
+		})
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return c.Status(422).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Users without personnel number: " + strings.Join(missing, ", "),
+		})
+	}
+	data, err := payroll.DATEV(cfg, month, records)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=windows-1252")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="lohn-`+month.Format("2006-01")+`.csv"`)
+	return c.Send(data)
+}
+
+// @Summary Get the payroll of a user
+// @Description hours of the month split into normal, night, Sunday and holiday hours with the configured surcharges and their tax free part under §3b EStG, based on the recorded times or the published shifts
+// @Tags users
+// @Param id path int true "User ID"
+// @Param month query string false "Month (YYYY-MM), defaults to the previous month"
+// @Param source query string false "actual (default) or planned"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=PayrollDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/payroll [get]
+func HandleGetUserPayroll(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	if subject := middleware.CurrentSubject(c); subject.User.ID != user.ID && !subject.CanManageUser(database.GetDB(), user.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	month, source, err := parsePayrollParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	entry, err := userPayroll(database.GetDB(), payroll.ConfigFromEnv(), user, month, source)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Payroll successfully computed",
+		Data:    entry,
+	})
+}
+
+// parsePayrollParams reads the month, by default the previous one, and the source of the hours
+func parsePayrollParams(c *fiber.Ctx) (time.Time, string, error) {
+	loc := config.Location()
+	now := time.Now().In(loc)
+	month := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, loc)
+	if c.Query("month") != "" {
+		t, err := time.ParseInLocation("2006-01", c.Query("month"), loc)
+		if err != nil {
+			return month, "", errors.New("Invalid month, expected YYYY-MM")
+		}
+		month = t
+	}
+	source := c.Query("source", payrollActual)
+	if source != payrollActual && source != payrollPlanned {
+		return month, "", errors.New("Source must be actual or planned")
+	}
+	return month, source, nil
+}
+
+// userPayroll computes the payroll of the user for the month from the finished
+// time entries or the published shifts starting in it
+func userPayroll(tx *gorm.DB, cfg payroll.Config, user models.User, month time.Time, source string) (PayrollDTO, error) {
+	entry := PayrollDTO{
+		UserID:          user.ID,
+		PersonnelNumber: user.PersonnelNumber,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Month:           month.Format("2006-01"),
+		Source:          source,
+	}
+	from, to := month.UTC(), month.AddDate(0, 1, 0).UTC()
+
+	var periods []payroll.Period
+	if source == payrollPlanned {
+		var shifts []models.Shift
+		if err := tx.Scopes(publishedShifts).Where("user_id = ? AND start_time >= ? AND start_time < ?", user.ID, from, to).
+			Find(&shifts).Error; err != nil {
+			return entry, err
+		}
+		for _, shift := range shifts {
+			periods = append(periods, payroll.Period{Start: shift.StartTime, End: shift.EndTime, BreakMinutes: shift.BreakMinutes})
+		}
+	} else {
+		var entries []models.TimeEntry
+		if err := tx.Preload("Breaks").Where("user_id = ? AND clock_in >= ? AND clock_in < ? AND clock_out IS NOT NULL", user.ID, from, to).
+			Find(&entries).Error; err != nil {
+			return entry, err
+		}
+		for _, e := range entries {
+			period := payroll.Period{Start: e.ClockIn, End: *e.ClockOut, BreakMinutes: e.BreakMinutes}
+			for _, b := range e.Breaks {
+				if b.End != nil {
+					period.Breaks = append(period.Breaks, payroll.Break{Start: b.Start, End: *b.End})
+				}
+			}
+			periods = append(periods, period)
+		}
+	}
+
+	contracts, err := loadContracts(tx, []uint{user.ID})
+	if err != nil {
+		return entry, err
+	}
+	isHoliday, err := holidayFilter(tx, user.ID)
+	if err != nil {
+		return entry, err
+	}
+	entry.Statement = payroll.Compute(cfg, periods, contracts[user.ID], isHoliday)
+	return entry, nil
+}
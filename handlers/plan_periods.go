@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/authz"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// @Summary Get all plan periods
+// @Description fetch the plan periods of the own departments, drafts only for their managers, optionally filtered by department, status and date range
+// @Tags plan-periods
+// @Accept */*
+// @Produce json
+// @Param department_id query int false "Department ID"
+// @Param status query string false "Status (draft, published, locked)"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods [get]
+func HandleAllPlanPeriods(c *fiber.Ctx) error {
+	query := database.GetDB().Order("department_id, start_date")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("department_id IN ?", subject.Departments()).
+			Where("status <> ? OR department_id IN ?", models.PlanDraft, subject.ManagedDepartments())
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("end_date >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("start_date <= ?", to)
+	}
+
+	var periods []models.PlanPeriod
+	result := query.Find(&periods)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan periods successfully retrieved",
+		Data:    periods,
+	})
+}
+
+type CreatePlanPeriodDTO struct {
+	DepartmentID uint   `json:"department_id"`
+	StartDate    string `json:"start_date" example:"2024-05-01"`
+	EndDate      string `json:"end_date" example:"2024-05-31"`
+}
+
+// @Summary Create a plan period
+// @Description create a new plan period in draft state, its shifts are only visible to the managers of the department until it is published
+// @Tags plan-periods
+// @Accept json
+// @Produce json
+// @Param period body CreatePlanPeriodDTO true "Plan period to create"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods [post]
+func HandleCreatePlanPeriod(c *fiber.Ctx) error {
+	dto := new(CreatePlanPeriodDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(dto.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	period := models.PlanPeriod{Status: models.PlanDraft}
+	if err := applyPlanPeriodDTO(&period, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return savePlanPeriod(c, &period, "Plan period successfully created")
+}
+
+// @Summary Get a single plan period
+// @Description fetch plan period by ID including its history
+// @Tags plan-periods
+// @Param id path int true "Plan period ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods/{id} [get]
+func HandleGetOnePlanPeriod(c *fiber.Ctx) error {
+	var period models.PlanPeriod
+	if err := database.GetDB().Preload("Events").Where("id = ?", c.Params("id")).First(&period).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Plan period not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanView(period.DepartmentID) || (period.Status == models.PlanDraft && !subject.CanManage(period.DepartmentID)) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan period successfully retrieved",
+		Data:    period,
+	})
+}
+
+// @Summary Update a plan period
+// @Description change the date range of a plan period that is not locked
+// @Tags plan-periods
+// @Accept json
+// @Produce json
+// @Param id path int true "Plan period ID"
+// @Param period body CreatePlanPeriodDTO true "Plan period update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods/{id} [put]
+func HandleUpdatePlanPeriod(c *fiber.Ctx) error {
+	period, errResp := loadPlanPeriod(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if period.Status == models.PlanLocked {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Plan period is locked",
+		})
+	}
+
+	dto := new(CreatePlanPeriodDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(dto.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	if err := applyPlanPeriodDTO(period, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return savePlanPeriod(c, period, "Plan period successfully updated")
+}
+
+// @Summary Delete a plan period
+// @Description delete a plan period that is not locked, its shifts are kept and become visible to all members
+// @Tags plan-periods
+// @Param id path int true "Plan period ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods/{id} [delete]
+func HandleDeletePlanPeriod(c *fiber.Ctx) error {
+	period, errResp := loadPlanPeriod(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if period.Status == models.PlanLocked {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Plan period is locked",
+		})
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plan_period_id = ?", period.ID).Delete(&models.PlanPeriodEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(period).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan period successfully deleted",
+	})
+}
+
+// @Summary Publish a plan period
+// @Description make the shifts of a draft plan period visible to all members and notify the members and the planned users
+// @Tags plan-periods
+// @Param id path int true "Plan period ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods/{id}/publish [post]
+func HandlePublishPlanPeriod(c *fiber.Ctx) error {
+	period, errResp := loadPlanPeriod(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if period.Status != models.PlanDraft {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Only draft plan periods can be published",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		period.Status = models.PlanPublished
+		period.PublishedAt = &now
+		period.PublishedByID = &user.ID
+		if err := changePlanPeriod(tx, period, user.ID, models.PlanActionPublished, ""); err != nil {
+			return err
+		}
+
+		recipients, err := planPeriodRecipients(tx, period)
+		if err != nil {
+			return err
+		}
+		var department models.Department
+		if err := tx.First(&department, period.DepartmentID).Error; err != nil {
+			return err
+		}
+		message := "The plan of " + department.Name + " from " + period.StartDate + " to " + period.EndDate + " has been published"
+		return notify(tx, recipients, user.ID, models.Notification{
+			Type:         models.NotificationPlanPublished,
+			Message:      message,
+			PlanPeriodID: &period.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan period successfully published",
+		Data:    period,
+	})
+}
+
+// @Summary Lock a plan period
+// @Description lock a published plan period once it has been paid out, its shifts can no longer be changed
+// @Tags plan-periods
+// @Param id path int true "Plan period ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods/{id}/lock [post]
+func HandleLockPlanPeriod(c *fiber.Ctx) error {
+	period, errResp := loadPlanPeriod(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if period.Status != models.PlanPublished {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Only published plan periods can be locked",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	now := time.Now()
+	period.Status = models.PlanLocked
+	period.LockedAt = &now
+	period.LockedByID = &user.ID
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return changePlanPeriod(tx, period, user.ID, models.PlanActionLocked, "")
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan period successfully locked",
+		Data:    period,
+	})
+}
+
+type UnlockPlanPeriodDTO struct {
+	Reason string `json:"reason" example:"Correction of a missed night shift"`
+}
+
+// @Summary Unlock a plan period
+// @Description administrators return a locked plan period to published so its shifts can be corrected, the reason is kept in the history and the managers are notified
+// @Tags plan-periods
+// @Accept json
+// @Produce json
+// @Param id path int true "Plan period ID"
+// @Param unlock body UnlockPlanPeriodDTO true "Reason for unlocking"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /plan-periods/{id}/unlock [post]
+func HandleUnlockPlanPeriod(c *fiber.Ctx) error {
+	period, errResp := loadPlanPeriod(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if period.Status != models.PlanLocked {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Plan period is not locked",
+		})
+	}
+
+	dto := new(UnlockPlanPeriodDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	reason := strings.TrimSpace(dto.Reason)
+	if reason == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "A reason is required to unlock a plan period",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		period.Status = models.PlanPublished
+		period.LockedAt = nil
+		period.LockedByID = nil
+		if err := changePlanPeriod(tx, period, user.ID, models.PlanActionUnlocked, reason); err != nil {
+			return err
+		}
+
+		managers, err := departmentManagers(tx, period.DepartmentID)
+		if err != nil {
+			return err
+		}
+		message := "The plan period from " + period.StartDate + " to " + period.EndDate + " has been unlocked: " + reason
+		return notify(tx, managers, user.ID, models.Notification{
+			Type:         models.NotificationPlanUnlocked,
+			Message:      message,
+			PlanPeriodID: &period.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan period successfully unlocked",
+		Data:    period,
+	})
+}
+
+// periodLockedError is returned when shifts in a locked plan period would be changed
+type periodLockedError struct {
+	period models.PlanPeriod
+}
+
+func (e *periodLockedError) Error() string {
+	return "Shift lies in a locked plan period"
+}
+
+// loadPlanPeriod loads the plan period addressed by the id param if the
+// current user manages its department
+func loadPlanPeriod(c *fiber.Ctx) (*models.PlanPeriod, *errorResponse) {
+	var period models.PlanPeriod
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&period).Error; err != nil {
+		return nil, &errorResponse{404, models.APIResponse{Success: false, Error: "Plan period not found"}}
+	}
+	if !middleware.CurrentSubject(c).CanManage(period.DepartmentID) {
+		return nil, &errorResponse{403, models.APIResponse{Success: false, Error: "Insufficient permissions"}}
+	}
+	return &period, nil
+}
+
+// savePlanPeriod stores the period unless it overlaps another period of the department
+func savePlanPeriod(c *fiber.Ctx, period *models.PlanPeriod, message string) error {
+	var overlapping int64
+	if err := database.GetDB().Model(&models.PlanPeriod{}).
+		Where("department_id = ? AND id <> ? AND start_date <= ? AND end_date >= ?", period.DepartmentID, period.ID, period.EndDate, period.StartDate).
+		Count(&overlapping).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if overlapping > 0 {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Plan period overlaps another plan period of the department",
+		})
+	}
+
+	if err := database.GetDB().Omit("Events").Save(period).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    period,
+	})
+}
+
+// changePlanPeriod saves the new state of the period and records the action in its history
+func changePlanPeriod(tx *gorm.DB, period *models.PlanPeriod, userID uint, action, reason string) error {
+	if err := tx.Omit("Events").Save(period).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.PlanPeriodEvent{
+		PlanPeriodID: period.ID,
+		UserID:       userID,
+		Action:       action,
+		Reason:       reason,
+	}).Error
+}
+
+// planPeriodRecipients returns the members of the department and everyone
+// with a shift of the department within the period
+func planPeriodRecipients(tx *gorm.DB, period *models.PlanPeriod) ([]uint, error) {
+	var members, planned []uint
+	if err := tx.Model(&models.UserDepartment{}).Where("department_id = ?", period.DepartmentID).
+		Pluck("user_id", &members).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.Shift{}).
+		Where("department_id = ? AND user_id <> 0 AND start_time >= ? AND start_time < ?", period.DepartmentID, period.StartsAt, period.EndsAt).
+		Distinct().Pluck("user_id", &planned).Error; err != nil {
+		return nil, err
+	}
+	return append(members, planned...), nil
+}
+
+func applyPlanPeriodDTO(period *models.PlanPeriod, dto *CreatePlanPeriodDTO) error {
+	loc := config.Location()
+	start, err := time.ParseInLocation("2006-01-02", dto.StartDate, loc)
+	if err != nil {
+		return errors.New("Invalid start date, expected YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation("2006-01-02", dto.EndDate, loc)
+	if err != nil {
+		return errors.New("Invalid end date, expected YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return errors.New("End date must not be before start date")
+	}
+	if err := database.GetDB().First(&models.Department{}, dto.DepartmentID).Error; err != nil {
+		return errors.New("Invalid department ID")
+	}
+
+	period.DepartmentID = dto.DepartmentID
+	period.StartDate = dto.StartDate
+	period.EndDate = dto.EndDate
+	period.StartsAt = start.UTC()
+	period.EndsAt = end.AddDate(0, 0, 1).UTC()
+	return nil
+}
+
+// checkUnlocked returns a periodLockedError if one of the shifts starts within
+// a locked plan period of its department
+func checkUnlocked(tx *gorm.DB, shifts ...models.Shift) error {
+	for _, shift := range shifts {
+		var periods []models.PlanPeriod
+		if err := tx.Where("department_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?",
+			shift.DepartmentID, models.PlanLocked, shift.StartTime, shift.StartTime).Limit(1).Find(&periods).Error; err != nil {
+			return err
+		}
+		if len(periods) > 0 {
+			return &periodLockedError{period: periods[0]}
+		}
+	}
+	return nil
+}
+
+// visibleShifts hides the shifts of draft plan periods from everyone but the
+// managers of their department
+func visibleShifts(subject *authz.Subject) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if subject.IsAdmin() {
+			return db
+		}
+		return db.Where("shifts.department_id IN ? OR NOT EXISTS (?)", subject.ManagedDepartments(), draftPeriods())
+	}
+}
+
+// publishedShifts hides shifts in plan periods that have not been published yet
+func publishedShifts(db *gorm.DB) *gorm.DB {
+	return db.Where("NOT EXISTS (?)", draftPeriods())
+}
+
+// draftPeriods selects the draft plan periods containing the shift of the outer query
+func draftPeriods() *gorm.DB {
+	return database.GetDB().Model(&models.PlanPeriod{}).Select("1").
+		Where("plan_periods.department_id = shifts.department_id AND plan_periods.status = ?", models.PlanDraft).
+		Where("plan_periods.starts_at <= shifts.start_time AND plan_periods.ends_at > shifts.start_time")
+}
+
+// inDraftPeriod reports whether the shift belongs to a plan period that has not been published yet
+func inDraftPeriod(tx *gorm.DB, shift *models.Shift) bool {
+	var count int64
+	tx.Model(&models.PlanPeriod{}).
+		Where("department_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?", shift.DepartmentID, models.PlanDraft, shift.StartTime, shift.StartTime).
+		Count(&count)
+	return count > 0
+}
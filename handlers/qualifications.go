@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// @Summary Get all qualifications
+// @Description fetch all qualifications
+// @Tags qualifications
+// @Accept */*
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /qualifications [get]
+func HandleAllQualifications(c *fiber.Ctx) error {
+	var qualifications []models.Qualification
+	result := database.GetDB().Order("name").Find(&qualifications)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Qualifications successfully retrieved",
+		Data:    qualifications,
+	})
+}
+
+type CreateQualificationDTO struct {
+	Name        string `json:"name" example:"Ersthelfer"`
+	Description string `json:"description"`
+}
+
+// @Summary Create a qualification
+// @Description create new qualification
+// @Tags qualifications
+// @Accept json
+// @Produce json
+// @Param qualification body CreateQualificationDTO true "Qualification to create"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /qualifications [post]
+func HandleCreateQualification(c *fiber.Ctx) error {
+	dto := new(CreateQualificationDTO)
+	if err := c.BodyParser(dto); err != nil || dto.Name == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	qualification := models.Qualification{Name: dto.Name, Description: dto.Description}
+	result := database.GetDB().Create(&qualification)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Qualification successfully created",
+		Data:    qualification,
+	})
+}
+
+// @Summary Update a qualification
+// @Description update qualification by ID
+// @Tags qualifications
+// @Accept json
+// @Produce json
+// @Param id path int true "Qualification ID"
+// @Param qualification body CreateQualificationDTO true "Qualification update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /qualifications/{id} [put]
+func HandleUpdateQualification(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var qualification models.Qualification
+	if err := database.GetDB().Where("id = ?", id).First(&qualification).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Qualification not found",
+		})
+	}
+
+	dto := new(CreateQualificationDTO)
+	if err := c.BodyParser(dto); err != nil || dto.Name == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	qualification.Name = dto.Name
+	qualification.Description = dto.Description
+	database.GetDB().Save(&qualification)
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Qualification successfully updated",
+		Data:    qualification,
+	})
+}
+
+// @Summary Delete a qualification
+// @Description delete qualification by ID
+// @Tags qualifications
+// @Param id path int true "Qualification ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /qualifications/{id} [delete]
+func HandleDeleteQualification(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	database.GetDB().Exec("DELETE FROM user_qualifications WHERE qualification_id = ?", id)
+	result := database.GetDB().Where("id = ?", id).Delete(&models.Qualification{})
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Qualification successfully deleted",
+	})
+}
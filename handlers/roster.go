@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/roster"
+)
+
+// maxRosterDays limits the period of a printed roster
+const maxRosterDays = 42
+
+// @Summary Print the roster of a department
+// @Description PDF of the department plan for a week, a month or up to 42 days as grid of employees and days, colored by shift type (or department) with a legend. Approved absences are shown as vacation or absent without reason; drafts only to planners.
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Param week query string false "ISO week (YYYY-Www)"
+// @Param month query string false "Month (YYYY-MM)"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Produce application/pdf
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/roster.pdf [get]
+func HandleDepartmentRoster(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Preload("Users").Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanView(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	from, to, err := parsePeriodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	// the columns are whole days, so every shift of the period falls into one
+	loc := config.Location()
+	first, last := from.In(loc), to.In(loc)
+	first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	if end := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, loc); end.Before(last) {
+		last = end.AddDate(0, 0, 1)
+	}
+	if math.Round(last.Sub(first).Hours()/24) > maxRosterDays {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "A roster covers at most 42 days",
+		})
+	}
+	from, to = first.UTC(), last.UTC()
+	var days []roster.Day
+	for day := first; day.Before(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, roster.Day{Date: day})
+	}
+
+	var shifts []models.Shift
+	if err := database.GetDB().Preload("User").Preload("ShiftType").Scopes(visibleShifts(subject)).
+		Where("department_id = ? AND start_time >= ? AND start_time < ?", department.ID, from, to).
+		Order("start_time").Find(&shifts).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// members first, then others with shifts in the department and the open shifts
+	users := department.Users
+	known := map[uint]bool{}
+	for _, user := range users {
+		known[user.ID] = true
+	}
+	for _, shift := range shifts {
+		if shift.User != nil && !known[shift.UserID] {
+			known[shift.UserID] = true
+			users = append(users, *shift.User)
+		}
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		if users[i].LastName != users[j].LastName {
+			return users[i].LastName < users[j].LastName
+		}
+		return users[i].FirstName < users[j].FirstName
+	})
+	userIDs := []uint{}
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	absences, err := loadAbsences(database.GetDB(), userIDs, from, to.Add(-time.Second))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	calendar, err := departmentCalendar(database.GetDB(), department.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	r := roster.Roster{
+		Title:     "Dienstplan " + department.Name,
+		Period:    roster.PeriodLabel(from.In(loc), to.In(loc)),
+		Color:     department.Color,
+		Days:      days,
+		PrintedAt: time.Now().In(loc),
+	}
+	column := map[string]int{}
+	for i := range r.Days {
+		column[r.Days[i].Date.Format("2006-01-02")] = i
+		if holiday, ok := calendar.On(r.Days[i].Date, loc); ok {
+			r.Days[i].Holiday = holiday.Name
+			r.Notes = append(r.Notes, "Feiertag "+r.Days[i].Date.Format("02.01.")+": "+holiday.Name)
+		}
+	}
+
+	rows := map[uint]*roster.Row{}
+	for _, user := range users {
+		row := &roster.Row{Name: user.LastName + ", " + user.FirstName, Color: user.Color, Cells: make([][]roster.Entry, len(days))}
+		for _, absence := range absences[user.ID] {
+			label := "Abwesend"
+			if absence.Type == models.AbsenceVacation {
+				label = "Urlaub"
+			}
+			for date, i := range column {
+				if absence.StartDate <= date && date <= absence.EndDate {
+					row.Cells[i] = append(row.Cells[i], roster.Entry{Label: label, Muted: true})
+				}
+			}
+		}
+		rows[user.ID] = row
+	}
+	open := &roster.Row{Name: "Offene Schichten", Cells: make([][]roster.Entry, len(days))}
+	rows[0] = open
+
+	var legend []roster.LegendEntry
+	listed := map[string]bool{}
+	for _, shift := range shifts {
+		start, end := shift.StartTime.In(loc), shift.EndTime.In(loc)
+		times := start.Format("15:04") + "–" + end.Format("15:04")
+		entry := roster.Entry{Label: times, Color: department.Color}
+		key := "department"
+		description := "Schicht ohne Schichtart"
+		if shift.ShiftType != nil {
+			entry = roster.Entry{Label: shift.ShiftType.Name, Detail: times, Color: shift.ShiftType.Color}
+			if entry.Color == "" {
+				entry.Color = department.Color
+			}
+			key = shift.ShiftType.Name
+			description = shift.ShiftType.StartTime + "–" + shift.ShiftType.EndTime
+		}
+		if !listed[key] {
+			listed[key] = true
+			label := department.Name
+			if shift.ShiftType != nil {
+				label = shift.ShiftType.Name
+			}
+			legend = append(legend, roster.LegendEntry{Label: label, Description: description, Color: entry.Color})
+		}
+		row, ok := rows[shift.UserID]
+		if !ok {
+			// the user was deleted
+			row = open
+		}
+		i := column[start.Format("2006-01-02")]
+		row.Cells[i] = append(row.Cells[i], entry)
+	}
+	for _, user := range users {
+		r.Rows = append(r.Rows, *rows[user.ID])
+	}
+	for _, cell := range open.Cells {
+		if len(cell) > 0 {
+			r.Rows = append(r.Rows, *open)
+			break
+		}
+	}
+	sort.SliceStable(legend, func(i, j int) bool { return legend[i].Label < legend[j].Label })
+	r.Legend = append(legend, roster.LegendEntry{Label: "Urlaub"}, roster.LegendEntry{Label: "Abwesend"})
+
+	name := fmt.Sprintf("dienstplan-%d-%s.pdf", department.ID, from.In(loc).Format("2006-01-02"))
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+name+`"`)
+	return c.Send(r.PDF())
+}
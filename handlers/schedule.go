@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/absence"
+	"github.com/ptmmeiningen/schichtplaner/availability"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/coverage"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+	"github.com/ptmmeiningen/schichtplaner/scheduler"
+	"github.com/ptmmeiningen/schichtplaner/worktime"
+)
+
+type ScheduleProposalDTO struct {
+	DepartmentID uint                  `json:"department_id"`
+	From         time.Time             `json:"from"`
+	To           time.Time             `json:"to"`
+	Seed         int64                 `json:"seed"`
+	Shifts       []models.Shift        `json:"shifts"`
+	Unfilled     []scheduler.Unfilled  `json:"unfilled"`
+	Hours        []scheduler.UserHours `json:"hours"`
+}
+
+// @Summary Generate a shift plan draft
+// @Description propose shift assignments for the department members that fill the staffing requirements of a period while honouring overlaps, qualifications, availability, absences and working time rules. Nothing is saved; the same seed always yields the same draft.
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Param week query string false "ISO week (YYYY-Www)"
+// @Param month query string false "Month (YYYY-MM)"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param seed query int false "Seed for breaking ties between equally loaded users"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=ScheduleProposalDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/schedule/generate [post]
+func HandleGenerateSchedule(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Preload("Users").Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	from, to, err := parsePeriodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	input, err := loadCoverageInput(department.ID, from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	cfg := rules.ConfigFromEnv()
+	userIDs := []uint{}
+	for _, user := range department.Users {
+		userIDs = append(userIDs, user.ID)
+	}
+	var existing []models.Shift
+	windowStart, windowEnd := ruleWindow(cfg, from, to)
+	if err := database.GetDB().
+		Where("user_id IN ? AND end_time > ? AND start_time < ?", userIDs, windowStart, windowEnd).
+		Find(&existing).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	var users []models.User
+	if err := database.GetDB().Preload("Qualifications").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	for _, user := range users {
+		input.Qualifications[user.ID] = map[uint]bool{}
+		for _, q := range user.Qualifications {
+			input.Qualifications[user.ID][q.ID] = true
+		}
+	}
+
+	// department shifts of non-members still count towards the coverage
+	known := map[uint]bool{}
+	for _, shift := range existing {
+		known[shift.ID] = true
+	}
+	for _, shift := range input.Shifts {
+		if !known[shift.ID] {
+			existing = append(existing, shift)
+		}
+	}
+
+	entries, err := loadAvailabilities(database.GetDB(), userIDs)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	available := &availability.Constraint{Entries: entries, Location: config.Location()}
+	absences, err := loadAbsences(database.GetDB(), userIDs, from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	absent := &absence.Constraint{Entries: absences, Location: config.Location()}
+
+	// drafts stay within the contractual hours and the Minijob limit, manual planning only gets a warning
+	if err := loadRuleData(database.GetDB(), &cfg, userIDs); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	cfg.ContractHours.Blocking = true
+	cfg.MinijobLimit.Blocking = true
+	targets := map[uint]float64{}
+	for _, user := range users {
+		isHoliday, err := holidayFilter(database.GetDB(), user.ID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		targets[user.ID] = worktime.TargetHours(cfg.Contracts[user.ID], user.WeeklyHours, from.In(config.Location()), to.In(config.Location()),
+			config.Location(), isHoliday, absences[user.ID])
+	}
+
+	seed := int64(c.QueryInt("seed"))
+	result := scheduler.Generate(scheduler.Input{
+		DepartmentID:   department.ID,
+		Slots:          coverage.Slots(input),
+		Users:          users,
+		ShiftTypes:     input.ShiftTypes,
+		Existing:       existing,
+		Qualifications: input.Qualifications,
+		TargetHours:    targets,
+		Engine:         rules.NewEngine(cfg),
+		Constraints:    []scheduler.Constraint{available, absent},
+		Preferences:    []scheduler.Preference{available},
+		Seed:           seed,
+	})
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Schedule draft successfully generated",
+		Data: ScheduleProposalDTO{
+			DepartmentID: department.ID,
+			From:         from,
+			To:           to,
+			Seed:         seed,
+			Shifts:       result.Shifts,
+			Unfilled:     result.Unfilled,
+			Hours:        result.Hours,
+		},
+	})
+}
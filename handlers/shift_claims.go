@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+type ClaimShiftDTO struct {
+	Note string `json:"note"`
+}
+
+// @Summary Claim an open shift
+// @Description take an open shift of a department the user is an employee or manager of; with claim mode first_come the shift is assigned at once if it is still open and passes the overlap, absence and working time checks, with approval a claim is filed for the managers
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift ID"
+// @Param claim body ClaimShiftDTO false "Note for the managers"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts/{id}/claim [post]
+func HandleClaimShift(c *fiber.Ctx) error {
+	var shift models.Shift
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&shift).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanWork(shift.DepartmentID) || !canViewShift(c, &shift) {
+		return middleware.Forbidden(c)
+	}
+	if !shift.IsOpen() {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   errShiftTaken.Error(),
+		})
+	}
+	if !shift.StartTime.After(time.Now()) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   errShiftStarted.Error(),
+		})
+	}
+	if !hasQualification(database.GetDB(), subject.User.ID, shift.QualificationID) {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Error:   "The shift requires a qualification you do not have",
+		})
+	}
+
+	dto := new(ClaimShiftDTO)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(dto); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid input",
+			})
+		}
+	}
+
+	if shift.ClaimMode == models.ClaimFirstCome {
+		var violations []models.Violation
+		err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			violations, err = assignOpenShift(tx, &shift, subject.User.ID, false)
+			return err
+		})
+		if err != nil {
+			return claimError(c, err, violations)
+		}
+
+		database.GetDB().Preload("User").Preload("ShiftType").First(&shift, shift.ID)
+		return c.JSON(models.APIResponse{
+			Success:    true,
+			Message:    "Shift successfully claimed",
+			Data:       shift,
+			Violations: violations,
+		})
+	}
+
+	if err := checkUnlocked(database.GetDB(), shift); err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+	var pending int64
+	database.GetDB().Model(&models.ShiftClaim{}).
+		Where("shift_id = ? AND user_id = ? AND status = ?", shift.ID, subject.User.ID, models.ClaimRequested).Count(&pending)
+	if pending > 0 {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "You have already claimed this shift",
+		})
+	}
+
+	claim := models.ShiftClaim{
+		ShiftID:      shift.ID,
+		UserID:       subject.User.ID,
+		DepartmentID: shift.DepartmentID,
+		Status:       models.ClaimRequested,
+		Note:         dto.Note,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Shift").Create(&claim).Error; err != nil {
+			return err
+		}
+		managers, err := departmentManagers(tx, shift.DepartmentID)
+		if err != nil {
+			return err
+		}
+		return notify(tx, managers, subject.User.ID, models.Notification{
+			Type:    models.NotificationShiftClaimed,
+			Message: subject.User.FirstName + " " + subject.User.LastName + " claims the open shift on " + formatShiftDay(&shift),
+			ShiftID: &shift.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift claim successfully filed",
+		Data:    claim,
+	})
+}
+
+// @Summary Get all shift claims
+// @Description fetch the own claims for open shifts and those in managed departments, optionally filtered by status and shift
+// @Tags shift-claims
+// @Accept */*
+// @Produce json
+// @Param status query string false "Status (requested, approved, rejected, withdrawn)"
+// @Param shift_id query int false "Shift ID"
+// @Param department_id query int false "Department ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-claims [get]
+func HandleAllShiftClaims(c *fiber.Ctx) error {
+	query := database.GetDB().Preload("Shift").Order("created_at")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("user_id = ? OR department_id IN ?", subject.User.ID, subject.ManagedDepartments())
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if shiftID := c.QueryInt("shift_id"); shiftID > 0 {
+		query = query.Where("shift_id = ?", shiftID)
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+
+	var claims []models.ShiftClaim
+	result := query.Find(&claims)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift claims successfully retrieved",
+		Data:    claims,
+	})
+}
+
+// @Summary Withdraw a shift claim
+// @Description withdraw an own claim that has not been decided yet
+// @Tags shift-claims
+// @Param id path int true "Shift claim ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-claims/{id} [delete]
+func HandleWithdrawShiftClaim(c *fiber.Ctx) error {
+	var claim models.ShiftClaim
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&claim).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift claim not found",
+		})
+	}
+	if claim.UserID != middleware.CurrentUser(c).ID {
+		return middleware.Forbidden(c)
+	}
+
+	if err := moveShiftClaim(database.GetDB(), &claim, map[string]interface{}{"status": models.ClaimWithdrawn}); err != nil {
+		return claimError(c, err, nil)
+	}
+	claim.Status = models.ClaimWithdrawn
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift claim successfully withdrawn",
+		Data:    claim,
+	})
+}
+
+type DecideShiftClaimDTO struct {
+	Note string `json:"note"`
+}
+
+// @Summary Approve a shift claim
+// @Description assign the open shift to the claiming user after checking that it has not started, that the user has the required qualification and checking it for overlaps, absences, locked plan periods and working time rules; the other claims for the shift are rejected
+// @Tags shift-claims
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift claim ID"
+// @Param decision body DecideShiftClaimDTO false "Decision note"
+// @Param force query bool false "Approve despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-claims/{id}/approve [post]
+func HandleApproveShiftClaim(c *fiber.Ctx) error {
+	return decideShiftClaim(c, models.ClaimApproved)
+}
+
+// @Summary Reject a shift claim
+// @Description reject a claim for an open shift, the shift stays open
+// @Tags shift-claims
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift claim ID"
+// @Param decision body DecideShiftClaimDTO false "Decision note"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-claims/{id}/reject [post]
+func HandleRejectShiftClaim(c *fiber.Ctx) error {
+	return decideShiftClaim(c, models.ClaimRejected)
+}
+
+var errShiftTaken = errors.New("Shift has already been assigned")
+
+var errClaimDecided = errors.New("Shift claim has already been decided")
+
+var errShiftStarted = errors.New("Shift has already started")
+
+var errClaimantUnqualified = errors.New("The claiming user lacks the qualification the shift requires")
+
+func decideShiftClaim(c *fiber.Ctx, status string) error {
+	var claim models.ShiftClaim
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&claim).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift claim not found",
+		})
+	}
+	// managers decide on claims of their members, but not on their own
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManage(claim.DepartmentID) || (claim.UserID == subject.User.ID && !subject.IsAdmin()) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(DecideShiftClaimDTO)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(dto); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid input",
+			})
+		}
+	}
+
+	var violations []models.Violation
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := moveShiftClaim(tx, &claim, map[string]interface{}{
+			"status":        status,
+			"decision_note": dto.Note,
+			"decided_by_id": subject.User.ID,
+			"decided_at":    now,
+		}); err != nil {
+			return err
+		}
+
+		var shift models.Shift
+		if err := tx.First(&shift, claim.ShiftID).Error; err != nil {
+			return err
+		}
+		var rejected []uint
+		if status == models.ClaimApproved {
+			// the claim may have been pending since before the shift began or the qualification was revoked
+			if !shift.StartTime.After(now) {
+				return errShiftStarted
+			}
+			if !hasQualification(tx, claim.UserID, shift.QualificationID) {
+				return errClaimantUnqualified
+			}
+			var err error
+			violations, err = assignOpenShift(tx, &shift, claim.UserID, forceRequested(c))
+			if err != nil {
+				return err
+			}
+			var others []uint
+			if err := tx.Model(&models.ShiftClaim{}).Where("shift_id = ? AND status = ?", shift.ID, models.ClaimRequested).
+				Pluck("user_id", &others).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ShiftClaim{}).Where("shift_id = ? AND status = ?", shift.ID, models.ClaimRequested).
+				Updates(map[string]interface{}{"status": models.ClaimRejected, "decided_by_id": subject.User.ID, "decided_at": now}).Error; err != nil {
+				return err
+			}
+			rejected = others
+		}
+
+		if err := notify(tx, []uint{claim.UserID}, subject.User.ID, models.Notification{
+			Type:    models.NotificationClaimDecided,
+			Message: "Your claim for the open shift on " + formatShiftDay(&shift) + " has been " + status,
+			ShiftID: &shift.ID,
+		}); err != nil {
+			return err
+		}
+		return notify(tx, rejected, subject.User.ID, models.Notification{
+			Type:    models.NotificationClaimDecided,
+			Message: "The open shift on " + formatShiftDay(&shift) + " has been assigned to someone else",
+			ShiftID: &shift.ID,
+		})
+	})
+	if err != nil {
+		return claimError(c, err, violations)
+	}
+
+	database.GetDB().Preload("Shift").First(&claim, claim.ID)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift claim successfully " + status,
+		Data:       claim,
+		Violations: violations,
+	})
+}
+
+// assignOpenShift gives the open shift to the user. The conditional update lets
+// only one of several concurrent claims win; the shift is then checked like any
+// other change and the assignment rolled back with the transaction on errors.
+func assignOpenShift(tx *gorm.DB, shift *models.Shift, userID uint, force bool) ([]models.Violation, error) {
+	result := tx.Model(&models.Shift{}).Where("id = ? AND user_id = 0", shift.ID).
+		Updates(map[string]interface{}{"user_id": userID, "claim_mode": ""})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errShiftTaken
+	}
+	shift.UserID = userID
+	shift.ClaimMode = ""
+	return saveShifts(tx, []*models.Shift{shift}, force)
+}
+
+// moveShiftClaim updates the claim only while it is still requested
+func moveShiftClaim(tx *gorm.DB, claim *models.ShiftClaim, updates map[string]interface{}) error {
+	result := tx.Model(&models.ShiftClaim{}).Where("id = ? AND status = ?", claim.ID, models.ClaimRequested).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errClaimDecided
+	}
+	return nil
+}
+
+// hasQualification reports whether the user has the qualification, true if none is required
+func hasQualification(tx *gorm.DB, userID uint, qualificationID *uint) bool {
+	if qualificationID == nil {
+		return true
+	}
+	var count int64
+	tx.Table("user_qualifications").
+		Where("user_id = ? AND qualification_id = ?", userID, *qualificationID).Count(&count)
+	return count > 0
+}
+
+// claimError turns an error of a claim into the matching response
+func claimError(c *fiber.Ctx, err error, violations []models.Violation) error {
+	if errors.Is(err, errShiftTaken) || errors.Is(err, errClaimDecided) {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if errors.Is(err, errShiftStarted) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if errors.Is(err, errClaimantUnqualified) {
+		return c.Status(422).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return shiftSaveError(c, err, violations)
+}
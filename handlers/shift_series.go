@@ -0,0 +1,813 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/recurrence"
+	"gorm.io/gorm"
+)
+
+// defaultSeriesHorizon is how far occurrences of an open ended series are created in advance
+const defaultSeriesHorizon = 12 * 7 * 24 * time.Hour
+
+// Scopes for editing or deleting a single occurrence of a series
+const (
+	scopeThis      = "this"
+	scopeFollowing = "following"
+	scopeAll       = "all"
+)
+
+// @Summary Get all shift series
+// @Description fetch recurring shift series, optionally filtered by user and department
+// @Tags shift-series
+// @Accept */*
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param department_id query int false "Department ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series [get]
+func HandleAllShiftSeries(c *fiber.Ctx) error {
+	query := database.GetDB().Order("start_time")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("department_id IN ? OR user_id = ?", subject.Departments(), subject.User.ID)
+	}
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+
+	var series []models.ShiftSeries
+	result := query.Find(&series)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift series successfully retrieved",
+		Data:    series,
+	})
+}
+
+type CreateShiftSeriesDTO struct {
+	StartTime      time.Time  `json:"start_time"`
+	EndTime        time.Time  `json:"end_time"`
+	Description    string     `json:"description"`
+	BreakMinutes   uint       `json:"break_minutes"`
+	UserID         uint       `json:"user_id"`
+	DepartmentID   uint       `json:"department_id"`
+	ShiftTypeID    *uint      `json:"shift_type_id"`
+	Date           string     `json:"date" example:"2024-05-06"` // first day, used with shift_type_id instead of start and end time
+	Frequency      string     `json:"frequency" example:"weekly"`
+	Interval       int        `json:"interval" example:"1"`
+	Weekdays       []string   `json:"weekdays" example:"MO,TU,WE,TH,FR"`
+	Until          *time.Time `json:"until"`
+	Count          int        `json:"count"`
+	ExceptionDates []string   `json:"exception_dates" example:"2024-12-24"`
+	ExpandUntil    *time.Time `json:"expand_until"`
+}
+
+// @Summary Create a shift series
+// @Description create a recurring shift and its occurrences up to expand_until (default 12 weeks)
+// @Tags shift-series
+// @Accept json
+// @Produce json
+// @Param series body CreateShiftSeriesDTO true "Shift series to create"
+// @Param force query bool false "Save despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series [post]
+func HandleCreateShiftSeries(c *fiber.Ctx) error {
+	dto := new(CreateShiftSeriesDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	series := models.ShiftSeries{}
+	if err := applySeriesDTO(&series, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(series.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateSeries(&series); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	horizon := series.StartTime.Add(defaultSeriesHorizon)
+	if dto.ExpandUntil != nil {
+		horizon = dto.ExpandUntil.UTC()
+	}
+
+	var violations []models.Violation
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Shifts").Create(&series).Error; err != nil {
+			return err
+		}
+		var err error
+		violations, err = expandSeries(tx, &series, series.StartTime, horizon, forceRequested(c))
+		return err
+	})
+	if err != nil {
+		return shiftSaveError(c, err, violations)
+	}
+
+	database.GetDB().Preload("Shifts", orderByStart).First(&series, series.ID)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift series successfully created",
+		Data:       series,
+		Violations: violations,
+	})
+}
+
+// @Summary Get a single shift series
+// @Description fetch shift series by ID including its occurrences
+// @Tags shift-series
+// @Param id path int true "Shift series ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series/{id} [get]
+func HandleGetOneShiftSeries(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	subject := middleware.CurrentSubject(c)
+	var series models.ShiftSeries
+	if err := database.GetDB().Preload("Shifts", orderByStart, visibleShifts(subject)).Where("id = ?", id).First(&series).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift series not found",
+		})
+	}
+	if !subject.CanView(series.DepartmentID) && series.UserID != subject.User.ID {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift series successfully retrieved",
+		Data:    series,
+	})
+}
+
+// @Summary Update a whole shift series
+// @Description update the series and recreate all of its occurrences that have not started yet
+// @Tags shift-series
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift series ID"
+// @Param series body CreateShiftSeriesDTO true "Shift series update data"
+// @Param force query bool false "Save despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series/{id} [put]
+func HandleUpdateShiftSeries(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var series models.ShiftSeries
+	if err := database.GetDB().Where("id = ?", id).First(&series).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift series not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManage(series.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(CreateShiftSeriesDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	if err := applySeriesDTO(&series, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if !subject.CanManage(series.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateSeries(&series); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	var violations []models.Violation
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		violations, err = regenerateSeries(tx, &series, time.Now().UTC(), dto.ExpandUntil, forceRequested(c))
+		return err
+	})
+	if err != nil {
+		return shiftSaveError(c, err, violations)
+	}
+
+	database.GetDB().Preload("Shifts", orderByStart).First(&series, series.ID)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift series successfully updated",
+		Data:       series,
+		Violations: violations,
+	})
+}
+
+// @Summary Delete a whole shift series
+// @Description delete the series and all occurrences that have not started yet, past occurrences are kept as single shifts
+// @Tags shift-series
+// @Param id path int true "Shift series ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series/{id} [delete]
+func HandleDeleteShiftSeries(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var series models.ShiftSeries
+	if err := database.GetDB().Where("id = ?", id).First(&series).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift series not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(series.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return deleteSeries(tx, &series)
+	})
+	if err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift series successfully deleted",
+	})
+}
+
+// @Summary Expand a shift series
+// @Description create the missing occurrences of the series within a date range and return all occurrences in it
+// @Tags shift-series
+// @Param id path int true "Shift series ID"
+// @Param from query string true "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string true "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param force query bool false "Save despite overlaps, absences and blocking working time violations, administrators only"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series/{id}/expand [post]
+func HandleExpandShiftSeries(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var series models.ShiftSeries
+	if err := database.GetDB().Where("id = ?", id).First(&series).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift series not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(series.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	from, to, err := parsePeriodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	var violations []models.Violation
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		violations, err = expandSeries(tx, &series, from, to, forceRequested(c))
+		return err
+	})
+	if err != nil {
+		return shiftSaveError(c, err, violations)
+	}
+
+	var shifts []models.Shift
+	database.GetDB().Preload("User").Preload("ShiftType").
+		Where("series_id = ? AND start_time >= ? AND start_time < ?", series.ID, from, to).
+		Order("start_time").Find(&shifts)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift series successfully expanded",
+		Data:       shifts,
+		Violations: violations,
+	})
+}
+
+// @Summary Update an occurrence of a shift series
+// @Description update only this occurrence, this and all following occurrences, or the whole series
+// @Tags shift-series
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift series ID"
+// @Param shiftId path int true "Shift ID of the occurrence"
+// @Param scope query string true "this, following or all"
+// @Param shift body CreateShiftDTO true "Occurrence update data"
+// @Param force query bool false "Save despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series/{id}/occurrences/{shiftId} [put]
+func HandleUpdateShiftOccurrence(c *fiber.Ctx) error {
+	series, occurrence, errResp := loadOccurrence(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+
+	dto := new(CreateShiftDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	updated := models.Shift{}
+	if err := applyShiftDTO(&updated, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(updated.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := checkShiftTimes(&updated); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	force := forceRequested(c)
+	loc := config.Location()
+	var violations []models.Violation
+	var err error
+
+	switch c.Query("scope") {
+	case scopeThis:
+		err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+			series.ExceptionDates = append(series.ExceptionDates, occurrence.StartTime.In(loc).Format("2006-01-02"))
+			if err := tx.Omit("Shifts").Save(series).Error; err != nil {
+				return err
+			}
+
+			occurrence.SeriesID = nil
+			occurrence.StartTime = updated.StartTime
+			occurrence.EndTime = updated.EndTime
+			occurrence.Description = updated.Description
+			occurrence.BreakMinutes = updated.BreakMinutes
+			occurrence.UserID = updated.UserID
+			occurrence.DepartmentID = updated.DepartmentID
+			occurrence.ShiftTypeID = updated.ShiftTypeID
+			occurrence.ClaimMode = updated.ClaimMode
+			occurrence.QualificationID = updated.QualificationID
+
+			var err error
+			violations, err = saveShifts(tx, []*models.Shift{occurrence}, force)
+			return err
+		})
+
+	case scopeFollowing:
+		err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			violations, err = splitSeries(tx, series, occurrence, &updated, force)
+			return err
+		})
+
+	case scopeAll:
+		offset := updated.StartTime.Sub(occurrence.StartTime)
+		series.StartTime = series.StartTime.Add(offset).UTC()
+		series.EndTime = series.StartTime.Add(updated.EndTime.Sub(updated.StartTime))
+		series.Description = updated.Description
+		series.BreakMinutes = updated.BreakMinutes
+		series.UserID = updated.UserID
+		series.DepartmentID = updated.DepartmentID
+		series.ShiftTypeID = updated.ShiftTypeID
+		err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			violations, err = regenerateSeries(tx, series, time.Now().UTC(), nil, force)
+			return err
+		})
+
+	default:
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Scope must be this, following or all",
+		})
+	}
+
+	if err != nil {
+		return shiftSaveError(c, err, violations)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift occurrence successfully updated",
+		Violations: violations,
+	})
+}
+
+// @Summary Delete an occurrence of a shift series
+// @Description delete only this occurrence, this and all following occurrences, or the whole series
+// @Tags shift-series
+// @Param id path int true "Shift series ID"
+// @Param shiftId path int true "Shift ID of the occurrence"
+// @Param scope query string true "this, following or all"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-series/{id}/occurrences/{shiftId} [delete]
+func HandleDeleteShiftOccurrence(c *fiber.Ctx) error {
+	series, occurrence, errResp := loadOccurrence(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+
+	loc := config.Location()
+	var err error
+
+	switch c.Query("scope") {
+	case scopeThis:
+		err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+			series.ExceptionDates = append(series.ExceptionDates, occurrence.StartTime.In(loc).Format("2006-01-02"))
+			if err := tx.Omit("Shifts").Save(series).Error; err != nil {
+				return err
+			}
+			if err := checkUnlocked(tx, *occurrence); err != nil {
+				return err
+			}
+			if err := cancelShiftEvents(tx, *occurrence, nil); err != nil {
+				return err
+			}
+			return tx.Delete(occurrence).Error
+		})
+
+	case scopeFollowing:
+		err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+			rule, _ := seriesRule(series)
+			if rule.CountBefore(series.StartTime, occurrence.StartTime, loc) == 0 {
+				return deleteSeries(tx, series)
+			}
+			if err := truncateSeries(tx, series, occurrence.StartTime); err != nil {
+				return err
+			}
+			return tx.Omit("Shifts").Save(series).Error
+		})
+
+	case scopeAll:
+		err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+			return deleteSeries(tx, series)
+		})
+
+	default:
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Scope must be this, following or all",
+		})
+	}
+
+	if err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift occurrence successfully deleted",
+	})
+}
+
+type errorResponse struct {
+	status int
+	body   models.APIResponse
+}
+
+// loadOccurrence loads the series and the occurrence addressed by the id and
+// shiftId params if the current user manages the department of the series
+func loadOccurrence(c *fiber.Ctx) (*models.ShiftSeries, *models.Shift, *errorResponse) {
+	var series models.ShiftSeries
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&series).Error; err != nil {
+		return nil, nil, &errorResponse{404, models.APIResponse{Success: false, Error: "Shift series not found"}}
+	}
+	if !middleware.CurrentSubject(c).CanManage(series.DepartmentID) {
+		return nil, nil, &errorResponse{403, models.APIResponse{Success: false, Error: "Insufficient permissions"}}
+	}
+
+	var occurrence models.Shift
+	if err := database.GetDB().Where("id = ? AND series_id = ?", c.Params("shiftId"), series.ID).First(&occurrence).Error; err != nil {
+		return nil, nil, &errorResponse{404, models.APIResponse{Success: false, Error: "Shift occurrence not found"}}
+	}
+	return &series, &occurrence, nil
+}
+
+// applySeriesDTO copies the DTO onto the series, resolving the times of the
+// first occurrence from a referenced shift type
+func applySeriesDTO(series *models.ShiftSeries, dto *CreateShiftSeriesDTO) error {
+	first := models.Shift{
+		StartTime:    dto.StartTime.UTC(),
+		EndTime:      dto.EndTime.UTC(),
+		BreakMinutes: dto.BreakMinutes,
+		DepartmentID: dto.DepartmentID,
+		ShiftTypeID:  dto.ShiftTypeID,
+	}
+	if err := applyShiftType(&first, dto.Date); err != nil {
+		return err
+	}
+
+	series.StartTime = first.StartTime
+	series.EndTime = first.EndTime
+	series.Description = dto.Description
+	series.BreakMinutes = first.BreakMinutes
+	series.UserID = dto.UserID
+	series.DepartmentID = first.DepartmentID
+	series.ShiftTypeID = first.ShiftTypeID
+	series.Frequency = dto.Frequency
+	series.Interval = dto.Interval
+	series.Weekdays = dto.Weekdays
+	series.Until = nil
+	if dto.Until != nil {
+		until := dto.Until.UTC()
+		series.Until = &until
+	}
+	series.Count = dto.Count
+	series.ExceptionDates = dto.ExceptionDates
+	if series.Interval == 0 {
+		series.Interval = 1
+	}
+	return nil
+}
+
+func validateSeries(series *models.ShiftSeries) error {
+	if series.StartTime.IsZero() || series.EndTime.IsZero() {
+		return errors.New("Start and end time are required")
+	}
+	if !series.EndTime.After(series.StartTime) {
+		return errInvertedShift
+	}
+	if series.Until != nil && series.Count > 0 {
+		return errors.New("Until and count cannot be combined")
+	}
+	if series.Until != nil && series.Until.Before(series.StartTime) {
+		return errors.New("Until must not be before the start of the series")
+	}
+	_, err := seriesRule(series)
+	return err
+}
+
+func seriesRule(series *models.ShiftSeries) (recurrence.Rule, error) {
+	weekdays, err := recurrence.ParseWeekdays(series.Weekdays)
+	if err != nil {
+		return recurrence.Rule{}, err
+	}
+	rule := recurrence.Rule{
+		Frequency:  series.Frequency,
+		Interval:   series.Interval,
+		Weekdays:   weekdays,
+		Until:      series.Until,
+		Count:      series.Count,
+		Exceptions: series.ExceptionDates,
+	}
+	return rule, rule.Validate()
+}
+
+// expandSeries creates the occurrences of the series starting within [from, to)
+// that do not exist yet and checks them like single shifts
+func expandSeries(tx *gorm.DB, series *models.ShiftSeries, from, to time.Time, force bool) ([]models.Violation, error) {
+	rule, err := seriesRule(series)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []models.Shift
+	if err := tx.Where("series_id = ? AND start_time >= ? AND start_time < ?", series.ID, from, to).
+		Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	known := map[int64]bool{}
+	for _, shift := range existing {
+		known[shift.StartTime.Unix()] = true
+	}
+
+	duration := series.EndTime.Sub(series.StartTime)
+	var shifts []*models.Shift
+	for _, start := range rule.Between(series.StartTime, from, to, config.Location()) {
+		if known[start.Unix()] {
+			continue
+		}
+		seriesID := series.ID
+		shifts = append(shifts, &models.Shift{
+			StartTime:    start.UTC(),
+			EndTime:      start.Add(duration).UTC(),
+			Description:  series.Description,
+			BreakMinutes: series.BreakMinutes,
+			UserID:       series.UserID,
+			DepartmentID: series.DepartmentID,
+			SeriesID:     &seriesID,
+			ShiftTypeID:  series.ShiftTypeID,
+		})
+	}
+	if len(shifts) == 0 {
+		return nil, nil
+	}
+	return saveShifts(tx, shifts, force)
+}
+
+// regenerateSeries saves the series and replaces its occurrences starting at or
+// after from, keeping the previously expanded horizon unless expandUntil is given
+func regenerateSeries(tx *gorm.DB, series *models.ShiftSeries, from time.Time, expandUntil *time.Time, force bool) ([]models.Violation, error) {
+	horizon := from.Add(defaultSeriesHorizon)
+	if expandUntil != nil {
+		horizon = expandUntil.UTC()
+	} else if last := lastOccurrenceStart(tx, series.ID); last.After(horizon) {
+		horizon = last.Add(time.Second)
+	}
+
+	if err := tx.Omit("Shifts").Save(series).Error; err != nil {
+		return nil, err
+	}
+	if err := deleteShifts(tx, "series_id = ? AND start_time >= ?", series.ID, from); err != nil {
+		return nil, err
+	}
+	return expandSeries(tx, series, from, horizon, force)
+}
+
+// splitSeries ends the series before the occurrence and continues it as a new
+// series that starts with the updated occurrence
+func splitSeries(tx *gorm.DB, series *models.ShiftSeries, occurrence, updated *models.Shift, force bool) ([]models.Violation, error) {
+	loc := config.Location()
+	rule, err := seriesRule(series)
+	if err != nil {
+		return nil, err
+	}
+
+	before := rule.CountBefore(series.StartTime, occurrence.StartTime, loc)
+	horizon := occurrence.StartTime.Add(defaultSeriesHorizon)
+	if last := lastOccurrenceStart(tx, series.ID); last.After(horizon) {
+		horizon = last.Add(time.Second)
+	}
+	horizon = horizon.Add(updated.StartTime.Sub(occurrence.StartTime))
+
+	following := *series
+	following.ID = 0
+	following.CreatedAt = time.Time{}
+	following.UpdatedAt = time.Time{}
+	following.StartTime = updated.StartTime
+	following.EndTime = updated.EndTime
+	following.Description = updated.Description
+	following.BreakMinutes = updated.BreakMinutes
+	following.UserID = updated.UserID
+	following.DepartmentID = updated.DepartmentID
+	following.ShiftTypeID = updated.ShiftTypeID
+	if series.Count > 0 {
+		following.Count = series.Count - before
+	}
+	following.ExceptionDates = nil
+	occurrenceDate := occurrence.StartTime.In(loc).Format("2006-01-02")
+	for _, date := range series.ExceptionDates {
+		if date > occurrenceDate {
+			following.ExceptionDates = append(following.ExceptionDates, date)
+		}
+	}
+
+	if before == 0 {
+		if err := deleteSeries(tx, series); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := truncateSeries(tx, series, occurrence.StartTime); err != nil {
+			return nil, err
+		}
+		if err := tx.Omit("Shifts").Save(series).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Omit("Shifts").Create(&following).Error; err != nil {
+		return nil, err
+	}
+	return expandSeries(tx, &following, following.StartTime, horizon, force)
+}
+
+// truncateSeries lets the series end right before the given start and removes
+// the occurrences from then on
+func truncateSeries(tx *gorm.DB, series *models.ShiftSeries, start time.Time) error {
+	until := start.Add(-time.Second).UTC()
+	series.Until = &until
+	series.Count = 0
+	return deleteShifts(tx, "series_id = ? AND start_time >= ?", series.ID, start)
+}
+
+// deleteSeries removes the series and its future occurrences and keeps the past ones as single shifts
+func deleteSeries(tx *gorm.DB, series *models.ShiftSeries) error {
+	now := time.Now().UTC()
+	if err := deleteShifts(tx, "series_id = ? AND start_time >= ?", series.ID, now); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Shift{}).Where("series_id = ?", series.ID).Update("series_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Delete(series).Error
+}
+
+// deleteShifts removes the shifts matching the condition unless one of them lies in a locked plan period
+func deleteShifts(tx *gorm.DB, query string, args ...interface{}) error {
+	var shifts []models.Shift
+	if err := tx.Where(query, args...).Find(&shifts).Error; err != nil {
+		return err
+	}
+	if err := checkUnlocked(tx, shifts...); err != nil {
+		return err
+	}
+	for _, shift := range shifts {
+		if err := cancelShiftEvents(tx, shift, nil); err != nil {
+			return err
+		}
+	}
+	return tx.Where(query, args...).Delete(&models.Shift{}).Error
+}
+
+func lastOccurrenceStart(tx *gorm.DB, seriesID uint) time.Time {
+	var starts []time.Time
+	tx.Model(&models.Shift{}).Where("series_id = ?", seriesID).Order("start_time desc").Limit(1).Pluck("start_time", &starts)
+	if len(starts) == 0 {
+		return time.Time{}
+	}
+	return starts[0]
+}
+
+func orderByStart(db *gorm.DB) *gorm.DB {
+	return db.Order("start_time")
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// @Summary Get all shift types
+// @Description fetch shift templates, optionally filtered by department
+// @Tags shift-types
+// @Accept */*
+// @Produce json
+// @Param department_id query int false "Department ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-types [get]
+func HandleAllShiftTypes(c *fiber.Ctx) error {
+	query := database.GetDB().Order("department_id, start_time")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("department_id IN ?", subject.Departments())
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+
+	var shiftTypes []models.ShiftType
+	result := query.Find(&shiftTypes)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift types successfully retrieved",
+		Data:    shiftTypes,
+	})
+}
+
+type CreateShiftTypeDTO struct {
+	DepartmentID      uint   `json:"department_id"`
+	Name              string `json:"name" example:"Frühschicht"`
+	Color             string `json:"color" example:"#ffcc00"`
+	StartTime         string `json:"start_time" example:"06:00"`
+	EndTime           string `json:"end_time" example:"14:00"`
+	BreakMinutes      uint   `json:"break_minutes" example:"30"`
+	RequiredHeadcount uint   `json:"required_headcount" example:"2"`
+}
+
+// @Summary Create a shift type
+// @Description create new shift template for a department
+// @Tags shift-types
+// @Accept json
+// @Produce json
+// @Param shiftType body CreateShiftTypeDTO true "Shift type to create"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-types [post]
+func HandleCreateShiftType(c *fiber.Ctx) error {
+	dto := new(CreateShiftTypeDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	shiftType := models.ShiftType{}
+	applyShiftTypeDTO(&shiftType, dto)
+	if !middleware.CurrentSubject(c).CanManage(shiftType.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateShiftType(&shiftType); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result := database.GetDB().Create(&shiftType)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift type successfully created",
+		Data:    shiftType,
+	})
+}
+
+// @Summary Get a single shift type
+// @Description fetch shift type by ID
+// @Tags shift-types
+// @Param id path int true "Shift type ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-types/{id} [get]
+func HandleGetOneShiftType(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var shiftType models.ShiftType
+	if err := database.GetDB().Where("id = ?", id).First(&shiftType).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift type not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanView(shiftType.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift type successfully retrieved",
+		Data:    shiftType,
+	})
+}
+
+// @Summary Update a shift type
+// @Description update shift type by ID, existing shifts keep their times
+// @Tags shift-types
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift type ID"
+// @Param shiftType body CreateShiftTypeDTO true "Shift type update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-types/{id} [put]
+func HandleUpdateShiftType(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var shiftType models.ShiftType
+	if err := database.GetDB().Where("id = ?", id).First(&shiftType).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift type not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManage(shiftType.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(CreateShiftTypeDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	applyShiftTypeDTO(&shiftType, dto)
+	if !subject.CanManage(shiftType.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateShiftType(&shiftType); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Save(&shiftType).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift type successfully updated",
+		Data:    shiftType,
+	})
+}
+
+// @Summary Delete a shift type
+// @Description delete shift type by ID, shifts of this type become untyped
+// @Tags shift-types
+// @Param id path int true "Shift type ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shift-types/{id} [delete]
+func HandleDeleteShiftType(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var shiftType models.ShiftType
+	if err := database.GetDB().Where("id = ?", id).First(&shiftType).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift type not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(shiftType.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	database.GetDB().Model(&models.Shift{}).Where("shift_type_id = ?", id).Update("shift_type_id", nil)
+	database.GetDB().Model(&models.ShiftSeries{}).Where("shift_type_id = ?", id).Update("shift_type_id", nil)
+	result := database.GetDB().Delete(&shiftType)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift type successfully deleted",
+	})
+}
+
+func applyShiftTypeDTO(shiftType *models.ShiftType, dto *CreateShiftTypeDTO) {
+	shiftType.DepartmentID = dto.DepartmentID
+	shiftType.Name = dto.Name
+	shiftType.Color = dto.Color
+	shiftType.StartTime = dto.StartTime
+	shiftType.EndTime = dto.EndTime
+	shiftType.BreakMinutes = dto.BreakMinutes
+	shiftType.RequiredHeadcount = dto.RequiredHeadcount
+}
+
+func validateShiftType(shiftType *models.ShiftType) error {
+	if shiftType.Name == "" {
+		return errors.New("Name is required")
+	}
+	if _, err := models.ParseClock(shiftType.StartTime); err != nil {
+		return err
+	}
+	if _, err := models.ParseClock(shiftType.EndTime); err != nil {
+		return err
+	}
+	if err := database.GetDB().First(&models.Department{}, shiftType.DepartmentID).Error; err != nil {
+		return errors.New("Invalid department ID")
+	}
+	return nil
+}
+
+// applyShiftType links the shift to its shift type. Missing times are taken from
+// the template on the given date, missing break and department from the template.
+// The shift type must belong to the department of the shift.
+func applyShiftType(shift *models.Shift, date string) error {
+	if shift.ShiftTypeID == nil {
+		return nil
+	}
+
+	var shiftType models.ShiftType
+	if err := database.GetDB().First(&shiftType, *shift.ShiftTypeID).Error; err != nil {
+		return errors.New("Invalid shift type ID")
+	}
+
+	if shift.StartTime.IsZero() && shift.EndTime.IsZero() {
+		loc := config.Location()
+		day, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return errors.New("Either start and end time or a date (YYYY-MM-DD) are required")
+		}
+		start, end, err := shiftType.Span(day, loc)
+		if err != nil {
+			return err
+		}
+		shift.StartTime = start.UTC()
+		shift.EndTime = end.UTC()
+	}
+	if shift.BreakMinutes == 0 {
+		shift.BreakMinutes = shiftType.BreakMinutes
+	}
+	if shift.DepartmentID == 0 {
+		shift.DepartmentID = shiftType.DepartmentID
+	} else if shift.DepartmentID != shiftType.DepartmentID {
+		return errors.New("Shift type belongs to another department")
+	}
+	return nil
+}
@@ -0,0 +1,623 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+	"gorm.io/gorm"
+)
+
+// @Summary Get all shifts
+// @Description fetch shifts, optionally filtered by date range, user and department; shifts of draft plan periods are only listed for managers
+// @Tags shifts
+// @Accept */*
+// @Produce json
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param user_id query int false "User ID"
+// @Param department_id query int false "Department ID"
+// @Param series_id query int false "Shift series ID"
+// @Param shift_type_id query int false "Shift type ID"
+// @Param open query bool false "Only open shifts without user"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts [get]
+func HandleAllShifts(c *fiber.Ctx) error {
+	query, err := shiftListQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	var shifts []models.Shift
+	result := query.Find(&shifts)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shifts successfully retrieved",
+		Data:    shifts,
+	})
+}
+
+type CreateShiftDTO struct {
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	Description  string    `json:"description"`
+	BreakMinutes uint      `json:"break_minutes"`
+	UserID       uint      `json:"user_id"`
+	DepartmentID uint      `json:"department_id"`
+	ShiftTypeID  *uint     `json:"shift_type_id"`
+	Date         string    `json:"date" example:"2024-05-06"` // used with shift_type_id instead of start and end time
+	// only for open shifts without user
+	ClaimMode       string `json:"claim_mode" example:"approval"` // first_come or approval (default)
+	QualificationID *uint  `json:"qualification_id"`
+}
+
+// @Summary Create a shift
+// @Description create new shift
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param shift body CreateShiftDTO true "Shift to create"
+// @Param force query bool false "Save despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts [post]
+func HandleCreateShift(c *fiber.Ctx) error {
+	dto := new(CreateShiftDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	shift := models.Shift{}
+	if err := applyShiftDTO(&shift, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(shift.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	if err := checkShiftTimes(&shift); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	violations, err := saveShift(&shift, forceRequested(c))
+	if err != nil {
+		return shiftSaveError(c, err, violations)
+	}
+
+	database.GetDB().Preload("User").Preload("ShiftType").First(&shift, shift.ID)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift successfully created",
+		Data:       shift,
+		Violations: violations,
+	})
+}
+
+// @Summary Get a single shift
+// @Description fetch shift by ID
+// @Tags shifts
+// @Param id path int true "Shift ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts/{id} [get]
+func HandleGetOneShift(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var shift models.Shift
+	if err := database.GetDB().Preload("User").Preload("ShiftType").Where("id = ?", id).First(&shift).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift not found",
+		})
+	}
+	if !canViewShift(c, &shift) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift successfully retrieved",
+		Data:    shift,
+	})
+}
+
+// @Summary Update a shift
+// @Description update shift by ID
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param id path int true "Shift ID"
+// @Param shift body CreateShiftDTO true "Shift update data"
+// @Param force query bool false "Save despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=ShiftConflictDTO}
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts/{id} [put]
+func HandleUpdateShift(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var shift models.Shift
+	if err := database.GetDB().Where("id = ?", id).First(&shift).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManage(shift.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(CreateShiftDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	if err := applyShiftDTO(&shift, dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if !subject.CanManage(shift.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	if err := checkShiftTimes(&shift); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	violations, err := saveShift(&shift, forceRequested(c))
+	if err != nil {
+		return shiftSaveError(c, err, violations)
+	}
+
+	database.GetDB().Preload("User").Preload("ShiftType").First(&shift, shift.ID)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Shift successfully updated",
+		Data:       shift,
+		Violations: violations,
+	})
+}
+
+// @Summary Delete a shift
+// @Description delete shift by ID
+// @Tags shifts
+// @Param id path int true "Shift ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts/{id} [delete]
+func HandleDeleteShift(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var shift models.Shift
+	if err := database.GetDB().Where("id = ?", id).First(&shift).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(shift.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := checkUnlocked(database.GetDB(), shift); err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := cancelShiftEvents(tx, shift, nil); err != nil {
+			return err
+		}
+		return tx.Delete(&shift).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift successfully deleted",
+	})
+}
+
+// shiftListQuery builds the query for the shifts visible to the current user
+// matching the filters of the request
+func shiftListQuery(c *fiber.Ctx) (*gorm.DB, error) {
+	query := database.GetDB().Preload("User").Preload("ShiftType").Order("start_time")
+
+	// members see the plans of their departments and their own shifts elsewhere,
+	// drafts only where they plan
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("department_id IN ? OR user_id = ?", subject.Departments(), subject.User.ID).
+			Scopes(visibleShifts(subject))
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseTimeParam(from, false)
+		if err != nil {
+			return nil, errors.New("Invalid from date")
+		}
+		query = query.Where("end_time > ?", t)
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := parseTimeParam(to, true)
+		if err != nil {
+			return nil, errors.New("Invalid to date")
+		}
+		query = query.Where("start_time < ?", t)
+	}
+
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+
+	if seriesID := c.QueryInt("series_id"); seriesID > 0 {
+		query = query.Where("series_id = ?", seriesID)
+	}
+
+	if shiftTypeID := c.QueryInt("shift_type_id"); shiftTypeID > 0 {
+		query = query.Where("shift_type_id = ?", shiftTypeID)
+	}
+
+	if c.QueryBool("open") {
+		query = query.Where("user_id = 0")
+	}
+	return query, nil
+}
+
+// ShiftConflictDTO lists the shifts a rejected shift would overlap with
+type ShiftConflictDTO struct {
+	ConflictingShiftIDs []uint `json:"conflicting_shift_ids"`
+}
+
+// shiftConflictError is returned when saved shifts overlap other shifts of their user
+type shiftConflictError struct {
+	ids []uint
+}
+
+func (e *shiftConflictError) Error() string {
+	return "Shift overlaps with existing shifts of this user"
+}
+
+var errBlockingViolations = errors.New("shift violates working time rules")
+
+var errInvertedShift = errors.New("End time must be after start time")
+
+// applyShiftDTO copies the DTO onto the shift, resolving times from a referenced shift type
+func applyShiftDTO(shift *models.Shift, dto *CreateShiftDTO) error {
+	shift.StartTime = dto.StartTime.UTC()
+	shift.EndTime = dto.EndTime.UTC()
+	shift.Description = dto.Description
+	shift.BreakMinutes = dto.BreakMinutes
+	shift.UserID = dto.UserID
+	shift.DepartmentID = dto.DepartmentID
+	shift.ShiftTypeID = dto.ShiftTypeID
+	shift.ShiftType = nil
+	shift.ClaimMode = ""
+	shift.QualificationID = dto.QualificationID
+	shift.Qualification = nil
+
+	if err := applyShiftType(shift, dto.Date); err != nil {
+		return err
+	}
+	if shift.IsOpen() {
+		switch dto.ClaimMode {
+		case "":
+			shift.ClaimMode = models.ClaimApproval
+		case models.ClaimFirstCome, models.ClaimApproval:
+			shift.ClaimMode = dto.ClaimMode
+		default:
+			return errors.New("Claim mode must be first_come or approval")
+		}
+	}
+	if shift.QualificationID != nil {
+		if err := database.GetDB().First(&models.Qualification{}, *shift.QualificationID).Error; err != nil {
+			return errors.New("Invalid qualification ID")
+		}
+	}
+	if shift.StartTime.IsZero() || shift.EndTime.IsZero() {
+		return errors.New("Start and end time are required")
+	}
+	return nil
+}
+
+// checkShiftTimes rejects shifts whose end does not lie after their start
+func checkShiftTimes(shift *models.Shift) error {
+	if !shift.EndTime.After(shift.StartTime) {
+		return errInvertedShift
+	}
+	return nil
+}
+
+// forceRequested reports whether the request overrides overlaps and blocking
+// violations. Only administrators may knowingly double-book, the flag is
+// ignored for everyone else.
+func forceRequested(c *fiber.Ctx) bool {
+	return c.QueryBool("force") && middleware.CurrentSubject(c).IsAdmin()
+}
+
+// saveShift stores a single shift in its own transaction, see saveShifts
+func saveShift(shift *models.Shift, force bool) ([]models.Violation, error) {
+	var violations []models.Violation
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		violations, err = saveShifts(tx, []*models.Shift{shift}, force)
+		return err
+	})
+	return violations, err
+}
+
+// saveShifts stores the shifts within tx, then checks them for overlaps with other
+// shifts of the same user and evaluates the working time rules. Overlaps and blocking
+// violations are returned as errors so the caller rolls back, unless forced. Shifts
+// moved into or out of a locked plan period are always rejected.
+func saveShifts(tx *gorm.DB, shifts []*models.Shift, force bool) ([]models.Violation, error) {
+	for _, shift := range shifts {
+		if err := checkUnlocked(tx, *shift); err != nil {
+			return nil, err
+		}
+		if shift.ID != 0 {
+			var stored models.Shift
+			if err := tx.First(&stored, shift.ID).Error; err == nil {
+				if err := checkUnlocked(tx, stored); err != nil {
+					return nil, err
+				}
+				shift.Sequence = stored.Sequence + 1
+				if err := cancelShiftEvents(tx, stored, shift); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := tx.Omit("User", "ShiftType", "Qualification").Save(shift).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if !force {
+		conflicts := []uint{}
+		for _, shift := range shifts {
+			ids, err := findOverlappingShiftIDs(tx, shift)
+			if err != nil {
+				return nil, err
+			}
+			conflicts = append(conflicts, ids...)
+		}
+		if len(conflicts) > 0 {
+			return nil, &shiftConflictError{ids: conflicts}
+		}
+	}
+
+	violations, err := evaluateShiftRules(tx, shifts)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := checkAvailability(tx, shifts)
+	if err != nil {
+		return nil, err
+	}
+	violations = append(violations, warnings...)
+	absent, err := checkAbsences(tx, shifts)
+	if err != nil {
+		return nil, err
+	}
+	violations = append(violations, absent...)
+	holidayWarnings, err := checkHolidays(tx, shifts)
+	if err != nil {
+		return nil, err
+	}
+	violations = append(violations, holidayWarnings...)
+	if !force && rules.HasBlocking(violations) {
+		return violations, errBlockingViolations
+	}
+	return violations, nil
+}
+
+// findOverlappingShiftIDs returns the IDs of all other shifts of the same user
+// that intersect the time span of the given shift
+func findOverlappingShiftIDs(tx *gorm.DB, shift *models.Shift) ([]uint, error) {
+	ids := []uint{}
+	if shift.UserID == 0 {
+		return ids, nil
+	}
+	err := tx.Model(&models.Shift{}).
+		Where("user_id = ? AND id <> ? AND start_time < ? AND end_time > ?", shift.UserID, shift.ID, shift.EndTime, shift.StartTime).
+		Order("start_time").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// evaluateShiftRules runs the rule engine on the shifts of the affected users
+// surrounding the given shifts and returns the violations that concern them
+func evaluateShiftRules(tx *gorm.DB, shifts []*models.Shift) ([]models.Violation, error) {
+	cfg := rules.ConfigFromEnv()
+
+	var ids []uint
+	windows := map[uint][2]time.Time{}
+	for _, shift := range shifts {
+		if shift.UserID == 0 {
+			continue
+		}
+		ids = append(ids, shift.ID)
+		from, to := ruleWindow(cfg, shift.StartTime, shift.EndTime)
+		if w, ok := windows[shift.UserID]; ok {
+			if w[0].Before(from) {
+				from = w[0]
+			}
+			if w[1].After(to) {
+				to = w[1]
+			}
+		}
+		windows[shift.UserID] = [2]time.Time{from, to}
+	}
+
+	var context []models.Shift
+	userIDs := []uint{}
+	for userID, w := range windows {
+		userIDs = append(userIDs, userID)
+		var userShifts []models.Shift
+		if err := tx.Where("user_id = ? AND end_time > ? AND start_time < ?", userID, w[0], w[1]).
+			Find(&userShifts).Error; err != nil {
+			return nil, err
+		}
+		context = append(context, userShifts...)
+	}
+
+	if err := loadRuleData(tx, &cfg, userIDs); err != nil {
+		return nil, err
+	}
+	violations := rules.NewEngine(cfg).Evaluate(context)
+	return rules.Involving(violations, ids...), nil
+}
+
+// ruleWindow returns the span of shifts the rules need to judge shifts in
+// [start, end): the reference weeks of the weekly average before, the next
+// days for the rest period after and the whole months for the Minijob limit
+func ruleWindow(cfg rules.Config, start, end time.Time) (time.Time, time.Time) {
+	loc := config.Location()
+	from := start.AddDate(0, 0, -7*(cfg.ReferenceWeeks+1))
+	if local := start.In(loc); from.After(time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)) {
+		from = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	}
+	to := end.AddDate(0, 0, 2)
+	if local := end.In(loc); to.Before(time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, loc)) {
+		to = time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, loc)
+	}
+	return from, to
+}
+
+// loadRuleData adds the contracts and birth dates of the users to the rule configuration
+func loadRuleData(tx *gorm.DB, cfg *rules.Config, userIDs []uint) error {
+	contracts, err := loadContracts(tx, userIDs)
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err := tx.Select("id", "birth_date").Where("id IN ? AND birth_date <> ''", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+	cfg.Contracts = contracts
+	cfg.BirthDates = map[uint]string{}
+	for _, user := range users {
+		cfg.BirthDates[user.ID] = user.BirthDate
+	}
+	return nil
+}
+
+// canViewShift reports whether the current user may see the shift: it belongs
+// to one of their departments or is their own, and drafts only to its planners
+func canViewShift(c *fiber.Ctx, shift *models.Shift) bool {
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanView(shift.DepartmentID) && shift.UserID != subject.User.ID {
+		return false
+	}
+	return subject.CanManage(shift.DepartmentID) || !inDraftPeriod(database.GetDB(), shift)
+}
+
+// shiftSaveError turns an error of saveShifts into the matching response
+func shiftSaveError(c *fiber.Ctx, err error, violations []models.Violation) error {
+	var conflict *shiftConflictError
+	if errors.As(err, &conflict) {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   conflict.Error(),
+			Data:    ShiftConflictDTO{ConflictingShiftIDs: conflict.ids},
+		})
+	}
+	var locked *periodLockedError
+	if errors.As(err, &locked) {
+		return c.Status(423).JSON(models.APIResponse{
+			Success: false,
+			Error:   locked.Error(),
+			Data:    locked.period,
+		})
+	}
+	if errors.Is(err, errBlockingViolations) {
+		return c.Status(422).JSON(models.APIResponse{
+			Success:    false,
+			Error:      "Shift violates planning rules",
+			Violations: violations,
+		})
+	}
+	return c.Status(500).JSON(models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
+
+// parseTimeParam parses a query value as RFC3339 timestamp or as local date.
+// With endOfDay a plain date is moved to the start of the following day.
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, config.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.UTC(), nil
+}
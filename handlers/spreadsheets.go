@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/auth"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/spreadsheet"
+	"gorm.io/gorm"
+)
+
+// Outcomes of an imported row
+const (
+	rowValid   = "valid"   // dry run only
+	rowCreated = "created" // the row has been imported
+	rowInvalid = "invalid" // the row has errors, nothing is imported
+)
+
+// userColumns are the fields of the user import with the headers recognized
+// by default, the first one is used for exports
+var userColumns = []struct {
+	field    string
+	headers  []string
+	required bool
+}{
+	{"first_name", []string{"Vorname", "first_name"}, true},
+	{"last_name", []string{"Nachname", "last_name"}, true},
+	{"email", []string{"E-Mail", "email", "Email", "E-Mail-Adresse"}, true},
+	{"password", []string{"Passwort", "password", "Kennwort"}, true},
+	{"color", []string{"Farbe", "color"}, false},
+	{"is_admin", []string{"Administrator", "is_admin", "Admin"}, false},
+	{"weekly_hours", []string{"Wochenstunden", "weekly_hours"}, false},
+	{"birth_date", []string{"Geburtsdatum", "birth_date"}, false},
+	{"personnel_number", []string{"Personalnummer", "personnel_number", "Personalnr."}, false},
+	{"departments", []string{"Abteilungen", "departments", "Abteilung"}, false},
+	{"qualifications", []string{"Qualifikationen", "qualifications", "Qualifikation"}, false},
+}
+
+// UserImportRowDTO is the outcome of a single row, numbered like in the spreadsheet
+type UserImportRowDTO struct {
+	Row    int          `json:"row" example:"2"`
+	Status string       `json:"status" example:"valid"`
+	Errors []string     `json:"errors,omitempty"`
+	User   *models.User `json:"user,omitempty"`
+}
+
+// UserImportDTO is the validation report of a user import
+type UserImportDTO struct {
+	DryRun  bool               `json:"dry_run"`
+	Columns map[string]string  `json:"columns"` // field to header of the file
+	Valid   int                `json:"valid"`
+	Invalid int                `json:"invalid"`
+	Created int                `json:"created"`
+	Rows    []UserImportRowDTO `json:"rows"`
+}
+
+// @Summary Import users
+// @Description create users from a CSV (semicolon, comma or tab separated, UTF-8 or Windows-1252) or XLSX file. Columns are recognized by their German or field name headers (Vorname, Nachname, E-Mail, Passwort, Farbe, Administrator, Wochenstunden, Geburtsdatum, Personalnummer, Abteilungen, Qualifikationen); the mapping assigns other headers to fields. Dates may be given as dd.mm.yyyy, departments and qualifications as comma separated names. Every row is validated; the users are only created if all rows are valid, with dry_run nothing is created.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param mapping formData string false "JSON object of field to header, e.g. {\"first_name\":\"Name\",\"email\":\"Mail\"}"
+// @Param dry_run query bool false "Only validate the rows"
+// @Success 200 {object} models.APIResponse{data=UserImportDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse{data=UserImportDTO}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/import [post]
+func HandleImportUsers(c *fiber.Ctx) error {
+	data, err := readUpload(c, "file")
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	table, err := spreadsheet.Read(data)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	report := UserImportDTO{DryRun: c.QueryBool("dry_run"), Rows: []UserImportRowDTO{}}
+	columns, err := userImportColumns(table, c.FormValue("mapping"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	report.Columns = map[string]string{}
+	for field, column := range columns {
+		report.Columns[field] = table.Header[column]
+	}
+
+	var departments []models.Department
+	var qualifications []models.Qualification
+	var emails []string
+	if err := database.GetDB().Find(&departments).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err := database.GetDB().Find(&qualifications).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err := database.GetDB().Model(&models.User{}).Pluck("LOWER(email)", &emails).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	taken := map[string]int{}
+	for _, email := range emails {
+		taken[email] = 0
+	}
+
+	var users []*models.User
+	var passwords []string
+	for i, row := range table.Rows {
+		if spreadsheet.IsEmpty(row) {
+			continue
+		}
+		entry := UserImportRowDTO{Row: table.HeaderRow + i + 1}
+		cell := func(field string) string {
+			column, ok := columns[field]
+			if !ok {
+				return ""
+			}
+			return spreadsheet.Cell(row, column)
+		}
+		user, password, errs := userFromRow(cell, departments, qualifications)
+		if user.Email != "" {
+			if first, ok := taken[strings.ToLower(user.Email)]; ok {
+				if first == 0 {
+					errs = append(errs, "E-mail "+user.Email+" is already in use")
+				} else {
+					errs = append(errs, "E-mail "+user.Email+" is repeated from row "+strconv.Itoa(first))
+				}
+			} else {
+				taken[strings.ToLower(user.Email)] = entry.Row
+			}
+		}
+
+		entry.User = user
+		if len(errs) > 0 {
+			entry.Status, entry.Errors = rowInvalid, errs
+			report.Invalid++
+		} else {
+			entry.Status = rowValid
+			report.Valid++
+			users = append(users, user)
+			passwords = append(passwords, password)
+		}
+		report.Rows = append(report.Rows, entry)
+	}
+
+	if report.Invalid > 0 && !report.DryRun {
+		return c.Status(422).JSON(models.APIResponse{
+			Success: false,
+			Error:   "The file contains invalid rows, no user has been imported",
+			Data:    report,
+		})
+	}
+	if report.DryRun {
+		return c.JSON(models.APIResponse{
+			Success: true,
+			Message: "Users successfully validated",
+			Data:    report,
+		})
+	}
+
+	for i, user := range users {
+		if user.Password, err = auth.HashPassword(passwords[i]); err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+	}
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	for i := range report.Rows {
+		report.Rows[i].Status = rowCreated
+	}
+	report.Created, report.Valid = report.Valid, 0
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Users successfully imported",
+		Data:    report,
+	})
+}
+
+// @Summary Export users
+// @Description download the users visible to the current user as CSV (semicolon separated, UTF-8 with BOM) or XLSX, in the columns of the user import
+// @Tags users
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv (default) or xlsx"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/export [get]
+func HandleExportUsers(c *fiber.Ctx) error {
+	query := database.GetDB().Preload("Departments").Preload("Qualifications").Order("last_name, first_name")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		members := database.GetDB().Model(&models.UserDepartment{}).Select("user_id").Where("department_id IN ?", subject.Departments())
+		query = query.Where("id = ? OR id IN (?)", subject.User.ID, members)
+	}
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	var table spreadsheet.Table
+	for _, column := range userColumns {
+		if column.field != "password" {
+			table.Header = append(table.Header, column.headers[0])
+		}
+	}
+	for _, user := range users {
+		var departments, qualifications []string
+		for _, department := range user.Departments {
+			departments = append(departments, department.Name)
+		}
+		for _, qualification := range user.Qualifications {
+			qualifications = append(qualifications, qualification.Name)
+		}
+		birthDate := ""
+		if t, err := time.Parse("2006-01-02", user.BirthDate); err == nil {
+			birthDate = spreadsheet.FormatDate(t)
+		}
+		table.Rows = append(table.Rows, []string{
+			user.FirstName,
+			user.LastName,
+			user.Email,
+			user.Color,
+			spreadsheet.FormatBool(user.IsAdmin),
+			spreadsheet.FormatNumber(user.WeeklyHours),
+			birthDate,
+			user.PersonnelNumber,
+			strings.Join(departments, ", "),
+			strings.Join(qualifications, ", "),
+		})
+	}
+	return sendSpreadsheet(c, "Mitarbeiter", table)
+}
+
+// @Summary Export departments
+// @Description download the departments visible to the current user with one row per member as CSV (semicolon separated, UTF-8 with BOM) or XLSX
+// @Tags departments
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv (default) or xlsx"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/export [get]
+func HandleExportDepartments(c *fiber.Ctx) error {
+	query := database.GetDB().Order("name")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("id IN ?", subject.Departments())
+	}
+	var departments []models.Department
+	if err := query.Find(&departments).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	ids := []uint{}
+	for _, department := range departments {
+		ids = append(ids, department.ID)
+	}
+	var memberships []models.UserDepartment
+	if err := database.GetDB().Where("department_id IN ?", ids).Find(&memberships).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	userIDs := []uint{}
+	for _, membership := range memberships {
+		userIDs = append(userIDs, membership.UserID)
+	}
+	var users []models.User
+	if err := database.GetDB().Where("id IN ?", userIDs).Order("last_name, first_name").Find(&users).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	roles := map[[2]uint]string{}
+	for _, membership := range memberships {
+		roles[[2]uint{membership.DepartmentID, membership.UserID}] = membership.Role
+	}
+
+	table := spreadsheet.Table{Header: []string{"Abteilung", "Beschreibung", "Farbe", "Bundesland", "Vorname", "Nachname", "E-Mail", "Rolle"}}
+	for _, department := range departments {
+		row := []string{department.Name, department.Description, department.Color, department.State}
+		members := 0
+		for _, user := range users {
+			if role, ok := roles[[2]uint{department.ID, user.ID}]; ok {
+				table.Rows = append(table.Rows, append(row[:4:4], user.FirstName, user.LastName, user.Email, role))
+				members++
+			}
+		}
+		if members == 0 {
+			table.Rows = append(table.Rows, append(row, "", "", "", ""))
+		}
+	}
+	return sendSpreadsheet(c, "Abteilungen", table)
+}
+
+// @Summary Export shifts
+// @Description download the shifts visible to the current user as CSV (semicolon separated, UTF-8 with BOM, dd.mm.yyyy dates) or XLSX
+// @Tags shifts
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv (default) or xlsx"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param user_id query int false "User ID"
+// @Param department_id query int false "Department ID"
+// @Param series_id query int false "Shift series ID"
+// @Param shift_type_id query int false "Shift type ID"
+// @Param open query bool false "Only open shifts without user"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /shifts/export [get]
+func HandleExportShifts(c *fiber.Ctx) error {
+	query, err := shiftListQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	var shifts []models.Shift
+	if err := query.Find(&shifts).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	var departmentList []models.Department
+	if err := database.GetDB().Find(&departmentList).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	departments := map[uint]string{}
+	for _, department := range departmentList {
+		departments[department.ID] = department.Name
+	}
+
+	loc := config.Location()
+	table := spreadsheet.Table{Header: []string{"Datum", "Beginn", "Ende", "Pause (Min.)", "Stunden", "Abteilung", "Schichtart",
+		"Vorname", "Nachname", "E-Mail", "Beschreibung"}}
+	for _, shift := range shifts {
+		start, end := shift.StartTime.In(loc), shift.EndTime.In(loc)
+		hours := shift.EndTime.Sub(shift.StartTime).Hours() - float64(shift.BreakMinutes)/60
+		row := []string{spreadsheet.FormatDate(start), start.Format("15:04"), end.Format("15:04"), strconv.Itoa(int(shift.BreakMinutes)),
+			spreadsheet.FormatNumber(hours), departments[shift.DepartmentID], "", "", "", "", shift.Description}
+		if shift.ShiftType != nil {
+			row[6] = shift.ShiftType.Name
+		}
+		if shift.User != nil {
+			row[7], row[8], row[9] = shift.User.FirstName, shift.User.LastName, shift.User.Email
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return sendSpreadsheet(c, "Schichten", table)
+}
+
+// sendSpreadsheet writes the table in the requested format as download
+func sendSpreadsheet(c *fiber.Ctx, name string, table spreadsheet.Table) error {
+	format := strings.ToLower(c.Query("format", spreadsheet.CSV))
+	if format != spreadsheet.CSV && format != spreadsheet.XLSX {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Format must be csv or xlsx",
+		})
+	}
+	data, err := spreadsheet.Write(format, name, table)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	contentType, extension := spreadsheet.ContentType(format)
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+strings.ToLower(name)+"-"+time.Now().In(config.Location()).Format("2006-01-02")+extension+`"`)
+	return c.Send(data)
+}
+
+// userImportColumns finds the column of every field, from the mapping or by the default headers
+func userImportColumns(table spreadsheet.Table, mapping string) (map[string]int, error) {
+	headers := map[string]string{}
+	if mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &headers); err != nil {
+			return nil, errors.New("Invalid mapping, expected a JSON object of field to header")
+		}
+	}
+	known := map[string]bool{}
+	columns := map[string]int{}
+	for _, column := range userColumns {
+		known[column.field] = true
+		index := table.Column(column.headers...)
+		if header, ok := headers[column.field]; ok {
+			if index = table.Column(header); index < 0 {
+				return nil, errors.New("Column " + header + " of " + column.field + " not found")
+			}
+		}
+		if index >= 0 {
+			columns[column.field] = index
+		} else if column.required {
+			return nil, errors.New("Column " + column.headers[0] + " (" + column.field + ") is required")
+		}
+	}
+	for field := range headers {
+		if !known[field] {
+			return nil, errors.New("Unknown field " + field)
+		}
+	}
+	return columns, nil
+}
+
+// userFromRow validates the cells of a row and returns the user with the
+// password to set and the errors found
+func userFromRow(cell func(field string) string, departments []models.Department, qualifications []models.Qualification) (*models.User, string, []string) {
+	var errs []string
+	user := &models.User{
+		FirstName:       cell("first_name"),
+		LastName:        cell("last_name"),
+		Email:           cell("email"),
+		Color:           cell("color"),
+		PersonnelNumber: cell("personnel_number"),
+	}
+	password := cell("password")
+	if user.FirstName == "" || user.LastName == "" {
+		errs = append(errs, "First and last name are required")
+	}
+	if !strings.Contains(user.Email, "@") {
+		errs = append(errs, "Invalid e-mail "+user.Email)
+	}
+	if len([]rune(password)) < auth.MinPasswordLength {
+		errs = append(errs, auth.ErrPasswordTooShort.Error())
+	}
+	var err error
+	if user.IsAdmin, err = spreadsheet.ParseBool(cell("is_admin")); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if value := cell("weekly_hours"); value != "" {
+		if user.WeeklyHours, err = spreadsheet.ParseNumber(value); err != nil {
+			errs = append(errs, err.Error())
+		} else if user.WeeklyHours < 0 || user.WeeklyHours > 168 {
+			errs = append(errs, "Invalid weekly hours")
+		}
+	}
+	if value := cell("birth_date"); value != "" {
+		if t, err := spreadsheet.ParseDate(value); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			user.BirthDate = t.Format("2006-01-02")
+		}
+	}
+	for _, name := range splitNames(cell("departments")) {
+		found := false
+		for _, department := range departments {
+			if strings.EqualFold(department.Name, name) {
+				user.Departments = append(user.Departments, department)
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, "Unknown department "+name)
+		}
+	}
+	for _, name := range splitNames(cell("qualifications")) {
+		found := false
+		for _, qualification := range qualifications {
+			if strings.EqualFold(qualification.Name, name) {
+				user.Qualifications = append(user.Qualifications, qualification)
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, "Unknown qualification "+name)
+		}
+	}
+	return user, password, errs
+}
+
+// splitNames splits a comma or semicolon separated list
+func splitNames(value string) []string {
+	var names []string
+	for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
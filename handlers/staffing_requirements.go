@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/coverage"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// @Summary Get all staffing requirements
+// @Description fetch staffing requirements, optionally filtered by department
+// @Tags staffing-requirements
+// @Accept */*
+// @Produce json
+// @Param department_id query int false "Department ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /staffing-requirements [get]
+func HandleAllStaffingRequirements(c *fiber.Ctx) error {
+	query := database.GetDB().Preload("Qualification").Order("department_id, weekday, start_time")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("department_id IN ?", subject.Departments())
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+
+	var requirements []models.StaffingRequirement
+	result := query.Find(&requirements)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Staffing requirements successfully retrieved",
+		Data:    requirements,
+	})
+}
+
+type CreateStaffingRequirementDTO struct {
+	DepartmentID    uint   `json:"department_id"`
+	Weekday         uint   `json:"weekday" example:"1"`
+	ShiftTypeID     *uint  `json:"shift_type_id"`
+	StartTime       string `json:"start_time" example:"06:00"`
+	EndTime         string `json:"end_time" example:"14:00"`
+	Headcount       uint   `json:"headcount" example:"3"`
+	QualificationID *uint  `json:"qualification_id"`
+}
+
+// @Summary Create a staffing requirement
+// @Description define how many people a department needs for a shift type or time slot on a weekday (1 = Monday ... 7 = Sunday)
+// @Tags staffing-requirements
+// @Accept json
+// @Produce json
+// @Param requirement body CreateStaffingRequirementDTO true "Staffing requirement to create"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /staffing-requirements [post]
+func HandleCreateStaffingRequirement(c *fiber.Ctx) error {
+	dto := new(CreateStaffingRequirementDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	requirement := models.StaffingRequirement{}
+	applyStaffingRequirementDTO(&requirement, dto)
+	if !middleware.CurrentSubject(c).CanManage(requirement.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateStaffingRequirement(&requirement); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result := database.GetDB().Create(&requirement)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Staffing requirement successfully created",
+		Data:    requirement,
+	})
+}
+
+// @Summary Update a staffing requirement
+// @Description update staffing requirement by ID
+// @Tags staffing-requirements
+// @Accept json
+// @Produce json
+// @Param id path int true "Staffing requirement ID"
+// @Param requirement body CreateStaffingRequirementDTO true "Staffing requirement update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /staffing-requirements/{id} [put]
+func HandleUpdateStaffingRequirement(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var requirement models.StaffingRequirement
+	if err := database.GetDB().Where("id = ?", id).First(&requirement).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Staffing requirement not found",
+		})
+	}
+	subject := middleware.CurrentSubject(c)
+	if !subject.CanManage(requirement.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(CreateStaffingRequirementDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	applyStaffingRequirementDTO(&requirement, dto)
+	if !subject.CanManage(requirement.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if err := validateStaffingRequirement(&requirement); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Omit("Qualification").Save(&requirement).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Staffing requirement successfully updated",
+		Data:    requirement,
+	})
+}
+
+// @Summary Delete a staffing requirement
+// @Description delete staffing requirement by ID
+// @Tags staffing-requirements
+// @Param id path int true "Staffing requirement ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /staffing-requirements/{id} [delete]
+func HandleDeleteStaffingRequirement(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var requirement models.StaffingRequirement
+	if err := database.GetDB().Where("id = ?", id).First(&requirement).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Staffing requirement not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanManage(requirement.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	result := database.GetDB().Delete(&requirement)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Staffing requirement successfully deleted",
+	})
+}
+
+type CoverageReportDTO struct {
+	DepartmentID uint            `json:"department_id"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Understaffed int             `json:"understaffed"`
+	Overstaffed  int             `json:"overstaffed"`
+	Slots        []coverage.Slot `json:"slots"`
+}
+
+// @Summary Get the staffing coverage of a department
+// @Description compare the staffing requirements of the department with its planned shifts and report under- and overstaffed slots
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Param week query string false "ISO week (YYYY-Www)"
+// @Param month query string false "Month (YYYY-MM)"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param only_gaps query bool false "Only return under- and overstaffed slots"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=CoverageReportDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/coverage [get]
+func HandleDepartmentCoverage(c *fiber.Ctx) error {
+	var department models.Department
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanView(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	from, to, err := parsePeriodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	input, err := loadCoverageInput(department.ID, from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	report := CoverageReportDTO{DepartmentID: department.ID, From: from, To: to, Slots: []coverage.Slot{}}
+	for _, slot := range coverage.Compute(input) {
+		switch slot.Status {
+		case coverage.StatusUnder:
+			report.Understaffed++
+		case coverage.StatusOver:
+			report.Overstaffed++
+		}
+		if slot.Status != coverage.StatusOK || !c.QueryBool("only_gaps") {
+			report.Slots = append(report.Slots, slot)
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Coverage successfully calculated",
+		Data:    report,
+	})
+}
+
+// loadCoverageInput collects requirements, shift types, shifts and qualifications of a department
+func loadCoverageInput(departmentID uint, from, to time.Time) (coverage.Input, error) {
+	input := coverage.Input{From: from, To: to, Location: config.Location(), Qualifications: map[uint]map[uint]bool{}}
+	db := database.GetDB()
+
+	calendar, err := departmentCalendar(db, departmentID)
+	if err != nil {
+		return input, err
+	}
+	input.Holidays = calendar
+
+	if err := db.Where("department_id = ?", departmentID).Find(&input.Requirements).Error; err != nil {
+		return input, err
+	}
+	if err := db.Where("department_id = ?", departmentID).Find(&input.ShiftTypes).Error; err != nil {
+		return input, err
+	}
+	// shifts may reach into the range from the previous day (night shifts)
+	if err := db.Where("department_id = ? AND end_time > ? AND start_time < ?", departmentID, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1)).
+		Find(&input.Shifts).Error; err != nil {
+		return input, err
+	}
+
+	var userIDs []uint
+	for _, shift := range input.Shifts {
+		userIDs = append(userIDs, shift.UserID)
+	}
+	var users []models.User
+	if err := db.Preload("Qualifications").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return input, err
+	}
+	for _, user := range users {
+		input.Qualifications[user.ID] = map[uint]bool{}
+		for _, q := range user.Qualifications {
+			input.Qualifications[user.ID][q.ID] = true
+		}
+	}
+	return input, nil
+}
+
+func applyStaffingRequirementDTO(requirement *models.StaffingRequirement, dto *CreateStaffingRequirementDTO) {
+	requirement.DepartmentID = dto.DepartmentID
+	requirement.Weekday = dto.Weekday
+	requirement.ShiftTypeID = dto.ShiftTypeID
+	requirement.StartTime = dto.StartTime
+	requirement.EndTime = dto.EndTime
+	requirement.Headcount = dto.Headcount
+	requirement.QualificationID = dto.QualificationID
+	requirement.Qualification = nil
+}
+
+func validateStaffingRequirement(requirement *models.StaffingRequirement) error {
+	if requirement.Weekday < 1 || requirement.Weekday > 7 {
+		return errors.New("Weekday must be between 1 (Monday) and 7 (Sunday)")
+	}
+	if err := database.GetDB().First(&models.Department{}, requirement.DepartmentID).Error; err != nil {
+		return errors.New("Invalid department ID")
+	}
+	if requirement.ShiftTypeID != nil {
+		var shiftType models.ShiftType
+		if err := database.GetDB().First(&shiftType, *requirement.ShiftTypeID).Error; err != nil || shiftType.DepartmentID != requirement.DepartmentID {
+			return errors.New("Invalid shift type ID")
+		}
+	} else {
+		if _, err := models.ParseClock(requirement.StartTime); err != nil {
+			return err
+		}
+		if _, err := models.ParseClock(requirement.EndTime); err != nil {
+			return err
+		}
+	}
+	if requirement.QualificationID != nil {
+		if err := database.GetDB().First(&models.Qualification{}, *requirement.QualificationID).Error; err != nil {
+			return errors.New("Invalid qualification ID")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,713 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
+)
+
+// @Summary Get all swap requests
+// @Description fetch the own swap requests and those of the own departments, optionally filtered by status, department and shift
+// @Tags swap-requests
+// @Accept */*
+// @Produce json
+// @Param status query string false "Status (open, pending, approved, rejected, cancelled)"
+// @Param department_id query int false "Department ID"
+// @Param shift_id query int false "Shift ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests [get]
+func HandleAllSwapRequests(c *fiber.Ctx) error {
+	query := database.GetDB().Preload("Shift").Preload("Offers.Shift").Order("created_at desc")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		query = query.Where("department_id IN ? OR requester_id = ?", subject.Departments(), subject.User.ID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		query = query.Where("department_id = ?", departmentID)
+	}
+	if shiftID := c.QueryInt("shift_id"); shiftID > 0 {
+		query = query.Where("shift_id = ?", shiftID)
+	}
+
+	var requests []models.SwapRequest
+	result := query.Find(&requests)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Swap requests successfully retrieved",
+		Data:    requests,
+	})
+}
+
+type CreateSwapRequestDTO struct {
+	ShiftID uint   `json:"shift_id"`
+	Type    string `json:"type" example:"swap"`
+	Note    string `json:"note"`
+}
+
+// @Summary Offer a shift for swap or giveaway
+// @Description offer one of the own upcoming shifts to the colleagues of its department, either in exchange for one of theirs or to give it away
+// @Tags swap-requests
+// @Accept json
+// @Produce json
+// @Param request body CreateSwapRequestDTO true "Shift to offer"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests [post]
+func HandleCreateSwapRequest(c *fiber.Ctx) error {
+	dto := new(CreateSwapRequestDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	if dto.Type != models.SwapTypeSwap && dto.Type != models.SwapTypeGiveaway {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Type must be swap or giveaway",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	shift, errResp := loadSwappableShift(dto.ShiftID, user.ID)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if err := checkUnlocked(database.GetDB(), *shift); err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+
+	var active int64
+	database.GetDB().Model(&models.SwapRequest{}).
+		Where("shift_id = ? AND status IN ?", shift.ID, []string{models.SwapOpen, models.SwapPending}).Count(&active)
+	if active > 0 {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Shift is already offered",
+		})
+	}
+
+	request := models.SwapRequest{
+		Type:         dto.Type,
+		Status:       models.SwapOpen,
+		ShiftID:      shift.ID,
+		RequesterID:  user.ID,
+		DepartmentID: shift.DepartmentID,
+		Note:         dto.Note,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Shift", "Offers").Create(&request).Error; err != nil {
+			return err
+		}
+		var members []uint
+		if err := tx.Model(&models.UserDepartment{}).Where("department_id = ?", request.DepartmentID).
+			Pluck("user_id", &members).Error; err != nil {
+			return err
+		}
+		return notify(tx, members, user.ID, models.Notification{
+			Type:          models.NotificationSwapRequested,
+			Message:       user.FirstName + " " + user.LastName + " offers the shift on " + formatShiftDay(shift) + " for " + request.Type,
+			SwapRequestID: &request.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return swapRequestResponse(c, request.ID, "Swap request successfully created")
+}
+
+// @Summary Get a single swap request
+// @Description fetch swap request by ID including its offers
+// @Tags swap-requests
+// @Param id path int true "Swap request ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id} [get]
+func HandleGetOneSwapRequest(c *fiber.Ctx) error {
+	request, errResp := loadSwapRequest(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	return swapRequestResponse(c, request.ID, "Swap request successfully retrieved")
+}
+
+// @Summary Cancel a swap request
+// @Description withdraw an open or pending swap request, possible for the requester and the managers of the department
+// @Tags swap-requests
+// @Param id path int true "Swap request ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/cancel [post]
+func HandleCancelSwapRequest(c *fiber.Ctx) error {
+	request, errResp := loadSwapRequest(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	subject := middleware.CurrentSubject(c)
+	if request.RequesterID != subject.User.ID && !subject.CanManage(request.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := moveSwapRequest(tx, request, []string{models.SwapOpen, models.SwapPending}, map[string]interface{}{
+			"status": models.SwapCancelled,
+		}); err != nil {
+			return err
+		}
+		return tx.Model(&models.SwapOffer{}).
+			Where("swap_request_id = ? AND status IN ?", request.ID, []string{models.OfferOffered, models.OfferAccepted}).
+			Update("status", models.OfferDeclined).Error
+	})
+	if err != nil {
+		return swapError(c, err, nil)
+	}
+	return swapRequestResponse(c, request.ID, "Swap request successfully cancelled")
+}
+
+type CreateSwapOfferDTO struct {
+	ShiftID *uint  `json:"shift_id"` // own shift offered in return, required for swaps
+	Note    string `json:"note"`
+}
+
+// @Summary Answer a swap request
+// @Description take over the offered shift or counter-offer with one of the own shifts of the department, employees and managers of the department only; taking over a giveaway directly passes it on to the manager, counter-offers have to be accepted by the requester first
+// @Tags swap-requests
+// @Accept json
+// @Produce json
+// @Param id path int true "Swap request ID"
+// @Param offer body CreateSwapOfferDTO true "Offer"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/offers [post]
+func HandleCreateSwapOffer(c *fiber.Ctx) error {
+	request, errResp := loadSwapRequest(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	user := middleware.CurrentUser(c)
+	if request.RequesterID == user.ID {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "You cannot answer your own swap request",
+		})
+	}
+	if !middleware.CurrentSubject(c).CanWork(request.DepartmentID) {
+		return middleware.Forbidden(c)
+	}
+	if request.Status != models.SwapOpen {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Swap request is no longer open",
+		})
+	}
+
+	dto := new(CreateSwapOfferDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	if request.Type == models.SwapTypeSwap && dto.ShiftID == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "A shift in return is required for swaps",
+		})
+	}
+	if dto.ShiftID != nil {
+		shift, errResp := loadSwappableShift(*dto.ShiftID, user.ID)
+		if errResp != nil {
+			return c.Status(errResp.status).JSON(errResp.body)
+		}
+		if shift.DepartmentID != request.DepartmentID {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "The shift in return must belong to the same department",
+			})
+		}
+		if err := checkUnlocked(database.GetDB(), *shift); err != nil {
+			return shiftSaveError(c, err, nil)
+		}
+	}
+	for _, offer := range request.Offers {
+		if offer.UserID == user.ID && (offer.Status == models.OfferOffered || offer.Status == models.OfferAccepted) {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   "You have already answered this swap request",
+			})
+		}
+	}
+
+	offer := models.SwapOffer{
+		SwapRequestID: request.ID,
+		UserID:        user.ID,
+		ShiftID:       dto.ShiftID,
+		Status:        models.OfferOffered,
+		Note:          dto.Note,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// taking over a shift needs no consent of the requester
+		if offer.ShiftID == nil {
+			offer.Status = models.OfferAccepted
+		}
+		if err := tx.Omit("Shift").Create(&offer).Error; err != nil {
+			return err
+		}
+		if offer.ShiftID != nil {
+			return notify(tx, []uint{request.RequesterID}, user.ID, models.Notification{
+				Type:          models.NotificationSwapOffered,
+				Message:       user.FirstName + " " + user.LastName + " offers a shift in return for yours on " + formatShiftDay(request.Shift),
+				SwapRequestID: &request.ID,
+			})
+		}
+		return passSwapToManagers(tx, request, &offer, user.ID)
+	})
+	if err != nil {
+		return swapError(c, err, nil)
+	}
+	return swapRequestResponse(c, request.ID, "Swap offer successfully created")
+}
+
+// @Summary Accept a counter-offer
+// @Description the requester accepts a counter-offer, the swap then waits for the approval of a manager
+// @Tags swap-requests
+// @Param id path int true "Swap request ID"
+// @Param offerId path int true "Swap offer ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/offers/{offerId}/accept [post]
+func HandleAcceptSwapOffer(c *fiber.Ctx) error {
+	request, offer, errResp := loadSwapOffer(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	user := middleware.CurrentUser(c)
+	if request.RequesterID != user.ID {
+		return middleware.Forbidden(c)
+	}
+	if offer.Status != models.OfferOffered {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Swap offer is no longer available",
+		})
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		offer.Status = models.OfferAccepted
+		if err := tx.Omit("Shift").Save(offer).Error; err != nil {
+			return err
+		}
+		return passSwapToManagers(tx, request, offer, user.ID)
+	})
+	if err != nil {
+		return swapError(c, err, nil)
+	}
+	return swapRequestResponse(c, request.ID, "Swap offer successfully accepted")
+}
+
+// @Summary Decline a counter-offer
+// @Description the requester declines a counter-offer, the swap request stays open
+// @Tags swap-requests
+// @Param id path int true "Swap request ID"
+// @Param offerId path int true "Swap offer ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/offers/{offerId}/decline [post]
+func HandleDeclineSwapOffer(c *fiber.Ctx) error {
+	request, offer, errResp := loadSwapOffer(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if request.RequesterID != middleware.CurrentUser(c).ID {
+		return middleware.Forbidden(c)
+	}
+	if offer.Status != models.OfferOffered {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Swap offer is no longer available",
+		})
+	}
+
+	offer.Status = models.OfferDeclined
+	if err := database.GetDB().Omit("Shift").Save(offer).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return swapRequestResponse(c, request.ID, "Swap offer successfully declined")
+}
+
+// @Summary Withdraw a swap offer
+// @Description the colleague withdraws their offer; if it was already accepted the swap request is open again
+// @Tags swap-requests
+// @Param id path int true "Swap request ID"
+// @Param offerId path int true "Swap offer ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/offers/{offerId} [delete]
+func HandleWithdrawSwapOffer(c *fiber.Ctx) error {
+	request, offer, errResp := loadSwapOffer(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if offer.UserID != middleware.CurrentUser(c).ID {
+		return middleware.Forbidden(c)
+	}
+	if offer.Status != models.OfferOffered && offer.Status != models.OfferAccepted {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Swap offer is no longer available",
+		})
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if offer.Status == models.OfferAccepted {
+			if err := moveSwapRequest(tx, request, []string{models.SwapPending}, map[string]interface{}{
+				"status":            models.SwapOpen,
+				"accepted_offer_id": nil,
+			}); err != nil {
+				return err
+			}
+		}
+		offer.Status = models.OfferWithdrawn
+		return tx.Omit("Shift").Save(offer).Error
+	})
+	if err != nil {
+		return swapError(c, err, nil)
+	}
+	return swapRequestResponse(c, request.ID, "Swap offer successfully withdrawn")
+}
+
+type DecideSwapDTO struct {
+	Note string `json:"note"`
+}
+
+// @Summary Approve a swap
+// @Description exchange the users of the shifts after checking them again for overlaps, absences, locked plan periods and working time rules
+// @Tags swap-requests
+// @Accept json
+// @Produce json
+// @Param id path int true "Swap request ID"
+// @Param decision body DecideSwapDTO false "Decision note"
+// @Param force query bool false "Approve despite overlaps, absences and blocking working time violations, administrators only"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/approve [post]
+func HandleApproveSwapRequest(c *fiber.Ctx) error {
+	return decideSwap(c, models.SwapApproved)
+}
+
+// @Summary Reject a swap
+// @Description reject a swap that waits for approval, the shifts keep their users
+// @Tags swap-requests
+// @Accept json
+// @Produce json
+// @Param id path int true "Swap request ID"
+// @Param decision body DecideSwapDTO false "Decision note"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /swap-requests/{id}/reject [post]
+func HandleRejectSwapRequest(c *fiber.Ctx) error {
+	return decideSwap(c, models.SwapRejected)
+}
+
+var errSwapChanged = errors.New("Swap request has been changed in the meantime")
+
+var errSwapOutdated = errors.New("The shifts have been reassigned since the swap was requested")
+
+func decideSwap(c *fiber.Ctx, status string) error {
+	request, errResp := loadSwapRequest(c)
+	if errResp != nil {
+		return c.Status(errResp.status).JSON(errResp.body)
+	}
+	if request.Status != models.SwapPending || request.AcceptedOfferID == nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Swap request is not waiting for approval",
+		})
+	}
+	var offer models.SwapOffer
+	if err := database.GetDB().First(&offer, *request.AcceptedOfferID).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	// managers decide on swaps of their members, but not on their own
+	subject := middleware.CurrentSubject(c)
+	involved := subject.User.ID == request.RequesterID || subject.User.ID == offer.UserID
+	if !subject.CanManage(request.DepartmentID) || (involved && !subject.IsAdmin()) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(DecideSwapDTO)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(dto); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid input",
+			})
+		}
+	}
+
+	var violations []models.Violation
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := moveSwapRequest(tx, request, []string{models.SwapPending}, map[string]interface{}{
+			"status":        status,
+			"decision_note": dto.Note,
+			"decided_by_id": subject.User.ID,
+			"decided_at":    now,
+		}); err != nil {
+			return err
+		}
+
+		if status == models.SwapApproved {
+			var err error
+			violations, err = exchangeShifts(tx, request, &offer, forceRequested(c))
+			if err != nil {
+				return err
+			}
+		} else {
+			offer.Status = models.OfferDeclined
+			if err := tx.Omit("Shift").Save(&offer).Error; err != nil {
+				return err
+			}
+		}
+
+		message := "The swap of the shift on " + formatShiftDay(request.Shift) + " has been " + status
+		return notify(tx, []uint{request.RequesterID, offer.UserID}, subject.User.ID, models.Notification{
+			Type:          models.NotificationSwapDecided,
+			Message:       message,
+			SwapRequestID: &request.ID,
+		})
+	})
+	if err != nil {
+		return swapError(c, err, violations)
+	}
+
+	database.GetDB().Preload("Shift").Preload("Offers.Shift").First(request, request.ID)
+	return c.JSON(models.APIResponse{
+		Success:    true,
+		Message:    "Swap request successfully " + status,
+		Data:       request,
+		Violations: violations,
+	})
+}
+
+// exchangeShifts hands the requested shift to the offering user and the shift
+// offered in return to the requester, then checks both like any other change.
+// Other requests and offers for the shifts are withdrawn.
+func exchangeShifts(tx *gorm.DB, request *models.SwapRequest, offer *models.SwapOffer, force bool) ([]models.Violation, error) {
+	var shift models.Shift
+	if err := tx.First(&shift, request.ShiftID).Error; err != nil || shift.UserID != request.RequesterID {
+		return nil, errSwapOutdated
+	}
+	shift.UserID = offer.UserID
+	shifts := []*models.Shift{&shift}
+	shiftIDs := []uint{shift.ID}
+
+	if offer.ShiftID != nil {
+		var counter models.Shift
+		if err := tx.First(&counter, *offer.ShiftID).Error; err != nil || counter.UserID != offer.UserID {
+			return nil, errSwapOutdated
+		}
+		counter.UserID = request.RequesterID
+		shifts = append(shifts, &counter)
+		shiftIDs = append(shiftIDs, counter.ID)
+	}
+
+	violations, err := saveShifts(tx, shifts, force)
+	if err != nil {
+		return violations, err
+	}
+
+	if err := tx.Model(&models.SwapOffer{}).
+		Where("id <> ? AND status IN ? AND (swap_request_id = ? OR shift_id IN ?)", offer.ID, []string{models.OfferOffered, models.OfferAccepted}, request.ID, shiftIDs).
+		Update("status", models.OfferDeclined).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.SwapRequest{}).
+		Where("id <> ? AND status IN ? AND shift_id IN ?", request.ID, []string{models.SwapOpen, models.SwapPending}, shiftIDs).
+		Update("status", models.SwapCancelled).Error; err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
+
+// passSwapToManagers marks the request as waiting for approval of the accepted offer
+// and notifies the managers of the department
+func passSwapToManagers(tx *gorm.DB, request *models.SwapRequest, offer *models.SwapOffer, senderID uint) error {
+	if err := moveSwapRequest(tx, request, []string{models.SwapOpen}, map[string]interface{}{
+		"status":            models.SwapPending,
+		"accepted_offer_id": offer.ID,
+	}); err != nil {
+		return err
+	}
+	managers, err := departmentManagers(tx, request.DepartmentID)
+	if err != nil {
+		return err
+	}
+	return notify(tx, append(managers, request.RequesterID, offer.UserID), senderID, models.Notification{
+		Type:          models.NotificationSwapPending,
+		Message:       "The swap of the shift on " + formatShiftDay(request.Shift) + " is waiting for approval",
+		SwapRequestID: &request.ID,
+	})
+}
+
+// moveSwapRequest updates the request only if it still has one of the expected
+// states, so concurrent answers cannot both succeed
+func moveSwapRequest(tx *gorm.DB, request *models.SwapRequest, from []string, updates map[string]interface{}) error {
+	result := tx.Model(&models.SwapRequest{}).Where("id = ? AND status IN ?", request.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errSwapChanged
+	}
+	return nil
+}
+
+// loadSwapRequest loads the swap request addressed by the id param if the current
+// user may see it: as requester or as member of its department
+func loadSwapRequest(c *fiber.Ctx) (*models.SwapRequest, *errorResponse) {
+	var request models.SwapRequest
+	if err := database.GetDB().Preload("Shift").Preload("Offers").Where("id = ?", c.Params("id")).First(&request).Error; err != nil {
+		return nil, &errorResponse{404, models.APIResponse{Success: false, Error: "Swap request not found"}}
+	}
+	if subject := middleware.CurrentSubject(c); request.RequesterID != subject.User.ID && !subject.CanView(request.DepartmentID) {
+		return nil, &errorResponse{403, models.APIResponse{Success: false, Error: "Insufficient permissions"}}
+	}
+	return &request, nil
+}
+
+// loadSwapOffer loads the swap request and its offer addressed by the offerId param
+func loadSwapOffer(c *fiber.Ctx) (*models.SwapRequest, *models.SwapOffer, *errorResponse) {
+	request, errResp := loadSwapRequest(c)
+	if errResp != nil {
+		return nil, nil, errResp
+	}
+	offerID, _ := c.ParamsInt("offerId")
+	for i := range request.Offers {
+		if request.Offers[i].ID == uint(offerID) {
+			return request, &request.Offers[i], nil
+		}
+	}
+	return nil, nil, &errorResponse{404, models.APIResponse{Success: false, Error: "Swap offer not found"}}
+}
+
+// loadSwappableShift loads a shift of the user that has not started yet
+func loadSwappableShift(shiftID, userID uint) (*models.Shift, *errorResponse) {
+	var shift models.Shift
+	if err := database.GetDB().First(&shift, shiftID).Error; err != nil {
+		return nil, &errorResponse{400, models.APIResponse{Success: false, Error: "Invalid shift ID"}}
+	}
+	if shift.UserID != userID {
+		return nil, &errorResponse{403, models.APIResponse{Success: false, Error: "Only own shifts can be offered"}}
+	}
+	if !shift.StartTime.After(time.Now()) {
+		return nil, &errorResponse{400, models.APIResponse{Success: false, Error: "Shift has already started"}}
+	}
+	return &shift, nil
+}
+
+func swapRequestResponse(c *fiber.Ctx, id uint, message string) error {
+	var request models.SwapRequest
+	if err := database.GetDB().Preload("Shift").Preload("Offers.Shift").First(&request, id).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    request,
+	})
+}
+
+// swapError turns an error of a swap transition into the matching response
+func swapError(c *fiber.Ctx, err error, violations []models.Violation) error {
+	if errors.Is(err, errSwapChanged) || errors.Is(err, errSwapOutdated) {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return shiftSaveError(c, err, violations)
+}
+
+// formatShiftDay formats the local start day of the shift for messages
+func formatShiftDay(shift *models.Shift) string {
+	if shift == nil {
+		return ""
+	}
+	return shift.StartTime.In(config.Location()).Format("2006-01-02")
+}
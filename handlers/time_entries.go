@@ -0,0 +1,742 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+	"github.com/ptmmeiningen/schichtplaner/timetracking"
+	"github.com/ptmmeiningen/schichtplaner/worktime"
+	"gorm.io/gorm"
+)
+
+// clockInWindow is how long before its start a clock in is linked to a shift
+const clockInWindow = time.Hour
+
+// @Summary Get all time entries
+// @Description fetch the own time entries and those of members of managed departments, optionally filtered by user and date range
+// @Tags time-entries
+// @Accept */*
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Param open query bool false "Only entries without clock out"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries [get]
+func HandleAllTimeEntries(c *fiber.Ctx) error {
+	subject := middleware.CurrentSubject(c)
+	query := database.GetDB().Preload("Breaks").Order("clock_in").Scopes(subject.UserScope(database.GetDB(), subject.ManagedDepartments()))
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseTimeParam(from, false)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid from date",
+			})
+		}
+		query = query.Where("clock_out IS NULL OR clock_out > ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseTimeParam(to, true)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid to date",
+			})
+		}
+		query = query.Where("clock_in < ?", t)
+	}
+	if c.QueryBool("open") {
+		query = query.Where("clock_out IS NULL")
+	}
+
+	var entries []models.TimeEntry
+	result := query.Find(&entries)
+	if result.Error != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   result.Error.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Time entries successfully retrieved",
+		Data:    entries,
+	})
+}
+
+// @Summary Get a single time entry
+// @Description fetch time entry by ID including its breaks and corrections
+// @Tags time-entries
+// @Param id path int true "Time entry ID"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/{id} [get]
+func HandleGetOneTimeEntry(c *fiber.Ctx) error {
+	var entry models.TimeEntry
+	if err := database.GetDB().Preload("Breaks").Preload("Corrections").Where("id = ?", c.Params("id")).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Time entry not found",
+		})
+	}
+	if !canEditTimeEntry(c, entry.UserID) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Time entry successfully retrieved",
+		Data:    entry,
+	})
+}
+
+type ClockInDTO struct {
+	ShiftID *uint  `json:"shift_id"` // planned shift, found automatically if empty
+	Note    string `json:"note"`
+}
+
+// @Summary Clock in
+// @Description start a time entry for the current user, linked to the given shift or to the own shift running now or starting within the next hour
+// @Tags time-entries
+// @Accept json
+// @Produce json
+// @Param clock body ClockInDTO false "Shift and note"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/clock-in [post]
+func HandleClockIn(c *fiber.Ctx) error {
+	dto := new(ClockInDTO)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(dto); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid input",
+			})
+		}
+	}
+
+	user := middleware.CurrentUser(c)
+	if _, err := openTimeEntry(user.ID); err == nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Already clocked in",
+		})
+	}
+
+	now := time.Now().UTC()
+	entry := models.TimeEntry{
+		UserID:  user.ID,
+		ClockIn: now,
+		Source:  models.TimeEntryClock,
+		Note:    dto.Note,
+	}
+	if dto.ShiftID != nil {
+		if err := validateTimeEntryShift(database.GetDB(), user.ID, dto.ShiftID); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		entry.ShiftID = dto.ShiftID
+	} else {
+		var shifts []models.Shift
+		database.GetDB().Where("user_id = ? AND start_time < ? AND end_time > ?", user.ID, now.Add(clockInWindow), now).Find(&shifts)
+		if shift := timetracking.MatchShift(shifts, now, clockInWindow); shift != nil {
+			entry.ShiftID = &shift.ID
+		}
+	}
+	if ids, err := overlappingTimeEntryIDs(database.GetDB(), &entry); err != nil || len(ids) > 0 {
+		return timeEntryConflict(c, ids, err)
+	}
+
+	if err := database.GetDB().Omit("Breaks", "Corrections").Create(&entry).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Successfully clocked in",
+		Data:    entry,
+	})
+}
+
+// @Summary Clock out
+// @Description end the running time entry of the current user, a running break is ended as well
+// @Tags time-entries
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/clock-out [post]
+func HandleClockOut(c *fiber.Ctx) error {
+	entry, err := openTimeEntry(middleware.CurrentUser(c).ID)
+	if err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Not clocked in",
+		})
+	}
+
+	now := time.Now().UTC()
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := endBreaks(tx, entry, now); err != nil {
+			return err
+		}
+		entry.ClockOut = &now
+		return tx.Omit("Breaks", "Corrections").Save(entry).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Successfully clocked out",
+		Data:    entry,
+	})
+}
+
+// @Summary Start a break
+// @Description start a break within the running time entry of the current user
+// @Tags time-entries
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/break-start [post]
+func HandleStartBreak(c *fiber.Ctx) error {
+	entry, err := openTimeEntry(middleware.CurrentUser(c).ID)
+	if err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Not clocked in",
+		})
+	}
+	for _, b := range entry.Breaks {
+		if b.End == nil {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Already on break",
+			})
+		}
+	}
+
+	entry.Breaks = append(entry.Breaks, models.TimeBreak{TimeEntryID: entry.ID, Start: time.Now().UTC()})
+	if err := database.GetDB().Create(&entry.Breaks[len(entry.Breaks)-1]).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Break successfully started",
+		Data:    entry,
+	})
+}
+
+// @Summary End a break
+// @Description end the running break of the current user
+// @Tags time-entries
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/break-end [post]
+func HandleEndBreak(c *fiber.Ctx) error {
+	entry, err := openTimeEntry(middleware.CurrentUser(c).ID)
+	if err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Not clocked in",
+		})
+	}
+	onBreak := false
+	for _, b := range entry.Breaks {
+		onBreak = onBreak || b.End == nil
+	}
+	if !onBreak {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Not on break",
+		})
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := endBreaks(tx, entry, time.Now().UTC()); err != nil {
+			return err
+		}
+		return tx.Omit("Breaks", "Corrections").Save(entry).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Break successfully ended",
+		Data:    entry,
+	})
+}
+
+type CreateTimeEntryDTO struct {
+	UserID       uint       `json:"user_id"` // defaults to the current user
+	ShiftID      *uint      `json:"shift_id"`
+	ClockIn      time.Time  `json:"clock_in"`
+	ClockOut     *time.Time `json:"clock_out"`
+	BreakMinutes uint       `json:"break_minutes"`
+	Note         string     `json:"note"`
+	Reason       string     `json:"reason" example:"Forgot to clock in"`
+}
+
+// @Summary Enter a time entry afterwards
+// @Description record worked time that was not clocked, for the current user or as a manager for a member of the department; clock in, clock out and a reason are required, the entry must not overlap other entries of the user
+// @Tags time-entries
+// @Accept json
+// @Produce json
+// @Param entry body CreateTimeEntryDTO true "Time entry"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=TimeEntryConflictDTO}
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries [post]
+func HandleCreateTimeEntry(c *fiber.Ctx) error {
+	dto := new(CreateTimeEntryDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	if dto.UserID == 0 {
+		dto.UserID = middleware.CurrentUser(c).ID
+	}
+	if !canEditTimeEntry(c, dto.UserID) {
+		return middleware.Forbidden(c)
+	}
+
+	entry := models.TimeEntry{
+		UserID:       dto.UserID,
+		ShiftID:      dto.ShiftID,
+		ClockIn:      dto.ClockIn.UTC(),
+		BreakMinutes: dto.BreakMinutes,
+		Source:       models.TimeEntryManual,
+		Note:         strings.TrimSpace(dto.Reason + "\n" + dto.Note),
+	}
+	if dto.ClockOut != nil {
+		clockOut := dto.ClockOut.UTC()
+		entry.ClockOut = &clockOut
+	}
+	if strings.TrimSpace(dto.Reason) == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "A reason is required",
+		})
+	}
+	// running entries are only started by clocking in
+	if entry.ClockOut == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Clock out is required",
+		})
+	}
+	if err := validateTimeEntry(database.GetDB(), &entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if ids, err := overlappingTimeEntryIDs(database.GetDB(), &entry); err != nil || len(ids) > 0 {
+		return timeEntryConflict(c, ids, err)
+	}
+	if err := checkTimeEntryUnlocked(database.GetDB(), entry.ShiftID); err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+	if err := checkMonthOpen(database.GetDB(), entry.UserID, entry.ClockIn); err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Omit("Breaks", "Corrections").Create(&entry).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Time entry successfully created",
+		Data:    entry,
+	})
+}
+
+type CorrectTimeEntryDTO struct {
+	ShiftID      *uint      `json:"shift_id"`
+	ClockIn      time.Time  `json:"clock_in"`
+	ClockOut     *time.Time `json:"clock_out"`
+	BreakMinutes uint       `json:"break_minutes"`
+	Reason       string     `json:"reason" example:"Clocked out too late"`
+}
+
+// @Summary Correct a time entry
+// @Description change the times of an own entry or, as a manager, of a member of the department; the previous values and the reason are kept in the history. The entry must not overlap other entries of the user and finished entries keep a clock out
+// @Tags time-entries
+// @Accept json
+// @Produce json
+// @Param id path int true "Time entry ID"
+// @Param correction body CorrectTimeEntryDTO true "Corrected times and reason"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse{data=TimeEntryConflictDTO}
+// @Failure 423 {object} models.APIResponse{data=models.PlanPeriod}
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/{id} [put]
+func HandleCorrectTimeEntry(c *fiber.Ctx) error {
+	var entry models.TimeEntry
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&entry).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Time entry not found",
+		})
+	}
+	if !canEditTimeEntry(c, entry.UserID) {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(CorrectTimeEntryDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	reason := strings.TrimSpace(dto.Reason)
+	if reason == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "A reason is required",
+		})
+	}
+
+	correction := models.TimeCorrection{
+		TimeEntryID:     entry.ID,
+		CorrectedByID:   middleware.CurrentUser(c).ID,
+		Reason:          reason,
+		OldClockIn:      entry.ClockIn,
+		OldClockOut:     entry.ClockOut,
+		OldBreakMinutes: entry.BreakMinutes,
+		OldShiftID:      entry.ShiftID,
+	}
+	if err := checkTimeEntryUnlocked(database.GetDB(), entry.ShiftID); err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+
+	// a finished entry cannot be reopened
+	if entry.ClockOut != nil && dto.ClockOut == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Clock out is required",
+		})
+	}
+
+	entry.ShiftID = dto.ShiftID
+	entry.ClockIn = dto.ClockIn.UTC()
+	entry.ClockOut = nil
+	if dto.ClockOut != nil {
+		clockOut := dto.ClockOut.UTC()
+		entry.ClockOut = &clockOut
+	}
+	entry.BreakMinutes = dto.BreakMinutes
+	if err := validateTimeEntry(database.GetDB(), &entry); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if ids, err := overlappingTimeEntryIDs(database.GetDB(), &entry); err != nil || len(ids) > 0 {
+		return timeEntryConflict(c, ids, err)
+	}
+	if err := checkTimeEntryUnlocked(database.GetDB(), entry.ShiftID); err != nil {
+		return shiftSaveError(c, err, nil)
+	}
+	if err := checkMonthOpen(database.GetDB(), entry.UserID, correction.OldClockIn, entry.ClockIn); err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	correction.NewClockIn = entry.ClockIn
+	correction.NewClockOut = entry.ClockOut
+	correction.NewBreakMinutes = entry.BreakMinutes
+	correction.NewShiftID = entry.ShiftID
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Breaks", "Corrections").Save(&entry).Error; err != nil {
+			return err
+		}
+		return tx.Create(&correction).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	database.GetDB().Preload("Breaks").Preload("Corrections").First(&entry, entry.ID)
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Time entry successfully corrected",
+		Data:    entry,
+	})
+}
+
+// UserTimeReportDTO compares the planned with the recorded working time of a user
+type UserTimeReportDTO struct {
+	UserID          uint                     `json:"user_id"`
+	PlannedHours    float64                  `json:"planned_hours"`
+	ActualHours     float64                  `json:"actual_hours"`
+	DifferenceHours float64                  `json:"difference_hours"`
+	Deviations      []timetracking.Deviation `json:"deviations"`
+}
+
+// TimeDeviationReportDTO lists the deviations between plan and recorded times in a period
+type TimeDeviationReportDTO struct {
+	From             time.Time           `json:"from"`
+	To               time.Time           `json:"to"`
+	ToleranceMinutes int                 `json:"tolerance_minutes"`
+	Users            []UserTimeReportDTO `json:"users"`
+}
+
+// @Summary Report deviations from the plan
+// @Description compare planned shifts with the recorded times per user: late arrival, early leave, overtime, unplanned work and missed shifts. Without user and department the own times are reported.
+// @Tags time-entries
+// @Param user_id query int false "User ID"
+// @Param department_id query int false "Department ID, reports all members"
+// @Param week query string false "ISO week (YYYY-Www)"
+// @Param month query string false "Month (YYYY-MM)"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=TimeDeviationReportDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /time-entries/deviations [get]
+func HandleTimeDeviations(c *fiber.Ctx) error {
+	from, to, err := parsePeriodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	subject := middleware.CurrentSubject(c)
+	userIDs := []uint{subject.User.ID}
+	if departmentID := c.QueryInt("department_id"); departmentID > 0 {
+		if !subject.CanManage(uint(departmentID)) {
+			return middleware.Forbidden(c)
+		}
+		userIDs = nil
+		database.GetDB().Model(&models.UserDepartment{}).Where("department_id = ?", departmentID).Order("user_id").Pluck("user_id", &userIDs)
+	} else if userID := c.QueryInt("user_id"); userID > 0 {
+		if !canEditTimeEntry(c, uint(userID)) {
+			return middleware.Forbidden(c)
+		}
+		userIDs = []uint{uint(userID)}
+	}
+
+	var shifts []models.Shift
+	if err := database.GetDB().Where("user_id IN ? AND start_time >= ? AND start_time < ?", userIDs, from, to).
+		Order("start_time").Find(&shifts).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	var entries []models.TimeEntry
+	if err := database.GetDB().Where("user_id IN ? AND clock_in >= ? AND clock_in < ?", userIDs, from, to).
+		Order("clock_in").Find(&entries).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	now := time.Now()
+	tolerance := config.TimeTrackingTolerance()
+	reports := map[uint]*UserTimeReportDTO{}
+	report := TimeDeviationReportDTO{From: from, To: to, ToleranceMinutes: int(tolerance / time.Minute), Users: []UserTimeReportDTO{}}
+	for _, id := range userIDs {
+		reports[id] = &UserTimeReportDTO{UserID: id, Deviations: []timetracking.Deviation{}}
+	}
+	for _, shift := range shifts {
+		reports[shift.UserID].PlannedHours += rules.WorkingTime(shift).Hours()
+	}
+	for _, entry := range entries {
+		reports[entry.UserID].ActualHours += timetracking.WorkedTime(entry, now).Hours()
+	}
+	for _, deviation := range timetracking.Deviations(shifts, entries, tolerance, now) {
+		reports[deviation.UserID].Deviations = append(reports[deviation.UserID].Deviations, deviation)
+	}
+	for _, id := range userIDs {
+		r := reports[id]
+		r.PlannedHours = worktime.Round(r.PlannedHours)
+		r.ActualHours = worktime.Round(r.ActualHours)
+		r.DifferenceHours = worktime.Round(r.ActualHours - r.PlannedHours)
+		report.Users = append(report.Users, *r)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Deviations successfully computed",
+		Data:    report,
+	})
+}
+
+// TimeEntryConflictDTO lists the entries a rejected time entry would overlap with
+type TimeEntryConflictDTO struct {
+	ConflictingTimeEntryIDs []uint `json:"conflicting_time_entry_ids"`
+}
+
+// overlappingTimeEntryIDs returns the IDs of the other entries of the same user
+// overlapping the entry; running entries and the entry itself if it has no
+// clock out count as open-ended
+func overlappingTimeEntryIDs(tx *gorm.DB, entry *models.TimeEntry) ([]uint, error) {
+	ids := []uint{}
+	query := tx.Model(&models.TimeEntry{}).
+		Where("user_id = ? AND id <> ? AND (clock_out IS NULL OR clock_out > ?)", entry.UserID, entry.ID, entry.ClockIn)
+	if entry.ClockOut != nil {
+		query = query.Where("clock_in < ?", *entry.ClockOut)
+	}
+	err := query.Order("clock_in").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// timeEntryConflict responds to an overlap found by overlappingTimeEntryIDs or the error of its query
+func timeEntryConflict(c *fiber.Ctx, ids []uint, err error) error {
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.Status(409).JSON(models.APIResponse{
+		Success: false,
+		Error:   "Time entry overlaps with existing entries of this user",
+		Data:    TimeEntryConflictDTO{ConflictingTimeEntryIDs: ids},
+	})
+}
+
+// openTimeEntry returns the running time entry of the user with its breaks
+func openTimeEntry(userID uint) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	err := database.GetDB().Preload("Breaks").Where("user_id = ? AND clock_out IS NULL", userID).First(&entry).Error
+	return &entry, err
+}
+
+// endBreaks ends the running breaks of the entry at t and updates its break minutes
+func endBreaks(tx *gorm.DB, entry *models.TimeEntry, t time.Time) error {
+	for i := range entry.Breaks {
+		if entry.Breaks[i].End != nil {
+			continue
+		}
+		entry.Breaks[i].End = &t
+		if err := tx.Save(&entry.Breaks[i]).Error; err != nil {
+			return err
+		}
+	}
+	entry.BreakMinutes = timetracking.BreakMinutes(entry.Breaks)
+	return nil
+}
+
+// canEditTimeEntry reports whether the current user may see and correct the
+// times of the user: their own or as manager of the user's department
+func canEditTimeEntry(c *fiber.Ctx, userID uint) bool {
+	subject := middleware.CurrentSubject(c)
+	return subject.User.ID == userID || subject.CanManageUser(database.GetDB(), userID)
+}
+
+// checkTimeEntryUnlocked rejects changes to times worked in a locked plan period
+func checkTimeEntryUnlocked(tx *gorm.DB, shiftID *uint) error {
+	if shiftID == nil {
+		return nil
+	}
+	var shift models.Shift
+	if err := tx.First(&shift, *shiftID).Error; err != nil {
+		return nil
+	}
+	return checkUnlocked(tx, shift)
+}
+
+// validateTimeEntryShift checks that the shift exists and is planned for the user
+func validateTimeEntryShift(tx *gorm.DB, userID uint, shiftID *uint) error {
+	if shiftID == nil {
+		return nil
+	}
+	var shift models.Shift
+	if err := tx.First(&shift, *shiftID).Error; err != nil {
+		return errors.New("Invalid shift ID")
+	}
+	if shift.UserID != userID {
+		return errors.New("Shift is planned for another user")
+	}
+	return nil
+}
+
+func validateTimeEntry(tx *gorm.DB, entry *models.TimeEntry) error {
+	if entry.ClockIn.IsZero() {
+		return errors.New("Clock in is required")
+	}
+	if entry.ClockOut != nil {
+		if !entry.ClockOut.After(entry.ClockIn) {
+			return errors.New("Clock out must be after clock in")
+		}
+		if time.Duration(entry.BreakMinutes)*time.Minute >= entry.ClockOut.Sub(entry.ClockIn) {
+			return errors.New("Breaks must be shorter than the entry")
+		}
+	}
+	if err := tx.First(&models.User{}, entry.UserID).Error; err != nil {
+		return errors.New("Invalid user ID")
+	}
+	return validateTimeEntryShift(tx, entry.UserID, entry.ShiftID)
+}
@@ -14,6 +14,7 @@ import (
 // @Produce json
 // @Success 200 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /todos [get]
 func HandleAllTodos(c *fiber.Ctx) error {
 	var todos []models.Todo
@@ -47,6 +48,7 @@ type CreateTodoDTO struct {
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /todos [post]
 func HandleCreateTodo(c *fiber.Ctx) error {
 	todo := new(models.Todo)
@@ -83,6 +85,7 @@ func HandleCreateTodo(c *fiber.Ctx) error {
 // @Failure 400 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /todos/{id} [put]
 func HandleUpdateTodo(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -117,6 +120,7 @@ func HandleUpdateTodo(c *fiber.Ctx) error {
 // @Produce json
 // @Success 200 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /todos/{id} [get]
 func HandleGetOneTodo(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -144,6 +148,7 @@ func HandleGetOneTodo(c *fiber.Ctx) error {
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /todos/{id} [delete]
 func HandleDeleteTodo(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -1,22 +1,34 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/auth"
 	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
 	"github.com/ptmmeiningen/schichtplaner/models"
+	"gorm.io/gorm"
 )
 
 // @Summary Get all users
-// @Description fetch all users
+// @Description fetch all users sharing a department with the current user, administrators get all
 // @Tags users
 // @Accept */*
 // @Produce json
 // @Success 200 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /users [get]
 func HandleAllUsers(c *fiber.Ctx) error {
+	query := database.GetDB().Preload("Departments").Preload("Qualifications")
+	if subject := middleware.CurrentSubject(c); !subject.IsAdmin() {
+		members := database.GetDB().Model(&models.UserDepartment{}).Select("user_id").Where("department_id IN ?", subject.Departments())
+		query = query.Where("id = ? OR id IN (?)", subject.User.ID, members)
+	}
+
 	var users []models.User
-	result := database.GetDB().Preload("Departments").Find(&users)
+	result := query.Find(&users)
 	if result.Error != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -31,13 +43,17 @@ func HandleAllUsers(c *fiber.Ctx) error {
 }
 
 type CreateUserDTO struct {
-	FirstName     string `json:"first_name"`
-	LastName      string `json:"last_name"`
-	Email         string `json:"email"`
-	Password      string `json:"password"`
-	Color         string `json:"color"`
-	IsAdmin       bool   `json:"is_admin"`
-	DepartmentIDs []uint `json:"department_ids"`
+	FirstName        string  `json:"first_name"`
+	LastName         string  `json:"last_name"`
+	Email            string  `json:"email"`
+	Password         string  `json:"password"`
+	Color            string  `json:"color"`
+	IsAdmin          bool    `json:"is_admin"`
+	WeeklyHours      float64 `json:"weekly_hours" example:"40"`
+	BirthDate        string  `json:"birth_date" example:"2008-04-01"`
+	PersonnelNumber  string  `json:"personnel_number" example:"1001"`
+	DepartmentIDs    []uint  `json:"department_ids"`
+	QualificationIDs []uint  `json:"qualification_ids"`
 }
 
 // @Summary Create a user
@@ -48,7 +64,9 @@ type CreateUserDTO struct {
 // @Param user body CreateUserDTO true "User to create"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /users [post]
 func HandleCreateUser(c *fiber.Ctx) error {
 	dto := new(CreateUserDTO)
@@ -58,14 +76,33 @@ func HandleCreateUser(c *fiber.Ctx) error {
 			Error:   "Invalid input",
 		})
 	}
+	if dto.BirthDate != "" {
+		if _, err := time.Parse("2006-01-02", dto.BirthDate); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid birth date, expected YYYY-MM-DD",
+			})
+		}
+	}
+
+	hash, err := auth.HashPassword(dto.Password)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
 
 	user := models.User{
-		FirstName: dto.FirstName,
-		LastName:  dto.LastName,
-		Email:     dto.Email,
-		Password:  dto.Password,
-		Color:     dto.Color,
-		IsAdmin:   dto.IsAdmin,
+		FirstName:       dto.FirstName,
+		LastName:        dto.LastName,
+		Email:           dto.Email,
+		Password:        hash,
+		Color:           dto.Color,
+		IsAdmin:         dto.IsAdmin,
+		WeeklyHours:     dto.WeeklyHours,
+		BirthDate:       dto.BirthDate,
+		PersonnelNumber: dto.PersonnelNumber,
 	}
 
 	if len(dto.DepartmentIDs) > 0 {
@@ -79,6 +116,17 @@ func HandleCreateUser(c *fiber.Ctx) error {
 		user.Departments = departments
 	}
 
+	if len(dto.QualificationIDs) > 0 {
+		var qualifications []models.Qualification
+		if err := database.GetDB().Find(&qualifications, dto.QualificationIDs).Error; err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid qualification IDs",
+			})
+		}
+		user.Qualifications = qualifications
+	}
+
 	result := database.GetDB().Create(&user)
 	if result.Error != nil {
 		return c.Status(500).JSON(models.APIResponse{
@@ -100,18 +148,23 @@ func HandleCreateUser(c *fiber.Ctx) error {
 // @Param id path int true "User ID"
 // @Produce json
 // @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /users/{id} [get]
 func HandleGetOneUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var user models.User
-	if err := database.GetDB().Preload("Departments").Where("id = ?", id).First(&user).Error; err != nil {
+	if err := database.GetDB().Preload("Departments").Preload("Qualifications").Where("id = ?", id).First(&user).Error; err != nil {
 		return c.Status(404).JSON(models.APIResponse{
 			Success: false,
 			Error:   "User not found",
 		})
 	}
+	if !middleware.CurrentSubject(c).CanSeeUser(database.GetDB(), user.ID) {
+		return middleware.Forbidden(c)
+	}
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -121,7 +174,7 @@ func HandleGetOneUser(c *fiber.Ctx) error {
 }
 
 // @Summary Update a user
-// @Description update user by ID
+// @Description update user by ID, the password is only changed if one is given
 // @Tags users
 // @Accept json
 // @Produce json
@@ -129,13 +182,15 @@ func HandleGetOneUser(c *fiber.Ctx) error {
 // @Param user body CreateUserDTO true "User update data"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /users/{id} [put]
 func HandleUpdateUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var user models.User
-	if err := database.GetDB().Preload("Departments").Where("id = ?", id).First(&user).Error; err != nil {
+	if err := database.GetDB().Preload("Departments").Preload("Qualifications").Where("id = ?", id).First(&user).Error; err != nil {
 		return c.Status(404).JSON(models.APIResponse{
 			Success: false,
 			Error:   "User not found",
@@ -149,13 +204,34 @@ func HandleUpdateUser(c *fiber.Ctx) error {
 			Error:   "Invalid input",
 		})
 	}
+	if dto.BirthDate != "" {
+		if _, err := time.Parse("2006-01-02", dto.BirthDate); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid birth date, expected YYYY-MM-DD",
+			})
+		}
+	}
 
 	user.FirstName = dto.FirstName
 	user.LastName = dto.LastName
 	user.Email = dto.Email
-	user.Password = dto.Password
 	user.Color = dto.Color
 	user.IsAdmin = dto.IsAdmin
+	user.WeeklyHours = dto.WeeklyHours
+	user.BirthDate = dto.BirthDate
+	user.PersonnelNumber = dto.PersonnelNumber
+
+	if dto.Password != "" {
+		hash, err := auth.HashPassword(dto.Password)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		user.Password = hash
+	}
 
 	if len(dto.DepartmentIDs) > 0 {
 		var departments []models.Department
@@ -168,7 +244,38 @@ func HandleUpdateUser(c *fiber.Ctx) error {
 		user.Departments = departments
 	}
 
-	database.GetDB().Save(&user)
+	var qualifications []models.Qualification
+	if len(dto.QualificationIDs) > 0 {
+		if err := database.GetDB().Find(&qualifications, dto.QualificationIDs).Error; err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid qualification IDs",
+			})
+		}
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if dto.QualificationIDs != nil {
+			if err := tx.Model(&user).Association("Qualifications").Replace(qualifications); err != nil {
+				return err
+			}
+			user.Qualifications = qualifications
+		}
+		if err := tx.Omit("Qualifications").Save(&user).Error; err != nil {
+			return err
+		}
+		if dto.Password != "" {
+			// sessions opened with the old password must not outlive it
+			return auth.RevokeAll(tx, user.ID, middleware.CurrentSession(c))
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Message: "User successfully updated",
@@ -182,7 +289,9 @@ func HandleUpdateUser(c *fiber.Ctx) error {
 // @Param id path int true "User ID"
 // @Produce json
 // @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
 // @Router /users/{id} [delete]
 func HandleDeleteUser(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -200,3 +309,77 @@ func HandleDeleteUser(c *fiber.Ctx) error {
 		Message: "User successfully deleted",
 	})
 }
+
+type ChangePasswordDTO struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// @Summary Change the password of a user
+// @Description set a new password for the current user after verifying the old one, other sessions of the user are ended
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param password body ChangePasswordDTO true "Old and new password"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/password [put]
+func HandleChangePassword(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	if middleware.CurrentUser(c).ID != user.ID {
+		return middleware.Forbidden(c)
+	}
+
+	dto := new(ChangePasswordDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+
+	if !checkPassword(&user, dto.OldPassword) {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Old password is incorrect",
+		})
+	}
+
+	hash, err := auth.HashPassword(dto.NewPassword)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := database.GetDB().Model(&user).Update("password", hash).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	// other sessions may have been opened with the old password
+	if err := auth.RevokeAll(database.GetDB(), user.ID, middleware.CurrentSession(c)); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Password successfully changed",
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+)
+
+type PlanValidationDTO struct {
+	DepartmentID uint               `json:"department_id"`
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Rules        []string           `json:"rules"`
+	Violations   []models.Violation `json:"violations"`
+}
+
+// @Summary Validate a department plan
+// @Description check all shifts of the department members in a week, month or date range against the working time rules and flag shifts on public holidays; planners of the department only
+// @Tags departments
+// @Param id path int true "Department ID"
+// @Param week query string false "ISO week (YYYY-Www)"
+// @Param month query string false "Month (YYYY-MM)"
+// @Param from query string false "Start of range (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "End of range, inclusive for dates (YYYY-MM-DD or RFC3339)"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=PlanValidationDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /departments/{id}/validate [get]
+func HandleValidateDepartmentPlan(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var department models.Department
+	if err := database.GetDB().Preload("Users").Where("id = ?", id).First(&department).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Department not found",
+		})
+	}
+	// the violations reveal working times, earnings and ages of the members
+	if !middleware.CurrentSubject(c).CanManage(department.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	from, to, err := parsePeriodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	userIDs := []uint{}
+	for _, user := range department.Users {
+		userIDs = append(userIDs, user.ID)
+	}
+	var plannedIDs []uint
+	if err := database.GetDB().Model(&models.Shift{}).
+		Where("department_id = ? AND end_time > ? AND start_time < ?", department.ID, from, to).
+		Distinct().Pluck("user_id", &plannedIDs).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	userIDs = append(userIDs, plannedIDs...)
+
+	cfg := rules.ConfigFromEnv()
+	var shifts []models.Shift
+	windowStart, windowEnd := ruleWindow(cfg, from, to)
+	if err := database.GetDB().
+		Where("user_id IN ? AND end_time > ? AND start_time < ?", userIDs, windowStart, windowEnd).
+		Find(&shifts).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	var inPeriod []uint
+	for _, shift := range shifts {
+		if shift.EndTime.After(from) && shift.StartTime.Before(to) {
+			inPeriod = append(inPeriod, shift.ID)
+		}
+	}
+
+	if err := loadRuleData(database.GetDB(), &cfg, userIDs); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	engine := rules.NewEngine(cfg)
+	violations := rules.Involving(engine.Evaluate(shifts), inPeriod...)
+
+	var departmentShifts []*models.Shift
+	for i := range shifts {
+		shift := &shifts[i]
+		if shift.DepartmentID == department.ID && shift.EndTime.After(from) && shift.StartTime.Before(to) {
+			departmentShifts = append(departmentShifts, shift)
+		}
+	}
+	holidayWarnings, err := checkHolidays(database.GetDB(), departmentShifts)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Plan successfully validated",
+		Data: PlanValidationDTO{
+			DepartmentID: department.ID,
+			From:         from,
+			To:           to,
+			Rules:        engine.Rules(),
+			Violations:   append(violations, holidayWarnings...),
+		},
+	})
+}
+
+// parsePeriodParams reads a period from the week, month or from/to query parameters
+func parsePeriodParams(c *fiber.Ctx) (time.Time, time.Time, error) {
+	loc := config.Location()
+
+	if week := c.Query("week"); week != "" {
+		year, number, ok := strings.Cut(strings.ToUpper(week), "-W")
+		y, errY := strconv.Atoi(year)
+		w, errW := strconv.Atoi(number)
+		if !ok || errY != nil || errW != nil || w < 1 || w > 53 {
+			return time.Time{}, time.Time{}, errors.New("Invalid week, expected YYYY-Www")
+		}
+		// the 4th of January always lies in the first ISO week
+		start := rules.WeekStart(time.Date(y, 1, 4, 0, 0, 0, 0, loc), loc).AddDate(0, 0, 7*(w-1))
+		return start.UTC(), start.AddDate(0, 0, 7).UTC(), nil
+	}
+
+	if month := c.Query("month"); month != "" {
+		start, err := time.ParseInLocation("2006-01", month, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid month, expected YYYY-MM")
+		}
+		return start.UTC(), start.AddDate(0, 1, 0).UTC(), nil
+	}
+
+	if c.Query("from") == "" || c.Query("to") == "" {
+		return time.Time{}, time.Time{}, errors.New("Either week, month or from and to are required")
+	}
+	from, err := parseTimeParam(c.Query("from"), false)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid from date")
+	}
+	to, err := parseTimeParam(c.Query("to"), true)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid to date")
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("The end of the period must be after its start")
+	}
+	return from, to, nil
+}
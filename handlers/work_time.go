@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/contract"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+	"github.com/ptmmeiningen/schichtplaner/timetracking"
+	"github.com/ptmmeiningen/schichtplaner/worktime"
+	"gorm.io/gorm"
+)
+
+// WorkTimeAccountDTO is the working time account of a user with its monthly history
+type WorkTimeAccountDTO struct {
+	UserID      uint             `json:"user_id"`
+	WeeklyHours float64          `json:"weekly_hours"` // of the contract valid today
+	Balance     float64          `json:"balance"`      // balance at the end of the last month listed
+	Months      []worktime.Month `json:"months"`
+}
+
+// @Summary Get the working time account of a user
+// @Description target hours according to the contracts, planned and actual hours per month with adjustments and the running balance. Open months are counted up to today; the account starts with the first recorded time, adjustment or closing.
+// @Tags users
+// @Param id path int true "User ID"
+// @Param from query string false "First month (YYYY-MM), defaults to eleven months before to"
+// @Param to query string false "Last month (YYYY-MM), defaults to the current month"
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=WorkTimeAccountDTO}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/work-time [get]
+func HandleGetWorkTimeAccount(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	if subject := middleware.CurrentSubject(c); subject.User.ID != user.ID && !subject.CanManageUser(database.GetDB(), user.ID) {
+		return middleware.Forbidden(c)
+	}
+
+	loc := config.Location()
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if c.Query("to") != "" {
+		t, err := time.ParseInLocation("2006-01", c.Query("to"), loc)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid to month, expected YYYY-MM",
+			})
+		}
+		to = t
+	}
+	from := to.AddDate(0, -11, 0)
+	if c.Query("from") != "" {
+		t, err := time.ParseInLocation("2006-01", c.Query("from"), loc)
+		if err != nil || t.After(to) {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid from month, expected YYYY-MM not after to",
+			})
+		}
+		from = t
+	}
+
+	account, err := workTimeAccount(database.GetDB(), user, from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Working time account successfully retrieved",
+		Data:    account,
+	})
+}
+
+type WorkTimeAdjustmentDTO struct {
+	Date   string  `json:"date" example:"2024-05-31"`
+	Hours  float64 `json:"hours" example:"-8"`
+	Reason string  `json:"reason" example:"Overtime paid out"`
+}
+
+// @Summary Adjust the working time account of a user
+// @Description book hours onto or off the account of a user, e.g. paid out overtime; only in months that are not closed
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param adjustment body WorkTimeAdjustmentDTO true "Adjustment"
+// @Success 200 {object} models.APIResponse{data=models.WorkTimeAdjustment}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/work-time/adjustments [post]
+func HandleCreateWorkTimeAdjustment(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+
+	dto := new(WorkTimeAdjustmentDTO)
+	if err := c.BodyParser(dto); err != nil || dto.Hours == 0 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	date, err := time.ParseInLocation("2006-01-02", dto.Date, config.Location())
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid date, expected YYYY-MM-DD",
+		})
+	}
+	if strings.TrimSpace(dto.Reason) == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "A reason is required",
+		})
+	}
+	if err := checkMonthOpen(database.GetDB(), user.ID, date); err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	adjustment := models.WorkTimeAdjustment{
+		UserID:      user.ID,
+		Date:        dto.Date,
+		Hours:       dto.Hours,
+		Reason:      strings.TrimSpace(dto.Reason),
+		CreatedByID: middleware.CurrentUser(c).ID,
+	}
+	if err := database.GetDB().Create(&adjustment).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Working time account successfully adjusted",
+		Data:    adjustment,
+	})
+}
+
+type WorkTimeClosingDTO struct {
+	Month string `json:"month" example:"2024-05"`
+}
+
+// @Summary Close a month of the working time account
+// @Description freeze the hours of a past month and carry the balance over into the next month; months have to be closed in order
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param closing body WorkTimeClosingDTO true "Month to close"
+// @Success 200 {object} models.APIResponse{data=models.WorkTimeClosing}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/work-time/closings [post]
+func HandleCloseWorkTimeMonth(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.GetDB().Where("id = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+
+	dto := new(WorkTimeClosingDTO)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+		})
+	}
+	month, err := time.ParseInLocation("2006-01", dto.Month, config.Location())
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid month, expected YYYY-MM",
+		})
+	}
+	if month.AddDate(0, 1, 0).After(time.Now()) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Only past months can be closed",
+		})
+	}
+
+	// months are closed in order, starting with the first month of the account
+	var last models.WorkTimeClosing
+	err = database.GetDB().Where("user_id = ?", user.ID).Order("month desc").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err == nil && last.Month >= dto.Month {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Months up to %s are already closed", last.Month),
+		})
+	}
+	pending := err == nil && last.Month != month.AddDate(0, -1, 0).Format("2006-01")
+	if err != nil {
+		start, err := workTimeAccountStart(database.GetDB(), user.ID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		pending = month.After(start)
+	}
+	if pending {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Month %s has to be closed first", month.AddDate(0, -1, 0).Format("2006-01")),
+		})
+	}
+
+	account, err := workTimeAccount(database.GetDB(), user, month, month)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	// a month before the start of the account opens it with a zero balance
+	var m worktime.Month
+	if len(account.Months) > 0 {
+		m = account.Months[0]
+	} else if err := computeWorkTimeMonth(database.GetDB(), user, month, time.Now(), &m); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	m.Month = dto.Month
+	closing := models.WorkTimeClosing{
+		UserID:          user.ID,
+		Month:           m.Month,
+		OpeningBalance:  m.OpeningBalance,
+		TargetHours:     m.TargetHours,
+		PlannedHours:    m.PlannedHours,
+		ActualHours:     m.ActualHours,
+		AdjustmentHours: m.AdjustmentHours,
+		Balance:         m.Balance,
+		CarryOver:       m.CarryOver,
+		ClosedByID:      middleware.CurrentUser(c).ID,
+	}
+	if err := database.GetDB().Create(&closing).Error; err != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Month is already closed",
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Month successfully closed",
+		Data:    closing,
+	})
+}
+
+// @Summary Reopen a month of the working time account
+// @Description remove the closing of the last closed month so its hours are computed again
+// @Tags users
+// @Param id path int true "User ID"
+// @Param month path string true "Month (YYYY-MM)"
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Security BearerAuth
+// @Router /users/{id}/work-time/closings/{month} [delete]
+func HandleReopenWorkTimeMonth(c *fiber.Ctx) error {
+	var closing models.WorkTimeClosing
+	if err := database.GetDB().Where("user_id = ? AND month = ?", c.Params("id"), c.Params("month")).First(&closing).Error; err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Closing not found",
+		})
+	}
+
+	var later int64
+	database.GetDB().Model(&models.WorkTimeClosing{}).Where("user_id = ? AND month > ?", closing.UserID, closing.Month).Count(&later)
+	if later > 0 {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Only the last closed month can be reopened",
+		})
+	}
+
+	if err := database.GetDB().Delete(&closing).Error; err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Month successfully reopened",
+	})
+}
+
+// workTimeAccount computes the months from through to (first days in the
+// configured location) of the account. The balance runs from the last closing
+// before from, or from the start of the account if nothing was closed yet.
+func workTimeAccount(tx *gorm.DB, user models.User, from, to time.Time) (WorkTimeAccountDTO, error) {
+	account := WorkTimeAccountDTO{UserID: user.ID, WeeklyHours: user.WeeklyHours, Months: []worktime.Month{}}
+	contracts, err := loadContracts(tx, []uint{user.ID})
+	if err != nil {
+		return account, err
+	}
+	if c := contract.ValidAt(contracts[user.ID], time.Now(), config.Location()); c != nil {
+		account.WeeklyHours = c.WeeklyHours
+	}
+
+	var closings []models.WorkTimeClosing
+	if err := tx.Where("user_id = ? AND month <= ?", user.ID, to.Format("2006-01")).Order("month").Find(&closings).Error; err != nil {
+		return account, err
+	}
+	closed := map[string]models.WorkTimeClosing{}
+	for _, closing := range closings {
+		closed[closing.Month] = closing
+	}
+
+	start, err := workTimeAccountStart(tx, user.ID)
+	if err != nil {
+		return account, err
+	}
+	var opening float64
+	for _, closing := range closings {
+		if closing.Month < from.Format("2006-01") {
+			start, _ = time.ParseInLocation("2006-01", closing.Month, from.Location())
+		}
+	}
+
+	now := time.Now()
+	for month := start; !month.After(to) && month.Before(now); month = month.AddDate(0, 1, 0) {
+		m := worktime.Month{Month: month.Format("2006-01")}
+		if closing, ok := closed[m.Month]; ok {
+			m = worktime.Month{
+				Month:           closing.Month,
+				Closed:          true,
+				OpeningBalance:  closing.OpeningBalance,
+				TargetHours:     closing.TargetHours,
+				PlannedHours:    closing.PlannedHours,
+				ActualHours:     closing.ActualHours,
+				AdjustmentHours: closing.AdjustmentHours,
+				Balance:         closing.Balance,
+				CarryOver:       closing.CarryOver,
+			}
+		} else {
+			m.OpeningBalance = opening
+			if err := computeWorkTimeMonth(tx, user, month, now, &m); err != nil {
+				return account, err
+			}
+		}
+		opening = m.CarryOver
+
+		if month.Before(from) {
+			continue
+		}
+		if err := tx.Where("user_id = ? AND date >= ? AND date < ?", user.ID, month.Format("2006-01-02"),
+			month.AddDate(0, 1, 0).Format("2006-01-02")).Order("date, id").Find(&m.Adjustments).Error; err != nil {
+			return account, err
+		}
+		account.Months = append(account.Months, m)
+		account.Balance = m.Balance
+	}
+	return account, nil
+}
+
+// computeWorkTimeMonth fills in the hours of an open month up to the end of today
+func computeWorkTimeMonth(tx *gorm.DB, user models.User, month, now time.Time, m *worktime.Month) error {
+	loc := month.Location()
+	end := month.AddDate(0, 1, 0)
+	if today := now.In(loc); end.After(today) {
+		end = time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, loc)
+	}
+
+	isHoliday, err := holidayFilter(tx, user.ID)
+	if err != nil {
+		return err
+	}
+	absences, err := loadAbsences(tx, []uint{user.ID}, month, end)
+	if err != nil {
+		return err
+	}
+	contracts, err := loadContracts(tx, []uint{user.ID})
+	if err != nil {
+		return err
+	}
+	m.TargetHours = worktime.TargetHours(contracts[user.ID], user.WeeklyHours, month, end, loc, isHoliday, absences[user.ID])
+
+	var shifts []models.Shift
+	if err := tx.Where("user_id = ? AND start_time >= ? AND start_time < ?", user.ID, month.UTC(), end.UTC()).Find(&shifts).Error; err != nil {
+		return err
+	}
+	for _, shift := range shifts {
+		m.PlannedHours += rules.WorkingTime(shift).Hours()
+	}
+
+	var entries []models.TimeEntry
+	if err := tx.Where("user_id = ? AND clock_in >= ? AND clock_in < ?", user.ID, month.UTC(), end.UTC()).Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		m.ActualHours += timetracking.WorkedTime(entry, now).Hours()
+	}
+
+	var adjustments []float64
+	if err := tx.Model(&models.WorkTimeAdjustment{}).Where("user_id = ? AND date >= ? AND date < ?", user.ID,
+		month.Format("2006-01-02"), month.AddDate(0, 1, 0).Format("2006-01-02")).Pluck("hours", &adjustments).Error; err != nil {
+		return err
+	}
+	for _, hours := range adjustments {
+		m.AdjustmentHours += hours
+	}
+
+	m.Close(config.MaxCarryOverHours())
+	return nil
+}
+
+// workTimeAccountStart returns the first month of the account: the month of
+// the first closing, time entry or adjustment, or the current month
+func workTimeAccountStart(tx *gorm.DB, userID uint) (time.Time, error) {
+	loc := config.Location()
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+
+	var closing models.WorkTimeClosing
+	if err := tx.Where("user_id = ?", userID).Order("month").First(&closing).Error; err == nil {
+		if t, err := time.ParseInLocation("2006-01", closing.Month, loc); err == nil && t.Before(start) {
+			start = t
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return start, err
+	}
+
+	var entry models.TimeEntry
+	if err := tx.Where("user_id = ?", userID).Order("clock_in").First(&entry).Error; err == nil {
+		if t := entry.ClockIn.In(loc); t.Before(start) {
+			start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return start, err
+	}
+
+	var adjustment models.WorkTimeAdjustment
+	if err := tx.Where("user_id = ?", userID).Order("date").First(&adjustment).Error; err == nil {
+		if t, err := time.ParseInLocation("2006-01-02", adjustment.Date, loc); err == nil && t.Before(start) {
+			start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return start, err
+	}
+	return start, nil
+}
+
+// checkMonthOpen rejects changes to the working time account of the user in
+// months that are already closed
+func checkMonthOpen(tx *gorm.DB, userID uint, times ...time.Time) error {
+	for _, t := range times {
+		month := t.In(config.Location()).Format("2006-01")
+		var count int64
+		if err := tx.Model(&models.WorkTimeClosing{}).Where("user_id = ? AND month = ?", userID, month).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("Working time account is already closed for %s", month)
+		}
+	}
+	return nil
+}
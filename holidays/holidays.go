@@ -0,0 +1,197 @@
+package holidays
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// RuleHoliday is the rule name of holiday warnings
+const RuleHoliday = "holiday"
+
+// States maps the codes of the German federal states to their names
+var States = map[string]string{
+	"BW": "Baden-Württemberg",
+	"BY": "Bayern",
+	"BE": "Berlin",
+	"BB": "Brandenburg",
+	"HB": "Bremen",
+	"HH": "Hamburg",
+	"HE": "Hessen",
+	"MV": "Mecklenburg-Vorpommern",
+	"NI": "Niedersachsen",
+	"NW": "Nordrhein-Westfalen",
+	"RP": "Rheinland-Pfalz",
+	"SL": "Saarland",
+	"SN": "Sachsen",
+	"ST": "Sachsen-Anhalt",
+	"SH": "Schleswig-Holstein",
+	"TH": "Thüringen",
+}
+
+// Holiday is a public holiday on a calendar date
+type Holiday struct {
+	Date string `json:"date" example:"2024-10-31"`
+	Name string `json:"name" example:"Reformationstag"`
+}
+
+// ErrUnknownState is returned for state codes not listed in States
+var ErrUnknownState = errors.New("Unknown federal state")
+
+// NormalizeState returns the upper case code of the state or ErrUnknownState
+func NormalizeState(state string) (string, error) {
+	state = strings.ToUpper(strings.TrimSpace(state))
+	if _, ok := States[state]; !ok {
+		return "", ErrUnknownState
+	}
+	return state, nil
+}
+
+// Easter returns Easter Sunday of the year (Gregorian calendar, anonymous algorithm)
+func Easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := (19*a + b - b/4 - (b-(b+8)/25+1)/3 + 15) % 30
+	e := (32 + 2*(b%4) + 2*(c/4) - d - c%4) % 7
+	f := d + e - 7*((a+11*d+22*e)/451) + 114
+	return time.Date(year, time.Month(f/31), f%31+1, 0, 0, 0, 0, time.UTC)
+}
+
+// For returns the public holidays of the state in the year, ordered by date
+func For(year int, state string) ([]Holiday, error) {
+	state, err := NormalizeState(state)
+	if err != nil {
+		return nil, err
+	}
+	in := func(states ...string) bool {
+		for _, s := range states {
+			if s == state {
+				return true
+			}
+		}
+		return false
+	}
+
+	easter := Easter(year)
+	var days []Holiday
+	add := func(date time.Time, name string) {
+		days = append(days, Holiday{Date: date.Format("2006-01-02"), Name: name})
+	}
+	fixed := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	add(fixed(time.January, 1), "Neujahr")
+	if in("BW", "BY", "ST") {
+		add(fixed(time.January, 6), "Heilige Drei Könige")
+	}
+	if (in("BE") && year >= 2019) || (in("MV") && year >= 2023) {
+		add(fixed(time.March, 8), "Internationaler Frauentag")
+	}
+	add(easter.AddDate(0, 0, -2), "Karfreitag")
+	if in("BB") {
+		add(easter, "Ostersonntag")
+	}
+	add(easter.AddDate(0, 0, 1), "Ostermontag")
+	add(fixed(time.May, 1), "Tag der Arbeit")
+	if in("BE") && (year == 2020 || year == 2025) {
+		add(fixed(time.May, 8), "Tag der Befreiung")
+	}
+	add(easter.AddDate(0, 0, 39), "Christi Himmelfahrt")
+	if in("BB") {
+		add(easter.AddDate(0, 0, 49), "Pfingstsonntag")
+	}
+	add(easter.AddDate(0, 0, 50), "Pfingstmontag")
+	if in("BW", "BY", "HE", "NW", "RP", "SL") {
+		add(easter.AddDate(0, 0, 60), "Fronleichnam")
+	}
+	if in("SL") {
+		add(fixed(time.August, 15), "Mariä Himmelfahrt")
+	}
+	if in("TH") && year >= 2019 {
+		add(fixed(time.September, 20), "Weltkindertag")
+	}
+	add(fixed(time.October, 3), "Tag der Deutschen Einheit")
+	if in("BB", "MV", "SN", "ST", "TH") || (in("HB", "HH", "NI", "SH") && year >= 2018) || year == 2017 {
+		add(fixed(time.October, 31), "Reformationstag")
+	}
+	if in("BW", "BY", "NW", "RP", "SL") {
+		add(fixed(time.November, 1), "Allerheiligen")
+	}
+	if in("SN") {
+		// Wednesday before the 23rd of November
+		day := fixed(time.November, 22)
+		for day.Weekday() != time.Wednesday {
+			day = day.AddDate(0, 0, -1)
+		}
+		add(day, "Buß- und Bettag")
+	}
+	add(fixed(time.December, 25), "1. Weihnachtstag")
+	add(fixed(time.December, 26), "2. Weihnachtstag")
+
+	sort.SliceStable(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+// Calendar looks up holidays of one state across years
+type Calendar struct {
+	State string
+	years map[int]map[string]Holiday
+}
+
+// NewCalendar returns a calendar for the state
+func NewCalendar(state string) (*Calendar, error) {
+	state, err := NormalizeState(state)
+	if err != nil {
+		return nil, err
+	}
+	return &Calendar{State: state, years: map[int]map[string]Holiday{}}, nil
+}
+
+// On returns the holiday on the calendar day of t in loc, if any
+func (c *Calendar) On(t time.Time, loc *time.Location) (Holiday, bool) {
+	local := t.In(loc)
+	year, ok := c.years[local.Year()]
+	if !ok {
+		year = map[string]Holiday{}
+		days, _ := For(local.Year(), c.State)
+		for _, h := range days {
+			year[h.Date] = h
+		}
+		c.years[local.Year()] = year
+	}
+	h, ok := year[local.Format("2006-01-02")]
+	return h, ok
+}
+
+// Within returns the holidays touched by the time span [start, end) in loc
+func (c *Calendar) Within(start, end time.Time, loc *time.Location) []Holiday {
+	var found []Holiday
+	local := start.In(loc)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if h, ok := c.On(day, loc); ok {
+			found = append(found, h)
+		}
+	}
+	return found
+}
+
+// Check warns about shifts that are worked on a public holiday
+func (c *Calendar) Check(shift models.Shift, loc *time.Location) []models.Violation {
+	var violations []models.Violation
+	for _, h := range c.Within(shift.StartTime, shift.EndTime, loc) {
+		violations = append(violations, models.Violation{
+			Rule:     RuleHoliday,
+			Severity: models.SeverityWarning,
+			UserID:   shift.UserID,
+			ShiftIDs: []uint{shift.ID},
+			Message:  fmt.Sprintf("Shift is worked on a public holiday (%s, %s)", h.Name, h.Date),
+		})
+	}
+	return violations
+}
@@ -0,0 +1,134 @@
+package holidays
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+func TestEaster(t *testing.T) {
+	tests := map[int]string{
+		2000: "2000-04-23",
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2038: "2038-04-25",
+	}
+	for year, want := range tests {
+		if got := Easter(year).Format("2006-01-02"); got != want {
+			t.Errorf("Easter(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func TestNormalizeState(t *testing.T) {
+	if got, err := NormalizeState(" by "); err != nil || got != "BY" {
+		t.Errorf("NormalizeState(\" by \") = %q, %v, want BY", got, err)
+	}
+	if _, err := NormalizeState("XX"); err != ErrUnknownState {
+		t.Errorf("NormalizeState(\"XX\") error = %v, want ErrUnknownState", err)
+	}
+}
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		year  int
+		state string
+		date  string
+		name  string // empty if the date must be no holiday
+	}{
+		{2024, "NW", "2024-01-01", "Neujahr"},
+		{2024, "BY", "2024-01-06", "Heilige Drei Könige"},
+		{2024, "NW", "2024-01-06", ""},
+		{2024, "BE", "2024-03-08", "Internationaler Frauentag"},
+		{2022, "MV", "2022-03-08", ""},
+		{2023, "MV", "2023-03-08", "Internationaler Frauentag"},
+		{2024, "HH", "2024-03-29", "Karfreitag"},
+		{2024, "BB", "2024-03-31", "Ostersonntag"},
+		{2024, "HH", "2024-03-31", ""},
+		{2024, "HH", "2024-04-01", "Ostermontag"},
+		{2024, "HH", "2024-05-09", "Christi Himmelfahrt"},
+		{2025, "BE", "2025-05-08", "Tag der Befreiung"},
+		{2024, "BE", "2024-05-08", ""},
+		{2024, "HH", "2024-05-20", "Pfingstmontag"},
+		{2024, "HE", "2024-05-30", "Fronleichnam"},
+		{2024, "HH", "2024-05-30", ""},
+		{2024, "SL", "2024-08-15", "Mariä Himmelfahrt"},
+		{2024, "TH", "2024-09-20", "Weltkindertag"},
+		{2024, "BW", "2024-10-03", "Tag der Deutschen Einheit"},
+		{2017, "BW", "2017-10-31", "Reformationstag"},
+		{2018, "BW", "2018-10-31", ""},
+		{2017, "NI", "2017-10-31", "Reformationstag"},
+		{2018, "NI", "2018-10-31", "Reformationstag"},
+		{2024, "RP", "2024-11-01", "Allerheiligen"},
+		{2024, "SN", "2024-11-20", "Buß- und Bettag"},
+		{2023, "SN", "2023-11-22", "Buß- und Bettag"},
+		{2024, "BY", "2024-11-20", ""},
+		{2024, "HB", "2024-12-25", "1. Weihnachtstag"},
+		{2024, "HB", "2024-12-26", "2. Weihnachtstag"},
+	}
+	for _, tt := range tests {
+		days, err := For(tt.year, tt.state)
+		if err != nil {
+			t.Fatalf("For(%d, %s) error = %v", tt.year, tt.state, err)
+		}
+		name := ""
+		for _, h := range days {
+			if h.Date == tt.date {
+				name = h.Name
+			}
+		}
+		if name != tt.name {
+			t.Errorf("For(%d, %s) on %s = %q, want %q", tt.year, tt.state, tt.date, name, tt.name)
+		}
+	}
+
+	days, _ := For(2024, "BY")
+	for i := 1; i < len(days); i++ {
+		if days[i-1].Date >= days[i].Date {
+			t.Errorf("For() is not ordered by date: %s before %s", days[i-1].Date, days[i].Date)
+		}
+	}
+	if _, err := For(2024, "XX"); err == nil {
+		t.Error("For() accepted an unknown state")
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar, err := NewCalendar("by")
+	if err != nil {
+		t.Fatalf("NewCalendar() error = %v", err)
+	}
+
+	// 00:30 in Berlin on the 3rd of October is still the 2nd in UTC
+	if h, ok := calendar.On(time.Date(2024, 10, 2, 22, 30, 0, 0, time.UTC), loc); !ok || h.Name != "Tag der Deutschen Einheit" {
+		t.Errorf("On() = %+v, %v, want Tag der Deutschen Einheit", h, ok)
+	}
+	if _, ok := calendar.On(time.Date(2024, 10, 2, 22, 30, 0, 0, time.UTC), time.UTC); ok {
+		t.Error("On() found a holiday on the 2nd of October in UTC")
+	}
+
+	// a night shift from Christmas Eve into Christmas Day
+	shift := models.Shift{
+		ID:        7,
+		UserID:    3,
+		StartTime: time.Date(2024, 12, 24, 22, 0, 0, 0, loc),
+		EndTime:   time.Date(2024, 12, 25, 6, 0, 0, 0, loc),
+	}
+	within := calendar.Within(shift.StartTime, shift.EndTime, loc)
+	if len(within) != 1 || within[0].Date != "2024-12-25" {
+		t.Errorf("Within() = %+v, want the 1. Weihnachtstag", within)
+	}
+	violations := calendar.Check(shift, loc)
+	if len(violations) != 1 || violations[0].Severity != models.SeverityWarning || violations[0].UserID != 3 || violations[0].ShiftIDs[0] != 7 {
+		t.Errorf("Check() = %+v, want one warning for shift 7", violations)
+	}
+	if got := calendar.Check(models.Shift{StartTime: time.Date(2024, 12, 23, 8, 0, 0, 0, loc), EndTime: time.Date(2024, 12, 23, 16, 0, 0, 0, loc)}, loc); len(got) != 0 {
+		t.Errorf("Check() = %+v for a working day, want none", got)
+	}
+}
@@ -0,0 +1,131 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is a VEVENT of a calendar
+type Event struct {
+	UID          string
+	Sequence     uint
+	Stamp        time.Time
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Color        string
+	LastModified time.Time
+	Cancelled    bool
+
+	// read by Parse only
+	AllDay       bool
+	Attendees    []string   // e-mail addresses in lower case
+	RecurrenceID *time.Time // set on single occurrences of recurring events
+	RRule        string
+	ExDates      []time.Time
+
+	duration time.Duration
+	location *time.Location // zone of DTSTART, recurrences keep its wall clock time
+}
+
+// Calendar is a VCALENDAR with its events
+type Calendar struct {
+	Name        string
+	Description string
+	Color       string
+	Events      []Event
+}
+
+// maxLineLength is the maximum length of a content line in octets, longer lines are folded
+const maxLineLength = 75
+
+// Marshal encodes the calendar as iCalendar (RFC 5545) text
+func (c Calendar) Marshal() []byte {
+	var b strings.Builder
+	line := func(name, value string) {
+		fold(&b, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//ptmmeiningen//schichtplaner//DE")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("NAME", escape(c.Name))
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	if c.Description != "" {
+		line("X-WR-CALDESC", escape(c.Description))
+	}
+	if c.Color != "" {
+		line("X-APPLE-CALENDAR-COLOR", c.Color)
+	}
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	line("X-PUBLISHED-TTL", "PT1H")
+
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(e.UID))
+		line("DTSTAMP", formatTime(e.Stamp))
+		line("DTSTART", formatTime(e.Start))
+		line("DTEND", formatTime(e.End))
+		line("SEQUENCE", fmt.Sprint(e.Sequence))
+		if e.Cancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		if e.Summary != "" {
+			line("SUMMARY", escape(e.Summary))
+		}
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION", escape(e.Location))
+		}
+		if e.Color != "" {
+			line("COLOR", e.Color)
+		}
+		if !e.LastModified.IsZero() {
+			line("LAST-MODIFIED", formatTime(e.LastModified))
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return []byte(b.String())
+}
+
+// formatTime formats t as UTC date-time
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape escapes a TEXT value
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(value)
+}
+
+// fold writes a content line, splitting it after 75 octets without breaking
+// UTF-8 sequences; continuation lines start with a space
+func fold(b *strings.Builder, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineLength - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
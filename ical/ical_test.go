@@ -0,0 +1,83 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestMarshal(t *testing.T) {
+	start := time.Date(2024, 5, 6, 8, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	calendar := Calendar{
+		Name:  "Pflege; Station 1",
+		Color: "#ff0000",
+		Events: []Event{
+			{UID: "shift-1@schichtplaner", Sequence: 2, Stamp: start, Start: start, End: start.Add(8 * time.Hour),
+				Summary: "Frühdienst", Description: "Übergabe, Visite\nDoku"},
+			{UID: "shift-2@schichtplaner", Stamp: start, Start: start, End: start, Cancelled: true},
+		},
+	}
+	text := string(calendar.Marshal())
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:Pflege\\; Station 1\r\n",
+		"X-APPLE-CALENDAR-COLOR:#ff0000\r\n",
+		"UID:shift-1@schichtplaner\r\nDTSTAMP:20240506T060000Z\r\nDTSTART:20240506T060000Z\r\nDTEND:20240506T140000Z\r\nSEQUENCE:2\r\nSTATUS:CONFIRMED\r\n",
+		"SUMMARY:Frühdienst\r\n",
+		"DESCRIPTION:Übergabe\\, Visite\\nDoku\r\n",
+		"UID:shift-2@schichtplaner\r\n",
+		"STATUS:CANCELLED\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Marshal() lacks %q in\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "LAST-MODIFIED") {
+		t.Error("Marshal() writes LAST-MODIFIED without modification time")
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []string{
+		"SUMMARY:short",
+		"DESCRIPTION:" + strings.Repeat("a", 200),
+		// multi-byte characters must not be split across lines
+		"DESCRIPTION:" + strings.Repeat("ä", 100),
+		"DESCRIPTION:x" + strings.Repeat("€", 60),
+	}
+	for _, line := range tests {
+		var b strings.Builder
+		fold(&b, line)
+		folded := b.String()
+		if !strings.HasSuffix(folded, "\r\n") {
+			t.Errorf("fold(%q) does not end with CRLF", line)
+		}
+		for _, part := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+			if len(part) > maxLineLength || !utf8.ValidString(part) {
+				t.Errorf("fold(%q) wrote the invalid line %q", line, part)
+			}
+		}
+		if got := unfold(folded)[0]; got != line {
+			t.Errorf("unfold(fold(%q)) = %q", line, got)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"a\r\nb\nc\rd", `a\nb\nc\nd`},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.value); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
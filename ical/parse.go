@@ -0,0 +1,329 @@
+package ical
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/recurrence"
+)
+
+// property is a parsed content line
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the events of an iCalendar (RFC 5545) file. Times without zone
+// and zones unknown to Go, such as the Windows names Outlook uses, are read in loc.
+func Parse(data []byte, loc *time.Location) ([]Event, error) {
+	lines := unfold(string(data))
+
+	var events []Event
+	var stack []string
+	found := false
+	var event *Event
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, errors.New("Invalid line " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		switch p.name {
+		case "BEGIN":
+			component := strings.ToUpper(p.value)
+			if len(stack) == 0 && component != "VCALENDAR" {
+				return nil, errors.New("Not an iCalendar file")
+			}
+			found = true
+			stack = append(stack, component)
+			if component == "VEVENT" && len(stack) == 2 {
+				event = &Event{location: loc}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(p.value) {
+				return nil, errors.New("Unexpected END:" + p.value + " in line " + strconv.Itoa(i+1))
+			}
+			stack = stack[:len(stack)-1]
+			if event != nil && len(stack) == 1 {
+				if err := event.complete(); err != nil {
+					return nil, err
+				}
+				events = append(events, *event)
+				event = nil
+			}
+			continue
+		}
+		// properties of nested components such as alarms are ignored
+		if event == nil || len(stack) != 2 {
+			continue
+		}
+		if err := event.set(p, loc); err != nil {
+			return nil, errors.New("Invalid " + p.name + " in line " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+	}
+	if len(stack) > 0 || !found {
+		return nil, errors.New("Incomplete iCalendar file")
+	}
+	return events, nil
+}
+
+// Occurrences expands a recurring event to its occurrences starting within
+// [from, to). Occurrences carry their RecurrenceID and no rule; an event
+// without rule is returned as it is if it starts in the span.
+func (e Event) Occurrences(from, to time.Time) ([]Event, error) {
+	if e.RRule == "" {
+		if e.Start.Before(from) || !e.Start.Before(to) {
+			return nil, nil
+		}
+		return []Event{e}, nil
+	}
+
+	loc := e.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	rule, err := parseRule(e.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range e.ExDates {
+		rule.Exceptions = append(rule.Exceptions, t.In(loc).Format("2006-01-02"))
+	}
+	duration := e.End.Sub(e.Start)
+	var occurrences []Event
+	for _, start := range rule.Between(e.Start, from, to, loc) {
+		occurrence := e
+		occurrence.RRule = ""
+		occurrence.ExDates = nil
+		occurrence.Start = start
+		occurrence.End = start.Add(duration)
+		recurrenceID := start
+		occurrence.RecurrenceID = &recurrenceID
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+// set applies a property to the event
+func (e *Event) set(p property, loc *time.Location) error {
+	var err error
+	switch p.name {
+	case "UID":
+		e.UID = p.value
+	case "SUMMARY":
+		e.Summary = unescape(p.value)
+	case "DESCRIPTION":
+		e.Description = unescape(p.value)
+	case "LOCATION":
+		e.Location = unescape(p.value)
+	case "STATUS":
+		e.Cancelled = strings.EqualFold(p.value, "CANCELLED")
+	case "SEQUENCE":
+		var sequence uint64
+		sequence, err = strconv.ParseUint(p.value, 10, 32)
+		e.Sequence = uint(sequence)
+	case "DTSTAMP":
+		e.Stamp, _, err = parseTime(p, loc)
+	case "LAST-MODIFIED":
+		e.LastModified, _, err = parseTime(p, loc)
+	case "DTSTART":
+		e.Start, e.AllDay, err = parseTime(p, loc)
+		if e.Start.Location() != time.UTC {
+			e.location = e.Start.Location()
+		}
+	case "DTEND":
+		e.End, _, err = parseTime(p, loc)
+	case "DURATION":
+		e.duration, err = parseDuration(p.value)
+	case "RECURRENCE-ID":
+		var t time.Time
+		t, _, err = parseTime(p, loc)
+		e.RecurrenceID = &t
+	case "RRULE":
+		e.RRule = p.value
+	case "EXDATE":
+		for _, value := range strings.Split(p.value, ",") {
+			var t time.Time
+			t, _, err = parseTime(property{name: p.name, params: p.params, value: value}, loc)
+			if err != nil {
+				break
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+	case "ATTENDEE":
+		email := p.params["EMAIL"]
+		if email == "" && len(p.value) > 7 && strings.EqualFold(p.value[:7], "mailto:") {
+			email = p.value[7:]
+		}
+		if email != "" {
+			e.Attendees = append(e.Attendees, strings.ToLower(email))
+		}
+	}
+	return err
+}
+
+// complete derives the end of events given by duration or without end
+func (e *Event) complete() error {
+	if e.Start.IsZero() {
+		return errors.New("Event " + e.UID + " has no DTSTART")
+	}
+	if e.End.IsZero() {
+		switch {
+		case e.duration != 0:
+			e.End = e.Start.Add(e.duration)
+		case e.AllDay:
+			e.End = e.Start.AddDate(0, 0, 1)
+		default:
+			e.End = e.Start
+		}
+	}
+	return nil
+}
+
+// unfold joins continuation lines, which start with a space or tab
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\r"))
+	}
+	return lines
+}
+
+// parseProperty splits a content line into name, parameters and value
+func parseProperty(line string) (property, error) {
+	p := property{params: map[string]string{}}
+	quoted := false
+	start := 0
+	var parts []string
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == ';':
+			parts = append(parts, line[start:i])
+			start = i + 1
+		case r == ':':
+			parts = append(parts, line[start:i])
+			p.name = strings.ToUpper(parts[0])
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(param, "=")
+				p.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+			}
+			p.value = line[i+1:]
+			return p, nil
+		}
+	}
+	return p, errors.New("missing value")
+}
+
+// parseTime parses a DATE or DATE-TIME value, reporting whether it is a date
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", p.value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.value)
+		return t, false, err
+	}
+	if tzid := strings.TrimPrefix(p.params["TZID"], "/"); tzid != "" {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			loc = zone
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, loc)
+	return t, false, err
+}
+
+// parseDuration parses a dur-value such as PT8H30M or P1D
+func parseDuration(value string) (time.Duration, error) {
+	invalid := errors.New("invalid duration " + value)
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign = -1
+		value = value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, invalid
+	}
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var total time.Duration
+	number := ""
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; {
+		case c >= '0' && c <= '9':
+			number += string(c)
+		case c == 'T':
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		default:
+			unit, ok := units[c]
+			n, err := strconv.Atoi(number)
+			if !ok || err != nil {
+				return 0, invalid
+			}
+			total += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, invalid
+	}
+	return sign * total, nil
+}
+
+// parseRule converts an RRULE value to the subset supported by recurrence.Rule
+func parseRule(value string, loc *time.Location) (recurrence.Rule, error) {
+	var rule recurrence.Rule
+	for _, part := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Frequency = strings.ToLower(val)
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			until, date, parseErr := parseTime(property{value: val}, loc)
+			if date {
+				// a date includes occurrences on that day
+				until = until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			rule.Until, err = &until, parseErr
+		case "BYDAY":
+			rule.Weekdays, err = recurrence.ParseWeekdays(strings.Split(val, ","))
+		case "WKST":
+		default:
+			err = errors.New(key + " is not supported")
+		}
+		if err != nil {
+			return rule, errors.New("Unsupported recurrence rule " + value + ": " + err.Error())
+		}
+	}
+	if err := rule.Validate(); err != nil {
+		return rule, errors.New("Unsupported recurrence rule " + value + ": " + err.Error())
+	}
+	return rule, nil
+}
+
+// unescape decodes a TEXT value
+func unescape(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
@@ -0,0 +1,218 @@
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// calendar wraps the lines in a VCALENDAR with CRLF line endings
+func calendar(lines ...string) []byte {
+	return []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n")
+}
+
+func TestParse(t *testing.T) {
+	data := calendar(
+		"BEGIN:VEVENT",
+		"UID:1",
+		"DTSTART;TZID=Europe/Berlin:20240506T080000",
+		"DTEND;TZID=Europe/Berlin:20240506T163000",
+		"SUMMARY:Fr\\, Dienst",
+		"DESCRIPTION:erste Zeile\\nzweite ",
+		" Zeile",
+		`ATTENDEE;CN="Mustermann, Max";EMAIL=Max@Example.com:urn:uuid:1`,
+		"ATTENDEE;CN=Erika:MAILTO:erika@example.com",
+		"SEQUENCE:4",
+		"STATUS:CANCELLED",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"DESCRIPTION:alarm",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:2",
+		"DTSTART:20240507T060000Z",
+		"DURATION:PT8H30M",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:3",
+		"DTSTART;VALUE=DATE:20240508",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:4",
+		"DTSTART;TZID=W. Europe Standard Time:20240509T080000",
+		"DTEND:20240509T100000",
+		"END:VEVENT",
+	)
+	events, err := Parse(data, berlin)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Parse() returned %d events, want 4", len(events))
+	}
+
+	e := events[0]
+	if !e.Start.Equal(time.Date(2024, 5, 6, 6, 0, 0, 0, time.UTC)) || !e.End.Equal(time.Date(2024, 5, 6, 14, 30, 0, 0, time.UTC)) {
+		t.Errorf("event 1 from %v to %v, want 08:00 to 16:30 in Berlin", e.Start, e.End)
+	}
+	if e.Summary != "Fr, Dienst" || e.Description != "erste Zeile\nzweite Zeile" {
+		t.Errorf("event 1 summary %q and description %q, the alarm must be ignored", e.Summary, e.Description)
+	}
+	if want := []string{"max@example.com", "erika@example.com"}; !reflect.DeepEqual(e.Attendees, want) {
+		t.Errorf("event 1 attendees %v, want %v", e.Attendees, want)
+	}
+	if e.Sequence != 4 || !e.Cancelled {
+		t.Errorf("event 1 sequence %d and cancelled %v, want 4 and true", e.Sequence, e.Cancelled)
+	}
+
+	if e := events[1]; !e.End.Equal(time.Date(2024, 5, 7, 14, 30, 0, 0, time.UTC)) {
+		t.Errorf("event 2 ends %v, want DTSTART plus DURATION", e.End)
+	}
+	if e := events[2]; !e.AllDay || !e.Start.Equal(time.Date(2024, 5, 8, 0, 0, 0, 0, berlin)) || !e.End.Equal(e.Start.AddDate(0, 0, 1)) {
+		t.Errorf("event 3 all day %v from %v to %v, want the whole 2024-05-08 in Berlin", e.AllDay, e.Start, e.End)
+	}
+	// zones unknown to Go are read in the given location
+	if e := events[3]; !e.Start.Equal(time.Date(2024, 5, 9, 8, 0, 0, 0, berlin)) {
+		t.Errorf("event 4 starts %v, want 08:00 in Berlin", e.Start)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no calendar", "BEGIN:VEVENT\r\nEND:VEVENT\r\n"},
+		{"empty", ""},
+		{"unterminated", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\n"},
+		{"unexpected end", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"line without value", string(calendar("BEGIN:VEVENT", "UID", "END:VEVENT"))},
+		{"without start", string(calendar("BEGIN:VEVENT", "UID:1", "END:VEVENT"))},
+		{"invalid start", string(calendar("BEGIN:VEVENT", "UID:1", "DTSTART:2024-05-06", "END:VEVENT"))},
+		{"invalid duration", string(calendar("BEGIN:VEVENT", "UID:1", "DTSTART:20240506T060000Z", "DURATION:8H", "END:VEVENT"))},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.data), berlin); err == nil {
+			t.Errorf("%s: Parse() accepted the file", tt.name)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		valid bool
+	}{
+		{"PT8H30M", 8*time.Hour + 30*time.Minute, true},
+		{"P1D", 24 * time.Hour, true},
+		{"P1W", 7 * 24 * time.Hour, true},
+		{"P1DT2H", 26 * time.Hour, true},
+		{"-PT15M", -15 * time.Minute, true},
+		{"+PT10S", 10 * time.Second, true},
+		{"PT", 0, false},
+		{"P1H", 0, false},
+		{"PT8", 0, false},
+		{"8H", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.value)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v, valid %v", tt.value, got, err, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	from, to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{"single event", []string{"DTSTART;TZID=Europe/Berlin:20240325T080000"}, []string{"2024-03-25 08:00"}},
+		{"outside the span", []string{"DTSTART;TZID=Europe/Berlin:20240601T080000"}, nil},
+		// the occurrences keep 08:00 local time across the change to summer time
+		{"weekly with exception", []string{
+			"DTSTART;TZID=Europe/Berlin:20240325T080000",
+			"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			"EXDATE;TZID=Europe/Berlin:20240327T080000",
+		}, []string{"2024-03-25 08:00", "2024-04-01 08:00", "2024-04-03 08:00"}},
+		{"until a date", []string{
+			"DTSTART;TZID=Europe/Berlin:20240425T080000",
+			"RRULE:FREQ=DAILY;UNTIL=20240427",
+		}, []string{"2024-04-25 08:00", "2024-04-26 08:00", "2024-04-27 08:00"}},
+	}
+	for _, tt := range tests {
+		lines := append([]string{"BEGIN:VEVENT", "UID:1"}, tt.lines...)
+		events, err := Parse(calendar(append(lines, "DURATION:PT8H", "END:VEVENT")...), berlin)
+		if err != nil {
+			t.Fatalf("%s: Parse() error = %v", tt.name, err)
+		}
+		occurrences, err := events[0].Occurrences(from, to)
+		if err != nil {
+			t.Fatalf("%s: Occurrences() error = %v", tt.name, err)
+		}
+		var got []string
+		for _, o := range occurrences {
+			got = append(got, o.Start.In(berlin).Format("2006-01-02 15:04"))
+			if o.End.Sub(o.Start) != 8*time.Hour || o.RRule != "" {
+				t.Errorf("%s: occurrence %+v, want 8 hours without rule", tt.name, o)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Occurrences() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOccurrencesUnsupportedRule(t *testing.T) {
+	for _, rule := range []string{"FREQ=YEARLY", "FREQ=MONTHLY;BYMONTHDAY=1", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;COUNT=x"} {
+		events, err := Parse(calendar("BEGIN:VEVENT", "UID:1", "DTSTART:20240506T060000Z", "RRULE:"+rule, "END:VEVENT"), berlin)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if _, err := events[0].Occurrences(time.Time{}, time.Now()); err == nil {
+			t.Errorf("Occurrences() accepted RRULE:%s", rule)
+		}
+	}
+}
+
+func TestMarshalParse(t *testing.T) {
+	start := time.Date(2024, 5, 6, 6, 0, 0, 0, time.UTC)
+	want := Event{
+		UID:         "shift-1@schichtplaner",
+		Sequence:    3,
+		Stamp:       start,
+		Start:       start,
+		End:         start.Add(8 * time.Hour),
+		Summary:     "Früh; lang, " + strings.Repeat("sehr ", 20),
+		Description: "Zeile 1\nZeile 2 \\ Ende",
+		Location:    "Station 1",
+		Cancelled:   true,
+	}
+	events, err := Parse(Calendar{Events: []Event{want}}.Marshal(), time.UTC)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Parse() returned %d events, want 1", len(events))
+	}
+	got := events[0]
+	if got.UID != want.UID || got.Sequence != want.Sequence || !got.Start.Equal(want.Start) || !got.End.Equal(want.End) ||
+		got.Summary != want.Summary || got.Description != want.Description || got.Location != want.Location || !got.Cancelled {
+		t.Errorf("Parse(Marshal()) = %+v, want %+v", got, want)
+	}
+}
@@ -12,6 +12,10 @@ import (
 // @license.name MIT
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Access token from /auth/login, prefixed with "Bearer "
 func main() {
 	// setup and run app
 	err := app.SetupAndRunApp()
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ptmmeiningen/schichtplaner/auth"
+	"github.com/ptmmeiningen/schichtplaner/authz"
+	"github.com/ptmmeiningen/schichtplaner/database"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// Keys of the request context set by RequireAuth
+const (
+	UserKey    = "user"
+	SessionKey = "session"
+	SubjectKey = "subject"
+)
+
+// RequireAuth rejects requests without a valid bearer access token of an
+// active session and stores the authenticated user in the request context
+func RequireAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return unauthorized(c, "Authentication required")
+		}
+
+		claims, err := auth.ParseAccessToken(token)
+		if err != nil {
+			return unauthorized(c, err.Error())
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			return unauthorized(c, err.Error())
+		}
+
+		var session models.Session
+		if err := database.GetDB().Where("id = ? AND user_id = ?", claims.SessionID, userID).First(&session).Error; err != nil ||
+			session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			return unauthorized(c, auth.ErrSessionRevoked.Error())
+		}
+
+		var user models.User
+		if err := database.GetDB().Where("id = ?", userID).First(&user).Error; err != nil {
+			return unauthorized(c, "User not found")
+		}
+
+		subject, err := authz.Load(database.GetDB(), &user)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+
+		c.Locals(UserKey, &user)
+		c.Locals(SessionKey, session.ID)
+		c.Locals(SubjectKey, subject)
+		return c.Next()
+	}
+}
+
+// RequireAdmin only lets administrators pass, it has to follow RequireAuth
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if subject := CurrentSubject(c); subject == nil || !subject.IsAdmin() {
+			return Forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+// CurrentSubject returns the authenticated user with their roles, or nil on public routes
+func CurrentSubject(c *fiber.Ctx) *authz.Subject {
+	subject, _ := c.Locals(SubjectKey).(*authz.Subject)
+	return subject
+}
+
+// Forbidden answers requests the user is not allowed to make
+func Forbidden(c *fiber.Ctx) error {
+	return c.Status(403).JSON(models.APIResponse{
+		Success: false,
+		Error:   "Insufficient permissions",
+	})
+}
+
+// CurrentUser returns the authenticated user of the request, or nil on public routes
+func CurrentUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals(UserKey).(*models.User)
+	return user
+}
+
+// CurrentSession returns the session ID of the request, or "" on public routes
+func CurrentSession(c *fiber.Ctx) string {
+	session, _ := c.Locals(SessionKey).(string)
+	return session
+}
+
+func unauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(401).JSON(models.APIResponse{
+		Success: false,
+		Error:   message,
+	})
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Absence types
+const (
+	AbsenceVacation     = "urlaub"
+	AbsenceSickness     = "krankheit"
+	AbsenceTraining     = "fortbildung"
+	AbsenceSpecialLeave = "sonderurlaub"
+)
+
+// Absence states
+const (
+	AbsenceRequested = "requested"
+	AbsenceApproved  = "approved"
+	AbsenceRejected  = "rejected"
+)
+
+// Absence ist eine Abwesenheit eines Mitarbeiters (Urlaub, Krankheit, ...) über
+// einen oder mehrere ganze Tage oder einen halben Tag
+type Absence struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `gorm:"index" json:"deleted_at"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	Type         string     `json:"type" gorm:"not null" example:"urlaub"`
+	StartDate    string     `json:"start_date" gorm:"not null;index" example:"2024-07-01"`
+	EndDate      string     `json:"end_date" gorm:"not null;index" example:"2024-07-12"`
+	HalfDay      bool       `json:"half_day"` // only for single days
+	Status       string     `json:"status" gorm:"not null;index" example:"requested"`
+	Comment      string     `json:"comment"`
+	DecisionNote string     `json:"decision_note"`
+	DecidedByID  *uint      `json:"decided_by_id"`
+	DecidedAt    *time.Time `json:"decided_at"`
+}
+
+// VacationEntitlement ist der Urlaubsanspruch eines Mitarbeiters in Tagen für ein Jahr
+type VacationEntitlement struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_vacation_user_year"`
+	Year      int       `json:"year" gorm:"not null;uniqueIndex:idx_vacation_user_year" example:"2024"`
+	Days      float64   `json:"days" gorm:"not null" example:"30"`
+}
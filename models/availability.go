@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Availability states
+const (
+	AvailabilityAvailable   = "available"
+	AvailabilityUnavailable = "unavailable"
+	AvailabilityPreferred   = "preferred"
+)
+
+// Availability beschreibt, wann ein Mitarbeiter arbeiten kann: wöchentlich
+// wiederkehrend (Weekday) oder an einem bestimmten Tag (Date)
+type Availability struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Weekday   *uint      `json:"weekday" example:"1"`                    // ISO weekday, 1 = Monday ... 7 = Sunday
+	Date      string     `json:"date" gorm:"index" example:"2024-05-06"` // one-off date, overrides weekly entries
+	StartTime string     `json:"start_time" example:"06:00"`             // empty for the whole day
+	EndTime   string     `json:"end_time" example:"14:00"`
+	Status    string     `json:"status" gorm:"not null" example:"available"`
+	Note      string     `json:"note"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// CalendarFeed ist ein geheimer Abonnement-Link auf die Schichten eines
+// Benutzers oder einer Abteilung. Gespeichert wird nur der Hash des Tokens.
+type CalendarFeed struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	TokenHash    string    `json:"-" gorm:"not null;uniqueIndex"`
+	UserID       *uint     `json:"user_id" gorm:"uniqueIndex"`
+	DepartmentID *uint     `json:"department_id" gorm:"uniqueIndex"`
+}
+
+// ShiftCancellation merkt sich eine Schicht, die aus dem Kalender eines
+// Benutzers (UserID) oder einer Abteilung (DepartmentID) verschwunden ist,
+// damit abonnierte Kalender den Termin als abgesagt erhalten
+type ShiftCancellation struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ShiftID      uint      `json:"shift_id" gorm:"not null;index"`
+	UserID       uint      `json:"user_id" gorm:"index"`       // 0 if the shift stays in the calendar of its user
+	DepartmentID uint      `json:"department_id" gorm:"index"` // 0 if the shift stays in the department
+	StartTime    time.Time `json:"start_time" gorm:"not null;index"`
+	EndTime      time.Time `json:"end_time" gorm:"not null"`
+	Sequence     uint      `json:"sequence"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Employment types
+const (
+	ContractFullTime   = "vollzeit"
+	ContractPartTime   = "teilzeit"
+	ContractMinijob    = "minijob"
+	ContractApprentice = "azubi"
+)
+
+// Contract ist ein Arbeitsvertrag eines Mitarbeiters mit Gültigkeitszeitraum.
+// Für Planung, Überstunden und Auswertungen gilt der Vertrag, der am Tag der
+// Schicht gültig ist.
+type Contract struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `gorm:"index" json:"deleted_at"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Type        string     `json:"type" gorm:"not null" example:"teilzeit"`
+	WeeklyHours float64    `json:"weekly_hours" gorm:"not null" example:"30"`
+	WorkingDays []string   `json:"working_days" gorm:"serializer:json" example:"MO,TU,WE,TH,FR"`
+	HourlyWage  float64    `json:"hourly_wage" example:"15.50"` // gross in euros
+	ValidFrom   string     `json:"valid_from" gorm:"not null;index" example:"2024-01-01"`
+	ValidTo     string     `json:"valid_to" gorm:"index" example:"2024-12-31"` // empty while open-ended
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Notification types
+const (
+	NotificationPlanPublished = "plan_published"
+	NotificationPlanUnlocked  = "plan_unlocked"
+	NotificationSwapRequested = "swap_requested"
+	NotificationSwapOffered   = "swap_offered"
+	NotificationSwapPending   = "swap_pending"
+	NotificationSwapDecided   = "swap_decided"
+	NotificationShiftClaimed  = "shift_claimed"
+	NotificationClaimDecided  = "claim_decided"
+)
+
+// Notification ist eine Benachrichtigung an einen Benutzer, z.B. über einen veröffentlichten Dienstplan
+type Notification struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	Type          string     `json:"type" gorm:"not null" example:"plan_published"`
+	Message       string     `json:"message"`
+	PlanPeriodID  *uint      `json:"plan_period_id,omitempty"`
+	SwapRequestID *uint      `json:"swap_request_id,omitempty"`
+	ShiftID       *uint      `json:"shift_id,omitempty"`
+	ReadAt        *time.Time `json:"read_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Plan period states
+const (
+	PlanDraft     = "draft"
+	PlanPublished = "published"
+	PlanLocked    = "locked"
+)
+
+// Plan period actions recorded in the history
+const (
+	PlanActionPublished = "published"
+	PlanActionLocked    = "locked"
+	PlanActionUnlocked  = "unlocked"
+)
+
+// PlanPeriod ist ein Planungszeitraum einer Abteilung. Schichten im Entwurf sehen
+// nur die Planer, veröffentlichte Schichten alle Mitglieder und abgeschlossene
+// (bereits abgerechnete) Zeiträume können nicht mehr geändert werden.
+type PlanPeriod struct {
+	ID            uint              `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	DeletedAt     *time.Time        `gorm:"index" json:"deleted_at"`
+	DepartmentID  uint              `json:"department_id" gorm:"not null;index"`
+	StartDate     string            `json:"start_date" gorm:"not null" example:"2024-05-01"`
+	EndDate       string            `json:"end_date" gorm:"not null" example:"2024-05-31"`
+	StartsAt      time.Time         `json:"-" gorm:"not null;index"` // start of StartDate in UTC
+	EndsAt        time.Time         `json:"-" gorm:"not null;index"` // end of EndDate in UTC
+	Status        string            `json:"status" gorm:"not null;index" example:"draft"`
+	PublishedAt   *time.Time        `json:"published_at"`
+	PublishedByID *uint             `json:"published_by_id"`
+	LockedAt      *time.Time        `json:"locked_at"`
+	LockedByID    *uint             `json:"locked_by_id"`
+	Events        []PlanPeriodEvent `json:"events,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PlanPeriodEvent ist ein Eintrag in der Historie eines Planungszeitraums
+type PlanPeriodEvent struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	PlanPeriodID uint      `json:"plan_period_id" gorm:"not null;index"`
+	UserID       uint      `json:"user_id"`
+	Action       string    `json:"action" gorm:"not null" example:"unlocked"`
+	Reason       string    `json:"reason"`
+}
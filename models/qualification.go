@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Qualification ist eine Fähigkeit oder Berechtigung eines Mitarbeiters, z.B. Ersthelfer
+type Qualification struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `gorm:"index" json:"deleted_at"`
+	Name        string     `json:"name" gorm:"unique;not null" example:"Ersthelfer"`
+	Description string     `json:"description"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session ist eine Anmeldung eines Benutzers. Access Tokens verweisen auf die
+// Session, Refresh Tokens werden bei jeder Verwendung durch neue ersetzt.
+type Session struct {
+	ID        string     `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// RefreshToken speichert nur den SHA-256 Hash des ausgegebenen Tokens
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	SessionID string     `json:"session_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
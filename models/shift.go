@@ -4,14 +4,61 @@ import (
 	"time"
 )
 
+// Claim modes of open shifts
+const (
+	ClaimFirstCome = "first_come" // the first qualified member to claim gets the shift
+	ClaimApproval  = "approval"   // claims have to be approved by a manager
+)
+
+// Shift ist eine geplante Schicht. Schichten ohne Benutzer (UserID 0) sind offene
+// Schichten, die Mitglieder der Abteilung übernehmen können.
 type Shift struct {
-	ID          uint       `gorm:"primarykey" json:"id"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	DeletedAt   *time.Time `gorm:"index" json:"deleted_at"`
-	StartTime   time.Time  `json:"start_time" gorm:"not null"`
-	EndTime     time.Time  `json:"end_time" gorm:"not null"`
-	Description string     `json:"description"`
-	UserID      uint       `json:"user_id"`
-	User        User       `json:"user"`
+	ID              uint           `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       *time.Time     `gorm:"index" json:"deleted_at"`
+	StartTime       time.Time      `json:"start_time" gorm:"not null;index"`
+	EndTime         time.Time      `json:"end_time" gorm:"not null;index"`
+	Description     string         `json:"description"`
+	BreakMinutes    uint           `json:"break_minutes"`
+	UserID          uint           `json:"user_id" gorm:"index"` // 0 for open shifts
+	User            *User          `json:"user,omitempty"`
+	DepartmentID    uint           `json:"department_id" gorm:"index"`
+	SeriesID        *uint          `json:"series_id" gorm:"index"`
+	ShiftTypeID     *uint          `json:"shift_type_id" gorm:"index"`
+	ShiftType       *ShiftType     `json:"shift_type,omitempty"`
+	ClaimMode       string         `json:"claim_mode,omitempty" example:"approval"` // only for open shifts
+	QualificationID *uint          `json:"qualification_id"`                        // required to claim the open shift
+	Qualification   *Qualification `json:"qualification,omitempty"`
+	Sequence        uint           `json:"sequence"`                          // incremented on every change, for calendar clients
+	ImportUID       string         `json:"import_uid,omitempty" gorm:"index"` // calendar event the shift was imported from
+}
+
+// IsOpen reports whether the shift has not been assigned to a user yet
+func (s Shift) IsOpen() bool {
+	return s.UserID == 0
+}
+
+// Shift claim states
+const (
+	ClaimRequested = "requested"
+	ClaimApproved  = "approved"
+	ClaimRejected  = "rejected"
+	ClaimWithdrawn = "withdrawn"
+)
+
+// ShiftClaim ist die Bewerbung eines Mitarbeiters auf eine offene Schicht, die ein Planer genehmigen muss
+type ShiftClaim struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ShiftID      uint       `json:"shift_id" gorm:"not null;index"`
+	Shift        *Shift     `json:"shift,omitempty"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	DepartmentID uint       `json:"department_id" gorm:"not null;index"`
+	Status       string     `json:"status" gorm:"not null;index" example:"requested"`
+	Note         string     `json:"note"`
+	DecisionNote string     `json:"decision_note"`
+	DecidedByID  *uint      `json:"decided_by_id"`
+	DecidedAt    *time.Time `json:"decided_at"`
 }
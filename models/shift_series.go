@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ShiftSeries beschreibt eine wiederkehrende Schicht, deren Termine als Shift angelegt werden
+type ShiftSeries struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `gorm:"index" json:"deleted_at"`
+	StartTime      time.Time  `json:"start_time" gorm:"not null"`
+	EndTime        time.Time  `json:"end_time" gorm:"not null"`
+	Description    string     `json:"description"`
+	BreakMinutes   uint       `json:"break_minutes"`
+	UserID         uint       `json:"user_id" gorm:"index"`
+	DepartmentID   uint       `json:"department_id" gorm:"index"`
+	ShiftTypeID    *uint      `json:"shift_type_id"`
+	Frequency      string     `json:"frequency" gorm:"not null" example:"weekly"`
+	Interval       int        `json:"interval" gorm:"default:1"`
+	Weekdays       []string   `json:"weekdays" gorm:"serializer:json" example:"MO,TU,WE,TH,FR"`
+	Until          *time.Time `json:"until"`
+	Count          int        `json:"count"`
+	ExceptionDates []string   `json:"exception_dates" gorm:"serializer:json"`
+	Shifts         []Shift    `json:"shifts,omitempty" gorm:"foreignKey:SeriesID"`
+}
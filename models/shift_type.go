@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ShiftType ist eine Schichtvorlage einer Abteilung, z.B. Frühschicht 06:00-14:00
+type ShiftType struct {
+	ID                uint       `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	DeletedAt         *time.Time `gorm:"index" json:"deleted_at"`
+	DepartmentID      uint       `json:"department_id" gorm:"not null;index"`
+	Name              string     `json:"name" gorm:"not null" example:"Frühschicht"`
+	Color             string     `json:"color" example:"#ffcc00"`
+	StartTime         string     `json:"start_time" gorm:"not null" example:"06:00"`
+	EndTime           string     `json:"end_time" gorm:"not null" example:"14:00"`
+	BreakMinutes      uint       `json:"break_minutes" example:"30"`
+	RequiredHeadcount uint       `json:"required_headcount" example:"2"`
+}
+
+// Span returns start and end of the shift type on the given day in loc.
+// An end time not after the start time ends on the following day (night shift).
+func (t ShiftType) Span(day time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := ParseClock(t.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := ParseClock(t.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+
+	local := day.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return atClock(midnight, start, loc), atClock(midnight, end, loc), nil
+}
+
+// ParseClock parses a wall clock time in HH:MM format into the offset from
+// midnight, 24:00 is accepted as the end of the day
+func ParseClock(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil || len(value) != 5 {
+		return 0, errors.New("Invalid time " + value + ", expected HH:MM")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// atClock adds a clock offset to midnight keeping the wall clock across DST changes
+func atClock(midnight time.Time, offset time.Duration, loc *time.Location) time.Time {
+	days := int(offset / (24 * time.Hour))
+	rest := offset % (24 * time.Hour)
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day()+days,
+		int(rest/time.Hour), int(rest%time.Hour/time.Minute), 0, 0, loc)
+}
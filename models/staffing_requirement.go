@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// StaffingRequirement legt fest, wie viele Mitarbeiter eine Abteilung an einem
+// Wochentag für eine Schichtart oder ein Zeitfenster benötigt
+type StaffingRequirement struct {
+	ID              uint           `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       *time.Time     `gorm:"index" json:"deleted_at"`
+	DepartmentID    uint           `json:"department_id" gorm:"not null;index"`
+	Weekday         uint           `json:"weekday" gorm:"not null" example:"1"` // ISO weekday, 1 = Monday ... 7 = Sunday
+	ShiftTypeID     *uint          `json:"shift_type_id"`
+	StartTime       string         `json:"start_time" example:"06:00"` // time slot, used without shift type
+	EndTime         string         `json:"end_time" example:"14:00"`
+	Headcount       uint           `json:"headcount" gorm:"not null" example:"3"`
+	QualificationID *uint          `json:"qualification_id"`
+	Qualification   *Qualification `json:"qualification,omitempty"`
+}
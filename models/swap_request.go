@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Swap request types
+const (
+	SwapTypeSwap     = "swap"
+	SwapTypeGiveaway = "giveaway"
+)
+
+// Swap request states
+const (
+	SwapOpen      = "open"
+	SwapPending   = "pending" // an offer has been accepted, waiting for the manager
+	SwapApproved  = "approved"
+	SwapRejected  = "rejected"
+	SwapCancelled = "cancelled"
+)
+
+// Swap offer states
+const (
+	OfferOffered   = "offered"
+	OfferAccepted  = "accepted"
+	OfferDeclined  = "declined"
+	OfferWithdrawn = "withdrawn"
+)
+
+// SwapRequest ist das Angebot eines Mitarbeiters, eine seiner Schichten zu tauschen oder abzugeben
+type SwapRequest struct {
+	ID              uint        `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	DeletedAt       *time.Time  `gorm:"index" json:"deleted_at"`
+	Type            string      `json:"type" gorm:"not null" example:"swap"`
+	Status          string      `json:"status" gorm:"not null;index" example:"open"`
+	ShiftID         uint        `json:"shift_id" gorm:"not null;index"`
+	Shift           *Shift      `json:"shift,omitempty"`
+	RequesterID     uint        `json:"requester_id" gorm:"not null;index"`
+	DepartmentID    uint        `json:"department_id" gorm:"not null;index"`
+	Note            string      `json:"note"`
+	AcceptedOfferID *uint       `json:"accepted_offer_id"`
+	DecisionNote    string      `json:"decision_note"`
+	DecidedByID     *uint       `json:"decided_by_id"`
+	DecidedAt       *time.Time  `json:"decided_at"`
+	Offers          []SwapOffer `json:"offers,omitempty"`
+}
+
+// SwapOffer ist die Antwort eines Kollegen auf eine Tauschanfrage: die Übernahme
+// der Schicht oder ein Gegenangebot mit einer eigenen Schicht
+type SwapOffer struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	SwapRequestID uint      `json:"swap_request_id" gorm:"not null;index"`
+	UserID        uint      `json:"user_id" gorm:"not null;index"`
+	ShiftID       *uint     `json:"shift_id"` // own shift offered in return, nil to simply take over
+	Shift         *Shift    `json:"shift,omitempty"`
+	Status        string    `json:"status" gorm:"not null" example:"offered"`
+	Note          string    `json:"note"`
+}
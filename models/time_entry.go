@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Time entry sources
+const (
+	TimeEntryClock  = "clock"  // recorded with clock in and clock out
+	TimeEntryManual = "manual" // entered afterwards
+)
+
+// TimeEntry ist eine tatsächlich gearbeitete Zeit eines Mitarbeiters, getrennt
+// von der geplanten Schicht, auf die sie sich bezieht
+type TimeEntry struct {
+	ID           uint             `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	DeletedAt    *time.Time       `gorm:"index" json:"deleted_at"`
+	UserID       uint             `json:"user_id" gorm:"not null;index"`
+	ShiftID      *uint            `json:"shift_id" gorm:"index"` // planned shift, nil for unplanned work
+	ClockIn      time.Time        `json:"clock_in" gorm:"not null;index"`
+	ClockOut     *time.Time       `json:"clock_out" gorm:"index"` // nil while clocked in
+	BreakMinutes uint             `json:"break_minutes"`
+	Source       string           `json:"source" gorm:"not null" example:"clock"`
+	Note         string           `json:"note"`
+	Breaks       []TimeBreak      `json:"breaks,omitempty"`
+	Corrections  []TimeCorrection `json:"corrections,omitempty"`
+}
+
+// TimeBreak ist eine gestempelte Pause innerhalb eines Zeiteintrags
+type TimeBreak struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	TimeEntryID uint       `json:"time_entry_id" gorm:"not null;index"`
+	Start       time.Time  `json:"start" gorm:"not null"`
+	End         *time.Time `json:"end"` // nil while on break
+}
+
+// TimeCorrection ist eine nachträgliche Änderung eines Zeiteintrags mit Begründung
+type TimeCorrection struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	TimeEntryID     uint       `json:"time_entry_id" gorm:"not null;index"`
+	CorrectedByID   uint       `json:"corrected_by_id"`
+	Reason          string     `json:"reason" gorm:"not null"`
+	OldClockIn      time.Time  `json:"old_clock_in"`
+	OldClockOut     *time.Time `json:"old_clock_out"`
+	OldBreakMinutes uint       `json:"old_break_minutes"`
+	OldShiftID      *uint      `json:"old_shift_id"`
+	NewClockIn      time.Time  `json:"new_clock_in"`
+	NewClockOut     *time.Time `json:"new_clock_out"`
+	NewBreakMinutes uint       `json:"new_break_minutes"`
+	NewShiftID      *uint      `json:"new_shift_id"`
+}
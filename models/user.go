@@ -3,16 +3,20 @@ package models
 import "time"
 
 type User struct {
-	ID          uint         `gorm:"primarykey" json:"id"`
-	CreatedAt   time.Time    `json:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at"`
-	DeletedAt   *time.Time   `gorm:"index" json:"deleted_at"`
-	FirstName   string       `json:"first_name" gorm:"not null"`
-	LastName    string       `json:"last_name" gorm:"not null"`
-	Email       string       `json:"email" gorm:"unique;not null"`
-	Password    string       `json:"password" gorm:"not null"`
-	Color       string       `json:"color" gorm:"not null"`
-	IsAdmin     bool         `json:"is_admin" gorm:"default:false"`
-	Departments []Department `json:"departments" gorm:"many2many:user_departments;"`
-	Shifts      []Shift      `json:"shifts" gorm:"foreignKey:UserID"`
+	ID              uint            `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	DeletedAt       *time.Time      `gorm:"index" json:"deleted_at"`
+	FirstName       string          `json:"first_name" gorm:"not null"`
+	LastName        string          `json:"last_name" gorm:"not null"`
+	Email           string          `json:"email" gorm:"unique;not null"`
+	Password        string          `json:"-" gorm:"not null"` // bcrypt hash, never serialized
+	Color           string          `json:"color" gorm:"not null"`
+	IsAdmin         bool            `json:"is_admin" gorm:"default:false"`
+	WeeklyHours     float64         `json:"weekly_hours" example:"40"`       // working time on days without contract, 0 without working time account
+	BirthDate       string          `json:"birth_date" example:"2008-04-01"` // for the protection of minors, may be empty
+	PersonnelNumber string          `json:"personnel_number" example:"1001"` // of the payroll accounting, may be empty
+	Departments     []Department    `json:"departments" gorm:"many2many:user_departments;"`
+	Shifts          []Shift         `json:"shifts" gorm:"foreignKey:UserID"`
+	Qualifications  []Qualification `json:"qualifications,omitempty" gorm:"many2many:user_qualifications;"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Roles of a user within a department. Administrators (User.IsAdmin) may do everything.
+const (
+	RoleManager  = "manager"
+	RoleEmployee = "employee"
+	RoleViewer   = "viewer"
+)
+
+// UserDepartment ist die Zugehörigkeit eines Benutzers zu einer Abteilung mit seiner Rolle dort
+type UserDepartment struct {
+	UserID       uint      `json:"user_id" gorm:"primaryKey"`
+	DepartmentID uint      `json:"department_id" gorm:"primaryKey"`
+	Role         string    `json:"role" gorm:"not null;default:employee" example:"employee"`
+	CreatedAt    time.Time `json:"created_at"`
+}
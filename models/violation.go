@@ -0,0 +1,17 @@
+package models
+
+const (
+	// SeverityViolation marks a rule breach that blocks saving unless forced
+	SeverityViolation = "violation"
+	// SeverityWarning marks a rule breach that is only reported
+	SeverityWarning = "warning"
+)
+
+// Violation beschreibt einen Verstoß gegen eine Planungsregel
+type Violation struct {
+	Rule     string `json:"rule" example:"rest_period"`
+	Severity string `json:"severity" example:"violation"`
+	UserID   uint   `json:"user_id"`
+	ShiftIDs []uint `json:"shift_ids"`
+	Message  string `json:"message" example:"Rest period of 11h not met"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// WorkTimeClosing ist der Monatsabschluss des Arbeitszeitkontos eines
+// Mitarbeiters. Die Werte werden beim Abschluss festgeschrieben, der Übertrag
+// ist der Anfangssaldo des Folgemonats.
+type WorkTimeClosing struct {
+	ID              uint      `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	UserID          uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_work_time_closing_user_month"`
+	Month           string    `json:"month" gorm:"not null;uniqueIndex:idx_work_time_closing_user_month" example:"2024-05"`
+	OpeningBalance  float64   `json:"opening_balance"`
+	TargetHours     float64   `json:"target_hours"`
+	PlannedHours    float64   `json:"planned_hours"`
+	ActualHours     float64   `json:"actual_hours"`
+	AdjustmentHours float64   `json:"adjustment_hours"`
+	Balance         float64   `json:"balance"`
+	CarryOver       float64   `json:"carry_over"` // balance after capping, see WORKTIME_MAX_CARRY_OVER_HOURS
+	ClosedByID      uint      `json:"closed_by_id" gorm:"not null"`
+}
+
+// WorkTimeAdjustment ist eine manuelle Buchung auf das Arbeitszeitkonto, z.B.
+// ausgezahlte Überstunden (negativ) oder ein übernommener Saldo (positiv)
+type WorkTimeAdjustment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	Date        string    `json:"date" gorm:"not null;index" example:"2024-05-31"`
+	Hours       float64   `json:"hours" gorm:"not null" example:"-8"`
+	Reason      string    `json:"reason" gorm:"not null"`
+	CreatedByID uint      `json:"created_by_id" gorm:"not null"`
+}
@@ -0,0 +1,55 @@
+package payroll
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Record is the statement of a user for the DATEV export
+type Record struct {
+	PersonnelNumber string
+	Statement       Statement
+}
+
+// DATEV encodes the statements of a month as movement data for the ASCII
+// import of DATEV Lohn und Gehalt. The first line holds consultant number,
+// client number and the month (MM/YYYY), every further line personnel number,
+// wage type, hours and amount with decimal comma: the hours worked with the
+// base wage, the hours of each surcharge with its tax free amount and the
+// taxable part of all surcharges of the user. The file is encoded in
+// Windows-1252 like DATEV expects.
+func DATEV(cfg Config, month time.Time, records []Record) ([]byte, error) {
+	var b bytes.Buffer
+	writer := csv.NewWriter(&b)
+	writer.Comma = ';'
+	writer.UseCRLF = true
+
+	rows := [][]string{{cfg.ConsultantNumber, cfg.ClientNumber, month.Format("01/2006")}}
+	for _, r := range records {
+		s := r.Statement
+		if s.Hours.Total == 0 {
+			continue
+		}
+		rows = append(rows, []string{r.PersonnelNumber, cfg.WageTypes[WageTypeHours], decimal(s.Hours.Total), decimal(s.BaseWage)})
+		for _, surcharge := range s.Surcharges {
+			rows = append(rows, []string{r.PersonnelNumber, cfg.WageTypes[surcharge.Kind], decimal(surcharge.Hours), decimal(surcharge.TaxFree)})
+		}
+		if s.Taxable > 0 {
+			rows = append(rows, []string{r.PersonnelNumber, cfg.WageTypes[WageTypeTaxable], "", decimal(s.Taxable)})
+		}
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return charmap.Windows1252.NewEncoder().Bytes(b.Bytes())
+}
+
+// decimal formats n with two decimals and decimal comma
+func decimal(n float64) string {
+	return strings.Replace(strconv.FormatFloat(n, 'f', 2, 64), ".", ",", 1)
+}
@@ -0,0 +1,51 @@
+package payroll
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDATEV(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConsultantNumber = "12345"
+	cfg.ClientNumber = "678"
+	records := []Record{
+		{PersonnelNumber: "1001", Statement: Statement{
+			Hours:    Hours{Total: 8.5},
+			BaseWage: 170,
+			Surcharges: []Surcharge{
+				{Kind: Night, Hours: 2, Amount: 12, TaxFree: 10, Taxable: 2},
+			},
+			Taxable: 2,
+		}},
+		// users without hours are left out
+		{PersonnelNumber: "1002"},
+		{PersonnelNumber: "1003", Statement: Statement{Hours: Hours{Total: 1.25}, BaseWage: 15.63}},
+	}
+
+	data, err := DATEV(cfg, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), records)
+	if err != nil {
+		t.Fatalf("DATEV() error = %v", err)
+	}
+	want := "12345;678;05/2024\r\n" +
+		"1001;1000;8,50;170,00\r\n" +
+		"1001;1100;2,00;10,00\r\n" +
+		"1001;1190;;2,00\r\n" +
+		"1003;1000;1,25;15,63\r\n"
+	if string(data) != want {
+		t.Errorf("DATEV() = %q, want %q", data, want)
+	}
+}
+
+func TestDATEVEncoding(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClientNumber = "Müller"
+	data, err := DATEV(cfg, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), nil)
+	if err != nil {
+		t.Fatalf("DATEV() error = %v", err)
+	}
+	// ü is a single byte in Windows-1252
+	if want := ";M\xfcller;05/2024\r\n"; string(data) != want {
+		t.Errorf("DATEV() = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,345 @@
+// Package payroll splits worked hours into normal hours and hours with night,
+// Sunday and holiday surcharges and computes the surcharges with the part that
+// is tax free under §3b EStG.
+package payroll
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/contract"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// Surcharge kinds
+const (
+	Night          = "night"
+	EarlyNight     = "early_night"     // 0 to 4 o'clock if the work began before midnight
+	Sunday         = "sunday"          // including 0 to 4 o'clock of the following day
+	Holiday        = "holiday"         // public holidays and 31.12. from 14 o'clock
+	SpecialHoliday = "special_holiday" // 24.12. from 14 o'clock, 25.12., 26.12. and 1.5.
+)
+
+// Kinds lists the surcharge kinds in the order of the exports
+var Kinds = []string{Night, EarlyNight, Sunday, Holiday, SpecialHoliday}
+
+// TaxFreeRates are the surcharges in percent of the basic wage that are tax
+// free under §3b EStG. Night surcharges add to the Sunday and holiday ones,
+// holiday surcharges replace the Sunday one.
+var TaxFreeRates = map[string]float64{
+	Night:          25,
+	EarlyNight:     40,
+	Sunday:         50,
+	Holiday:        125,
+	SpecialHoliday: 150,
+}
+
+// MaxTaxFreeWage is the hourly basic wage up to which surcharges are tax free (§3b Abs. 2 EStG)
+const MaxTaxFreeWage = 50.0
+
+// Config holds the surcharges paid and the settings of the DATEV export
+type Config struct {
+	NightStart int                `json:"night_start"` // hour the paid night begins
+	NightEnd   int                `json:"night_end"`   // hour the paid night ends
+	Rates      map[string]float64 `json:"rates"`       // paid surcharges in percent of the hourly wage per kind
+	// WageTypes holds the DATEV wage types per kind, for the hours worked
+	// (WageTypeHours) and the taxable part of the surcharges (WageTypeTaxable)
+	WageTypes        map[string]string `json:"wage_types"`
+	ConsultantNumber string            `json:"consultant_number"`
+	ClientNumber     string            `json:"client_number"`
+
+	Location *time.Location `json:"-"`
+}
+
+// Wage types besides the surcharge kinds
+const (
+	WageTypeHours   = "hours"
+	WageTypeTaxable = "taxable"
+)
+
+// DefaultConfig pays the tax free surcharges for night work from 20 to 6 o'clock
+func DefaultConfig() Config {
+	rates := map[string]float64{}
+	for kind, rate := range TaxFreeRates {
+		rates[kind] = rate
+	}
+	return Config{
+		NightStart: 20,
+		NightEnd:   6,
+		Rates:      rates,
+		WageTypes: map[string]string{
+			WageTypeHours:   "1000",
+			Night:           "1100",
+			EarlyNight:      "1110",
+			Sunday:          "1120",
+			Holiday:         "1130",
+			SpecialHoliday:  "1140",
+			WageTypeTaxable: "1190",
+		},
+		Location: time.UTC,
+	}
+}
+
+// ConfigFromEnv reads the settings from the environment: PAYROLL_NIGHT_START
+// and PAYROLL_NIGHT_END take hours, PAYROLL_RATE_<KIND> the paid surcharges
+// in percent, DATEV_WAGE_TYPE_<KIND|HOURS|TAXABLE> the wage types and
+// DATEV_CONSULTANT_NUMBER and DATEV_CLIENT_NUMBER the header of the export.
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	cfg.Location = config.Location()
+
+	if v, err := strconv.Atoi(os.Getenv("PAYROLL_NIGHT_START")); err == nil && v >= 0 && v < 24 {
+		cfg.NightStart = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PAYROLL_NIGHT_END")); err == nil && v >= 0 && v < 24 {
+		cfg.NightEnd = v
+	}
+	for _, kind := range Kinds {
+		if v, err := strconv.ParseFloat(os.Getenv("PAYROLL_RATE_"+strings.ToUpper(kind)), 64); err == nil && v >= 0 {
+			cfg.Rates[kind] = v
+		}
+	}
+	for name := range cfg.WageTypes {
+		if v := strings.TrimSpace(os.Getenv("DATEV_WAGE_TYPE_" + strings.ToUpper(name))); v != "" {
+			cfg.WageTypes[name] = v
+		}
+	}
+	cfg.ConsultantNumber = os.Getenv("DATEV_CONSULTANT_NUMBER")
+	cfg.ClientNumber = os.Getenv("DATEV_CLIENT_NUMBER")
+	return cfg
+}
+
+// Period is a worked time span. Stamped breaks are deducted at the time they
+// were taken; break minutes beyond them are deducted proportionally from all
+// hours of the span since it is not known when they were taken.
+type Period struct {
+	Start        time.Time
+	End          time.Time
+	BreakMinutes uint
+	Breaks       []Break
+}
+
+// Break is a stamped break within a period
+type Break struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Hours splits the worked hours. Night hours may also be Sunday or holiday
+// hours since their surcharges add up, normal hours earn no surcharge.
+type Hours struct {
+	Total   float64 `json:"total"`
+	Normal  float64 `json:"normal"`
+	Night   float64 `json:"night"`   // including early night
+	Sunday  float64 `json:"sunday"`  // Sundays that are no holidays
+	Holiday float64 `json:"holiday"` // including special holidays
+}
+
+// Surcharge is the sum of a surcharge kind
+type Surcharge struct {
+	Kind    string  `json:"kind" example:"night"`
+	Hours   float64 `json:"hours"`
+	Rate    float64 `json:"rate" example:"25"` // paid, in percent
+	Amount  float64 `json:"amount"`            // euros
+	TaxFree float64 `json:"tax_free"`          // part of the amount within the limits of §3b EStG
+	Taxable float64 `json:"taxable"`
+}
+
+// Statement is the payroll of a user for a period
+type Statement struct {
+	Hours            Hours       `json:"hours"`
+	BaseWage         float64     `json:"base_wage"` // worked hours times the hourly wage
+	Surcharges       []Surcharge `json:"surcharges"`
+	TaxFree          float64     `json:"tax_free"`
+	Taxable          float64     `json:"taxable"`            // of the surcharges
+	MissingWageHours float64     `json:"missing_wage_hours"` // worked without contract with hourly wage
+}
+
+// Compute sums the periods up. The hourly wage is taken from the contract valid
+// at the time worked, isHoliday reports the public holidays of the user.
+func Compute(cfg Config, periods []Period, contracts []models.Contract, isHoliday func(time.Time) bool) Statement {
+	loc := cfg.Location
+	surcharges := map[string]*Surcharge{}
+	var s Statement
+	for _, p := range periods {
+		span := p.End.Sub(p.Start)
+		if span <= 0 {
+			continue
+		}
+		stamped := p.paused(p.Start, p.End)
+		if stamped >= span {
+			continue
+		}
+		rest := time.Duration(p.BreakMinutes)*time.Minute - stamped
+		if rest < 0 {
+			rest = 0
+		}
+		share := 1 - rest.Hours()/(span-stamped).Hours()
+		if share <= 0 {
+			continue
+		}
+		start := p.Start.In(loc)
+		// all surcharges change on full hours, so the period is split into hours
+		for t := start; t.Before(p.End); {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if next.After(p.End) {
+				next = p.End.In(loc)
+			}
+			hours := (next.Sub(t) - p.paused(t, next)).Hours() * share
+			wage := 0.0
+			if c := contract.ValidAt(contracts, t, loc); c != nil {
+				wage = c.HourlyWage
+			}
+			if wage == 0 {
+				s.MissingWageHours += hours
+			}
+			s.Hours.Total += hours
+			s.BaseWage += hours * wage
+
+			kinds := cfg.kinds(t, start, isHoliday)
+			if len(kinds) == 0 {
+				s.Hours.Normal += hours
+			}
+			for _, kind := range kinds {
+				switch kind {
+				case Night, EarlyNight:
+					s.Hours.Night += hours
+				case Sunday:
+					s.Hours.Sunday += hours
+				default:
+					s.Hours.Holiday += hours
+				}
+				sum, ok := surcharges[kind]
+				if !ok {
+					sum = &Surcharge{Kind: kind, Rate: cfg.Rates[kind]}
+					surcharges[kind] = sum
+				}
+				amount := hours * wage * sum.Rate / 100
+				taxFree := 0.0
+				// the tax free night is fixed from 20 to 6 o'clock
+				if kind != Night || t.Hour() >= 20 || t.Hour() < 6 {
+					taxFree = math.Min(amount, hours*math.Min(wage, MaxTaxFreeWage)*TaxFreeRates[kind]/100)
+				}
+				sum.Hours += hours
+				sum.Amount += amount
+				sum.TaxFree += taxFree
+			}
+			t = next
+		}
+	}
+
+	s.Hours = Hours{
+		Total:   round(s.Hours.Total),
+		Normal:  round(s.Hours.Normal),
+		Night:   round(s.Hours.Night),
+		Sunday:  round(s.Hours.Sunday),
+		Holiday: round(s.Hours.Holiday),
+	}
+	s.BaseWage = round(s.BaseWage)
+	s.MissingWageHours = round(s.MissingWageHours)
+	s.Surcharges = []Surcharge{}
+	for _, kind := range Kinds {
+		sum, ok := surcharges[kind]
+		if !ok {
+			continue
+		}
+		sum.Hours = round(sum.Hours)
+		sum.Amount = round(sum.Amount)
+		sum.TaxFree = round(sum.TaxFree)
+		sum.Taxable = round(sum.Amount - sum.TaxFree)
+		s.TaxFree = round(s.TaxFree + sum.TaxFree)
+		s.Taxable = round(s.Taxable + sum.Taxable)
+		s.Surcharges = append(s.Surcharges, *sum)
+	}
+	return s
+}
+
+// paused returns how much of [from, to) falls into the stamped breaks
+func (p Period) paused(from, to time.Time) time.Duration {
+	var total time.Duration
+	for _, b := range p.Breaks {
+		start, end := b.Start, b.End
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// kinds returns the surcharges for work in the hour beginning at t of a period
+// beginning at start
+func (cfg Config) kinds(t, start time.Time, isHoliday func(time.Time) bool) []string {
+	var kinds []string
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// from 0 to 4 o'clock work that began before midnight still counts for the day before
+	carried := t.Hour() < 4 && start.Before(day)
+	if cfg.isNight(t.Hour()) {
+		if carried {
+			kinds = append(kinds, EarlyNight)
+		} else {
+			kinds = append(kinds, Night)
+		}
+	}
+
+	kind := dayKind(day, t.Hour(), isHoliday)
+	if carried {
+		if previous := dayKind(day.AddDate(0, 0, -1), 24, isHoliday); rank(previous) > rank(kind) {
+			kind = previous
+		}
+	}
+	if kind != "" {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+func (cfg Config) isNight(hour int) bool {
+	if cfg.NightStart <= cfg.NightEnd {
+		return hour >= cfg.NightStart && hour < cfg.NightEnd
+	}
+	return hour >= cfg.NightStart || hour < cfg.NightEnd
+}
+
+// dayKind returns the Sunday or holiday surcharge of the day at the hour, or an empty string
+func dayKind(day time.Time, hour int, isHoliday func(time.Time) bool) string {
+	switch {
+	case day.Month() == time.December && (day.Day() == 25 || day.Day() == 26), day.Month() == time.May && day.Day() == 1:
+		return SpecialHoliday
+	case day.Month() == time.December && day.Day() == 24 && hour >= 14:
+		return SpecialHoliday
+	case day.Month() == time.December && day.Day() == 31 && hour >= 14:
+		return Holiday
+	case isHoliday != nil && isHoliday(day):
+		return Holiday
+	case day.Weekday() == time.Sunday:
+		return Sunday
+	}
+	return ""
+}
+
+func rank(kind string) int {
+	switch kind {
+	case SpecialHoliday:
+		return 3
+	case Holiday:
+		return 2
+	case Sunday:
+		return 1
+	}
+	return 0
+}
+
+// round rounds to cents and hundredths of an hour
+func round(n float64) float64 {
+	return math.Round(n*100) / 100
+}
@@ -0,0 +1,135 @@
+package payroll
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// at parses a local time in Berlin
+func at(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, berlin)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func period(start, end string, breakMinutes uint, breaks ...Break) Period {
+	return Period{Start: at(start).UTC(), End: at(end).UTC(), BreakMinutes: breakMinutes, Breaks: breaks}
+}
+
+func stamped(start, end string) Break {
+	return Break{Start: at(start).UTC(), End: at(end).UTC()}
+}
+
+func wage(hourlyWage float64) []models.Contract {
+	return []models.Contract{{HourlyWage: hourlyWage, ValidFrom: "2024-01-01"}}
+}
+
+func TestComputeHours(t *testing.T) {
+	ascension := func(day time.Time) bool { return day.Format("2006-01-02") == "2024-05-09" }
+	tests := []struct {
+		name   string
+		period Period
+		want   Hours
+	}{
+		{"weekday", period("2024-05-06 08:00", "2024-05-06 16:30", 30), Hours{Total: 8, Normal: 8}},
+		{"evening", period("2024-05-06 18:00", "2024-05-06 23:00", 0), Hours{Total: 5, Normal: 2, Night: 3}},
+		// the break of 30 minutes is deducted proportionally from all hours
+		{"evening with break", period("2024-05-06 18:00", "2024-05-06 23:00", 30), Hours{Total: 4.5, Normal: 1.8, Night: 2.7}},
+		{"evening with stamped break", period("2024-05-06 18:00", "2024-05-06 23:00", 30, stamped("2024-05-06 22:00", "2024-05-06 22:30")), Hours{Total: 4.5, Normal: 2, Night: 2.5}},
+		// 15 minutes beyond the stamped break are deducted proportionally
+		{"longer break than stamped", period("2024-05-06 18:00", "2024-05-06 23:00", 45, stamped("2024-05-06 18:00", "2024-05-06 18:30")), Hours{Total: 4.25, Normal: 1.42, Night: 2.83}},
+		{"stamped break longer than the break minutes", period("2024-05-06 18:00", "2024-05-06 23:00", 0, stamped("2024-05-06 19:00", "2024-05-06 20:00")), Hours{Total: 4, Normal: 1, Night: 3}},
+		{"break covering the period", period("2024-05-06 08:00", "2024-05-06 08:30", 30), Hours{}},
+		{"Sunday", period("2024-05-12 08:00", "2024-05-12 12:00", 0), Hours{Total: 4, Sunday: 4}},
+		{"night into Sunday", period("2024-05-11 22:00", "2024-05-12 02:00", 0), Hours{Total: 4, Night: 4, Sunday: 2}},
+		{"holiday", period("2024-05-09 08:00", "2024-05-09 12:00", 0), Hours{Total: 4, Holiday: 4}},
+		{"Christmas Eve afternoon", period("2024-12-24 12:00", "2024-12-24 16:00", 0), Hours{Total: 4, Normal: 2, Holiday: 2}},
+	}
+	cfg := DefaultConfig()
+	cfg.Location = berlin
+	for _, tt := range tests {
+		if got := Compute(cfg, []Period{tt.period}, wage(20), ascension).Hours; got != tt.want {
+			t.Errorf("%s: Hours = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestComputeSurcharges(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(*Config)
+		hourlyWage float64
+		period     Period
+		want       []Surcharge
+	}{
+		{"night", nil, 20, period("2024-05-06 20:00", "2024-05-06 22:00", 0),
+			[]Surcharge{{Kind: Night, Hours: 2, Rate: 25, Amount: 10, TaxFree: 10}}},
+		{"early night and Sunday", nil, 20, period("2024-05-11 23:00", "2024-05-12 01:00", 0), []Surcharge{
+			{Kind: Night, Hours: 1, Rate: 25, Amount: 5, TaxFree: 5},
+			{Kind: EarlyNight, Hours: 1, Rate: 40, Amount: 8, TaxFree: 8},
+			{Kind: Sunday, Hours: 1, Rate: 50, Amount: 10, TaxFree: 10},
+		}},
+		{"special holiday", nil, 20, period("2024-12-25 08:00", "2024-12-25 10:00", 0),
+			[]Surcharge{{Kind: SpecialHoliday, Hours: 2, Rate: 150, Amount: 60, TaxFree: 60}}},
+		// work begun on New Year's Eve keeps its holiday surcharge until 4 o'clock
+		{"holiday carried past midnight", nil, 20, period("2024-12-31 22:00", "2025-01-01 02:00", 0), []Surcharge{
+			{Kind: Night, Hours: 2, Rate: 25, Amount: 10, TaxFree: 10},
+			{Kind: EarlyNight, Hours: 2, Rate: 40, Amount: 16, TaxFree: 16},
+			{Kind: Holiday, Hours: 4, Rate: 125, Amount: 100, TaxFree: 100},
+		}},
+		{"wage above the tax free limit", nil, 60, period("2024-05-06 20:00", "2024-05-06 22:00", 0),
+			[]Surcharge{{Kind: Night, Hours: 2, Rate: 25, Amount: 30, TaxFree: 25, Taxable: 5}}},
+		{"rate above the tax free rate", func(cfg *Config) { cfg.Rates[Night] = 30 }, 20, period("2024-05-06 20:00", "2024-05-06 22:00", 0),
+			[]Surcharge{{Kind: Night, Hours: 2, Rate: 30, Amount: 12, TaxFree: 10, Taxable: 2}}},
+		// the tax free night begins at 20 o'clock whatever night is paid
+		{"paid night before 20 o'clock", func(cfg *Config) { cfg.NightStart = 19 }, 20, period("2024-05-06 19:00", "2024-05-06 21:00", 0),
+			[]Surcharge{{Kind: Night, Hours: 2, Rate: 25, Amount: 10, TaxFree: 5, Taxable: 5}}},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Location = berlin
+		if tt.configure != nil {
+			tt.configure(&cfg)
+		}
+		if got := Compute(cfg, []Period{tt.period}, wage(tt.hourlyWage), nil).Surcharges; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Surcharges = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestComputeStatement(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Location = berlin
+	periods := []Period{
+		period("2024-05-06 08:00", "2024-05-06 12:00", 0),
+		period("2024-05-06 20:00", "2024-05-06 22:00", 0),
+		// after the end of the contract
+		period("2024-05-07 08:00", "2024-05-07 10:00", 0),
+	}
+	contracts := []models.Contract{{HourlyWage: 20, ValidFrom: "2024-01-01", ValidTo: "2024-05-06"}}
+	s := Compute(cfg, periods, contracts, nil)
+	if s.Hours.Total != 8 || s.BaseWage != 120 || s.MissingWageHours != 2 {
+		t.Errorf("total %g, base wage %g and missing wage hours %g, want 8, 120 and 2", s.Hours.Total, s.BaseWage, s.MissingWageHours)
+	}
+	if s.TaxFree != 10 || s.Taxable != 0 {
+		t.Errorf("tax free %g and taxable %g, want 10 and 0", s.TaxFree, s.Taxable)
+	}
+	if empty := Compute(cfg, nil, contracts, nil); empty.Surcharges == nil || empty.Hours.Total != 0 {
+		t.Errorf("Compute() without periods = %+v, want an empty statement", empty)
+	}
+}
@@ -0,0 +1,245 @@
+// Package pdf writes simple PDF documents with rectangles, lines and text in
+// the standard Helvetica fonts, which every PDF viewer provides, so no font
+// files have to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Page sizes in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font is one of the standard fonts
+type Font int
+
+// Standard fonts
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Color is an RGB color with components between 0 and 1
+type Color struct {
+	R, G, B float64
+}
+
+// Common colors
+var (
+	Black = Color{0, 0, 0}
+	White = Color{1, 1, 1}
+)
+
+// ParseColor reads a CSS hex color (#rgb or #rrggbb)
+func ParseColor(value string) (Color, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return Color{}, false
+	}
+	n, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return Color{}, false
+	}
+	return Color{float64(n>>16) / 255, float64(n>>8&0xff) / 255, float64(n&0xff) / 255}, true
+}
+
+// Lighten mixes the color with white, amount 1 gives white
+func (c Color) Lighten(amount float64) Color {
+	return Color{c.R + (1-c.R)*amount, c.G + (1-c.G)*amount, c.B + (1-c.B)*amount}
+}
+
+// Contrast returns black or white, whichever is better readable on the color
+func (c Color) Contrast() Color {
+	if 0.299*c.R+0.587*c.G+0.114*c.B > 0.6 {
+		return Black
+	}
+	return White
+}
+
+// Document is a PDF document of pages with the same size
+type Document struct {
+	Title  string
+	width  float64
+	height float64
+	pages  []*Page
+}
+
+// New creates an empty document with pages of the given size in points
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// Page is a page of a document. Coordinates are given in points from the top
+// left corner of the page.
+type Page struct {
+	height  float64
+	content bytes.Buffer
+}
+
+// AddPage appends a new empty page
+func (d *Document) AddPage() *Page {
+	page := &Page{height: d.height}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Rect fills a rectangle
+func (p *Page) Rect(x, y, w, h float64, fill Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", rgb(fill), num(x), num(p.height-y-h), num(w), num(h))
+}
+
+// Frame strokes the outline of a rectangle
+func (p *Page) Frame(x, y, w, h, lineWidth float64, stroke Color) {
+	fmt.Fprintf(&p.content, "%s w %s RG %s %s %s %s re S\n", num(lineWidth), rgb(stroke), num(x), num(p.height-y-h), num(w), num(h))
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, lineWidth float64, stroke Color) {
+	fmt.Fprintf(&p.content, "%s w %s RG %s %s m %s %s l S\n", num(lineWidth), rgb(stroke), num(x1), num(p.height-y1), num(x2), num(p.height-y2))
+}
+
+// Text writes a single line of text with its baseline at y
+func (p *Page) Text(x, y float64, font Font, size float64, color Color, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s rg %s %s Td (%s) Tj ET\n", font+1, num(size), rgb(color), num(x), num(p.height-y), escape(encode(text)))
+}
+
+// Bytes encodes the document
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	var offsets []int
+	object := func(content string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// objects: 1 catalog, 2 page tree, 3 info, then one font each, then page and content per page
+	firstFont := 4
+	firstPage := firstFont + len(fontNames)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object(fmt.Sprintf("<< /Title (%s) /Producer (schichtplaner) >>", escape(encode(d.Title))))
+	var fonts []string
+	for i, name := range fontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, firstFont+i))
+	}
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			num(d.width), num(d.height), strings.Join(fonts, " "), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.Bytes()
+}
+
+// TextWidth returns the width of the text in points
+func TextWidth(font Font, size float64, text string) float64 {
+	widths := helveticaWidths
+	if font == HelveticaBold {
+		widths = helveticaBoldWidths
+	}
+	total := 0
+	for _, c := range encode(text) {
+		switch {
+		case c >= 32 && c < 127:
+			total += widths[c-32]
+		case c == 0x85: // ellipsis
+			total += 1000
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Fit shortens the text with an ellipsis until it is at most width points wide
+func Fit(font Font, size, width float64, text string) string {
+	if TextWidth(font, size, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := strings.TrimSpace(string(runes)) + "…"; TextWidth(font, size, candidate) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// encode converts text to the WinAnsi encoding of the standard fonts,
+// characters outside of it become question marks
+func encode(text string) []byte {
+	encoder := charmap.Windows1252.NewEncoder()
+	var b []byte
+	for _, r := range text {
+		encoded, err := encoder.Bytes([]byte(string(r)))
+		if err != nil || len(encoded) != 1 {
+			encoded = []byte("?")
+		}
+		b = append(b, encoded...)
+	}
+	return b
+}
+
+// escape escapes a string literal
+func escape(text []byte) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`, "\n", `\n`).Replace(string(text))
+}
+
+func rgb(c Color) string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+// num formats a number with at most three decimals
+func num(f float64) string {
+	s := strings.TrimRight(strconv.FormatFloat(f, 'f', 3, 64), "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// widths of the printable ASCII characters in thousandths of the font size
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,184 @@
+package recurrence
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Frequencies supported by a Rule
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// maxIterations guards against runaway expansion of rules without end
+const maxIterations = 100000
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is a subset of the iCalendar RRULE: FREQ, INTERVAL, BYDAY, UNTIL and COUNT
+// plus a list of excluded dates (EXDATE)
+type Rule struct {
+	Frequency  string
+	Interval   int
+	Weekdays   []time.Weekday
+	Until      *time.Time
+	Count      int
+	Exceptions []string // dates as YYYY-MM-DD in the location of the series
+}
+
+// ParseWeekdays converts two letter iCalendar day codes (MO, TU, ...) to weekdays
+func ParseWeekdays(codes []string) ([]time.Weekday, error) {
+	days := make([]time.Weekday, 0, len(codes))
+	for _, code := range codes {
+		day, ok := weekdayCodes[strings.ToUpper(strings.TrimSpace(code))]
+		if !ok {
+			return nil, errors.New("Invalid weekday " + code)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// Validate checks the rule for unsupported or contradicting values
+func (r Rule) Validate() error {
+	switch r.Frequency {
+	case Daily, Weekly, Monthly:
+	default:
+		return errors.New("Frequency must be daily, weekly or monthly")
+	}
+	if r.Interval < 0 {
+		return errors.New("Interval must be positive")
+	}
+	if r.Count < 0 {
+		return errors.New("Count must be positive")
+	}
+	if len(r.Weekdays) > 0 && r.Frequency != Weekly {
+		return errors.New("Weekdays are only supported for weekly frequency")
+	}
+	seen := map[time.Weekday]bool{}
+	for _, day := range r.Weekdays {
+		if seen[day] {
+			return errors.New("Weekdays must not repeat")
+		}
+		seen[day] = true
+	}
+	for _, date := range r.Exceptions {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return errors.New("Invalid exception date " + date)
+		}
+	}
+	return nil
+}
+
+// Between returns the start times of all occurrences of a series starting at
+// dtstart that begin within [from, to). Occurrences keep the wall clock time
+// of dtstart in loc, so they do not drift across daylight saving changes.
+func (r Rule) Between(dtstart, from, to time.Time, loc *time.Location) []time.Time {
+	excluded := map[string]bool{}
+	for _, date := range r.Exceptions {
+		excluded[date] = true
+	}
+
+	var result []time.Time
+	r.each(dtstart, loc, func(n int, t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) && !excluded[t.In(loc).Format("2006-01-02")] {
+			result = append(result, t)
+		}
+		return true
+	})
+	return result
+}
+
+// CountBefore returns how many occurrences (including excluded dates) start before t
+func (r Rule) CountBefore(dtstart, t time.Time, loc *time.Location) int {
+	count := 0
+	r.each(dtstart, loc, func(n int, occurrence time.Time) bool {
+		if !occurrence.Before(t) {
+			return false
+		}
+		count = n
+		return true
+	})
+	return count
+}
+
+// each calls fn with the running number and start of every occurrence in order
+// until fn returns false or the rule ends by UNTIL or COUNT
+func (r Rule) each(dtstart time.Time, loc *time.Location, fn func(n int, t time.Time) bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	local := dtstart.In(loc)
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, local.Hour(), local.Minute(), local.Second(), 0, loc)
+	}
+
+	n := 0
+	emit := func(t time.Time) bool {
+		if t.Before(dtstart) {
+			return true
+		}
+		if r.Until != nil && t.After(*r.Until) {
+			return false
+		}
+		if r.Count > 0 && n >= r.Count {
+			return false
+		}
+		n++
+		return fn(n, t)
+	}
+
+	switch r.Frequency {
+	case Daily:
+		for i := 0; i < maxIterations; i++ {
+			if !emit(at(local.Year(), local.Month(), local.Day()+i*interval)) {
+				return
+			}
+		}
+	case Weekly:
+		days := r.Weekdays
+		if len(days) == 0 {
+			days = []time.Weekday{local.Weekday()}
+		}
+		offsets := make([]int, len(days))
+		for i, day := range days {
+			offsets[i] = (int(day) + 6) % 7
+		}
+		sort.Ints(offsets)
+		monday := local.Day() - (int(local.Weekday())+6)%7
+		for week := 0; week < maxIterations; week++ {
+			for _, offset := range offsets {
+				if !emit(at(local.Year(), local.Month(), monday+week*7*interval+offset)) {
+					return
+				}
+			}
+		}
+	case Monthly:
+		for i := 0; i < maxIterations; i++ {
+			t := at(local.Year(), local.Month()+time.Month(i*interval), local.Day())
+			// months without this day are skipped as in RFC 5545
+			if t.Day() != local.Day() {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
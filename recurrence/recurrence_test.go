@@ -0,0 +1,134 @@
+package recurrence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// at parses a local time in Berlin
+func at(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, berlin)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func format(times []time.Time) []string {
+	var result []string
+	for _, t := range times {
+		result = append(result, t.In(berlin).Format("2006-01-02 15:04"))
+	}
+	return result
+}
+
+func TestParseWeekdays(t *testing.T) {
+	days, err := ParseWeekdays([]string{"MO", " we ", "SU"})
+	if err != nil {
+		t.Fatalf("ParseWeekdays() error = %v", err)
+	}
+	if want := []time.Weekday{time.Monday, time.Wednesday, time.Sunday}; !reflect.DeepEqual(days, want) {
+		t.Errorf("ParseWeekdays() = %v, want %v", days, want)
+	}
+	if _, err := ParseWeekdays([]string{"MO", "XX"}); err == nil {
+		t.Error("ParseWeekdays() accepted an invalid code")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"daily", Rule{Frequency: Daily, Interval: 2, Count: 5}, true},
+		{"weekly with weekdays", Rule{Frequency: Weekly, Weekdays: []time.Weekday{time.Monday, time.Friday}}, true},
+		{"monthly with exceptions", Rule{Frequency: Monthly, Exceptions: []string{"2024-05-01"}}, true},
+		{"unknown frequency", Rule{Frequency: "yearly"}, false},
+		{"negative interval", Rule{Frequency: Daily, Interval: -1}, false},
+		{"negative count", Rule{Frequency: Daily, Count: -1}, false},
+		{"weekdays of a daily rule", Rule{Frequency: Daily, Weekdays: []time.Weekday{time.Monday}}, false},
+		{"repeated weekday", Rule{Frequency: Weekly, Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Monday}}, false},
+		{"invalid exception", Rule{Frequency: Weekly, Exceptions: []string{"2024-02-30"}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() error = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	until := at("2024-05-15 08:00")
+	tests := []struct {
+		name    string
+		rule    Rule
+		dtstart string
+		from    string
+		to      string
+		want    []string
+	}{
+		{"daily every other day with count", Rule{Frequency: Daily, Interval: 2, Count: 3}, "2024-05-01 08:00", "2024-05-01 00:00", "2024-06-01 00:00",
+			[]string{"2024-05-01 08:00", "2024-05-03 08:00", "2024-05-05 08:00"}},
+		{"daily until inclusive", Rule{Frequency: Daily, Interval: 7, Until: &until}, "2024-05-01 08:00", "2024-05-01 00:00", "2024-06-01 00:00",
+			[]string{"2024-05-01 08:00", "2024-05-08 08:00", "2024-05-15 08:00"}},
+		// the series starts on a Wednesday, so the Monday of its first week is skipped
+		{"weekly on weekdays", Rule{Frequency: Weekly, Weekdays: []time.Weekday{time.Friday, time.Monday}}, "2024-05-01 08:00", "2024-05-01 00:00", "2024-05-11 00:00",
+			[]string{"2024-05-03 08:00", "2024-05-06 08:00", "2024-05-10 08:00"}},
+		{"biweekly on the start day", Rule{Frequency: Weekly, Interval: 2, Count: 3}, "2024-05-01 08:00", "2024-05-01 00:00", "2024-07-01 00:00",
+			[]string{"2024-05-01 08:00", "2024-05-15 08:00", "2024-05-29 08:00"}},
+		{"monthly skips short months", Rule{Frequency: Monthly, Count: 3}, "2024-01-31 08:00", "2024-01-01 00:00", "2025-01-01 00:00",
+			[]string{"2024-01-31 08:00", "2024-03-31 08:00", "2024-05-31 08:00"}},
+		{"window", Rule{Frequency: Daily}, "2024-05-01 08:00", "2024-05-10 08:00", "2024-05-12 08:00",
+			[]string{"2024-05-10 08:00", "2024-05-11 08:00"}},
+		{"exceptions", Rule{Frequency: Daily, Count: 3, Exceptions: []string{"2024-05-02"}}, "2024-05-01 08:00", "2024-05-01 00:00", "2024-06-01 00:00",
+			[]string{"2024-05-01 08:00", "2024-05-03 08:00"}},
+		{"wall clock across daylight saving time", Rule{Frequency: Daily}, "2024-03-30 06:00", "2024-03-30 00:00", "2024-04-01 00:00",
+			[]string{"2024-03-30 06:00", "2024-03-31 06:00"}},
+	}
+	for _, tt := range tests {
+		got := format(tt.rule.Between(at(tt.dtstart).UTC(), at(tt.from).UTC(), at(tt.to).UTC(), berlin))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Between() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBetweenKeepsLocalTime(t *testing.T) {
+	rule := Rule{Frequency: Daily}
+	got := rule.Between(at("2024-03-30 06:00"), at("2024-03-30 00:00"), at("2024-04-01 00:00"), berlin)
+	if len(got) != 2 || got[1].Sub(got[0]) != 23*time.Hour {
+		t.Errorf("Between() = %v, want two occurrences 23 hours apart", got)
+	}
+}
+
+func TestCountBefore(t *testing.T) {
+	rule := Rule{Frequency: Weekly, Weekdays: []time.Weekday{time.Monday, time.Wednesday}, Exceptions: []string{"2024-05-06"}}
+	dtstart := at("2024-05-01 08:00")
+	tests := []struct {
+		t    string
+		want int
+	}{
+		{"2024-05-01 08:00", 0},
+		{"2024-05-01 08:01", 1},
+		// excluded dates count as occurrences
+		{"2024-05-08 08:00", 2},
+		{"2024-05-09 00:00", 3},
+	}
+	for _, tt := range tests {
+		if got := rule.CountBefore(dtstart, at(tt.t), berlin); got != tt.want {
+			t.Errorf("CountBefore(%s) = %d, want %d", tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Package roster lays out the shift plan of a department as printable PDF
+// grid of employees and days.
+package roster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/pdf"
+)
+
+// Roster is the plan of a department for consecutive days
+type Roster struct {
+	Title     string
+	Period    string
+	Color     string // of the department, used for the header
+	Days      []Day
+	Rows      []Row
+	Legend    []LegendEntry
+	Notes     []string // printed below the legend, e.g. the public holidays
+	PrintedAt time.Time
+}
+
+// Day is a column of the roster
+type Day struct {
+	Date    time.Time
+	Holiday string // name of the public holiday
+}
+
+// Row is the line of an employee, or of the open shifts
+type Row struct {
+	Name  string
+	Color string
+	Cells [][]Entry // per day
+}
+
+// Entry is a shift or absence within a cell
+type Entry struct {
+	Label  string // shift type name or times
+	Detail string // times, only shown if there is room
+	Color  string
+	Muted  bool // absences are printed without color block
+}
+
+// LegendEntry explains a color
+type LegendEntry struct {
+	Label       string
+	Description string
+	Color       string
+}
+
+// layout of an A4 landscape page in points
+const (
+	pageWidth     = pdf.A4Height
+	pageHeight    = pdf.A4Width
+	margin        = 28.0
+	headerHeight  = 52.0 // title and period above the grid
+	dayRowHeight  = 26.0
+	footerHeight  = 22.0
+	cellPadding   = 2.0
+	legendRow     = 14.0
+	compactDays   = 10 // more days are printed without times
+	wideNameWidth = 130.0
+)
+
+var (
+	gridLine    = pdf.Color{R: 0.7, G: 0.7, B: 0.7}
+	weekendFill = pdf.Color{R: 0.93, G: 0.93, B: 0.93}
+	holidayFill = pdf.Color{R: 0.98, G: 0.9, B: 0.9}
+	mutedText   = pdf.Color{R: 0.35, G: 0.35, B: 0.35}
+	fallback    = pdf.Color{R: 0.55, G: 0.6, B: 0.7}
+)
+
+var weekdays = []string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"}
+
+var months = []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli",
+	"August", "September", "Oktober", "November", "Dezember"}
+
+// PeriodLabel describes the days [from, to) as calendar week, month or date range
+func PeriodLabel(from, to time.Time) string {
+	last := to.AddDate(0, 0, -1)
+	year, week := from.ISOWeek()
+	switch {
+	case from.Weekday() == time.Monday && from.AddDate(0, 0, 7).Equal(to):
+		return fmt.Sprintf("KW %d/%d (%s – %s)", week, year, from.Format("02.01."), last.Format("02.01.2006"))
+	case from.Day() == 1 && from.AddDate(0, 1, 0).Equal(to):
+		return months[from.Month()-1] + " " + from.Format("2006")
+	}
+	return from.Format("02.01.2006") + " – " + last.Format("02.01.2006")
+}
+
+// PDF renders the roster on as many pages as the rows need. Header and day
+// columns are repeated on every page, the legend follows the last row.
+func (r Roster) PDF() []byte {
+	doc := pdf.New(pageWidth, pageHeight)
+	doc.Title = r.Title + " " + r.Period
+
+	compact := len(r.Days) > compactDays
+	nameWidth := wideNameWidth
+	if compact {
+		nameWidth = 100
+	}
+	dayWidth := (pageWidth - 2*margin - nameWidth) / float64(max(len(r.Days), 1))
+	entryHeight := 22.0
+	if compact {
+		entryHeight = 11
+	}
+
+	// split the rows into pages
+	gridTop := margin + headerHeight + dayRowHeight
+	bottom := pageHeight - margin - footerHeight
+	var pages [][]Row
+	var current []Row
+	y := gridTop
+	for _, row := range r.Rows {
+		height := rowHeight(row, entryHeight)
+		if y+height > bottom && len(current) > 0 {
+			pages = append(pages, current)
+			current, y = nil, gridTop
+		}
+		current = append(current, row)
+		y += height
+	}
+	pages = append(pages, current)
+	legendHeight := r.legendHeight()
+	legendOnNewPage := y+legendHeight+12 > bottom
+
+	total := len(pages)
+	if legendOnNewPage {
+		total++
+	}
+	for i, rows := range pages {
+		page := doc.AddPage()
+		r.drawHeader(page, i+1, total)
+		r.drawDays(page, nameWidth, dayWidth)
+		y := gridTop
+		for _, row := range rows {
+			y = r.drawRow(page, row, y, nameWidth, dayWidth, entryHeight, compact)
+		}
+		if !legendOnNewPage && i == len(pages)-1 {
+			r.drawLegend(page, y+12)
+		}
+	}
+	if legendOnNewPage {
+		page := doc.AddPage()
+		r.drawHeader(page, total, total)
+		r.drawLegend(page, margin+headerHeight)
+	}
+	return doc.Bytes()
+}
+
+func (r Roster) drawHeader(page *pdf.Page, number, total int) {
+	color := parseColor(r.Color)
+	page.Rect(margin, margin, 6, 30, color)
+	page.Text(margin+14, margin+14, pdf.HelveticaBold, 16, pdf.Black, r.Title)
+	page.Text(margin+14, margin+29, pdf.Helvetica, 11, mutedText, r.Period)
+	page.Line(margin, margin+headerHeight-10, pageWidth-margin, margin+headerHeight-10, 1.5, color)
+
+	footer := pageHeight - margin - 4
+	page.Text(margin, footer, pdf.Helvetica, 8, mutedText, "Gedruckt am "+r.PrintedAt.Format("02.01.2006 15:04"))
+	text := fmt.Sprintf("Seite %d von %d", number, total)
+	page.Text(pageWidth-margin-pdf.TextWidth(pdf.Helvetica, 8, text), footer, pdf.Helvetica, 8, mutedText, text)
+}
+
+func (r Roster) drawDays(page *pdf.Page, nameWidth, dayWidth float64) {
+	top := margin + headerHeight
+	page.Text(margin+cellPadding, top+dayRowHeight-8, pdf.HelveticaBold, 9, pdf.Black, "Mitarbeiter")
+	for i, day := range r.Days {
+		x := margin + nameWidth + float64(i)*dayWidth
+		if fill, ok := dayFill(day); ok {
+			page.Rect(x, top, dayWidth, dayRowHeight, fill)
+		}
+		label := weekdays[day.Date.Weekday()]
+		date := day.Date.Format("02.01.")
+		if len(r.Days) > compactDays {
+			date = day.Date.Format("2")
+		}
+		page.Text(x+cellPadding, top+10, pdf.HelveticaBold, 8, pdf.Black, label)
+		page.Text(x+cellPadding, top+20, pdf.Helvetica, 8, pdf.Black, date)
+		if day.Holiday != "" && len(r.Days) <= compactDays {
+			page.Text(x+cellPadding+pdf.TextWidth(pdf.Helvetica, 8, date)+4, top+20, pdf.Helvetica, 6.5, mutedText,
+				pdf.Fit(pdf.Helvetica, 6.5, dayWidth-pdf.TextWidth(pdf.Helvetica, 8, date)-4-2*cellPadding, day.Holiday))
+		}
+		page.Line(x, top, x, top+dayRowHeight, 0.5, gridLine)
+	}
+	page.Line(margin, top+dayRowHeight, pageWidth-margin, top+dayRowHeight, 1, pdf.Black)
+}
+
+// drawRow draws a row starting at y and returns the top of the next row
+func (r Roster) drawRow(page *pdf.Page, row Row, y, nameWidth, dayWidth, entryHeight float64, compact bool) float64 {
+	height := rowHeight(row, entryHeight)
+	for i, day := range r.Days {
+		if fill, ok := dayFill(day); ok {
+			page.Rect(margin+nameWidth+float64(i)*dayWidth, y, dayWidth, height, fill)
+		}
+	}
+
+	if color, ok := pdf.ParseColor(row.Color); ok {
+		page.Rect(margin+cellPadding, y+height/2-4, 8, 8, color)
+	}
+	page.Text(margin+cellPadding+12, y+height/2+3, pdf.Helvetica, 9, pdf.Black,
+		pdf.Fit(pdf.Helvetica, 9, nameWidth-cellPadding*2-14, row.Name))
+
+	for i, entries := range row.Cells {
+		x := margin + nameWidth + float64(i)*dayWidth
+		for j, entry := range entries {
+			top := y + cellPadding + float64(j)*(entryHeight+cellPadding)
+			width := dayWidth - 2*cellPadding
+			size := 7.5
+			if compact {
+				size = 6
+			}
+			text := pdf.Black
+			if entry.Muted {
+				text = mutedText
+				page.Frame(x+cellPadding, top, width, entryHeight, 0.5, gridLine)
+			} else {
+				fill := parseColor(entry.Color)
+				page.Rect(x+cellPadding, top, width, entryHeight, fill)
+				text = fill.Contrast()
+			}
+			if compact || entry.Detail == "" {
+				page.Text(x+cellPadding+1.5, top+entryHeight/2+size/2-1, pdf.HelveticaBold, size, text,
+					pdf.Fit(pdf.HelveticaBold, size, width-3, entry.Label))
+				continue
+			}
+			page.Text(x+cellPadding+2, top+9, pdf.HelveticaBold, size, text, pdf.Fit(pdf.HelveticaBold, size, width-4, entry.Label))
+			page.Text(x+cellPadding+2, top+18, pdf.Helvetica, 6.5, text, pdf.Fit(pdf.Helvetica, 6.5, width-4, entry.Detail))
+		}
+	}
+
+	page.Line(margin, y+height, pageWidth-margin, y+height, 0.5, gridLine)
+	for i := range r.Days {
+		x := margin + nameWidth + float64(i)*dayWidth
+		page.Line(x, y, x, y+height, 0.5, gridLine)
+	}
+	return y + height
+}
+
+func (r Roster) legendHeight() float64 {
+	if len(r.Legend) == 0 && len(r.Notes) == 0 {
+		return 0
+	}
+	lines := 1 + len(r.Notes)
+	x := margin
+	for _, entry := range r.Legend {
+		width := legendWidth(entry)
+		if x+width > pageWidth-margin {
+			lines++
+			x = margin
+		}
+		x += width
+	}
+	return float64(lines+1) * legendRow
+}
+
+func (r Roster) drawLegend(page *pdf.Page, y float64) {
+	if len(r.Legend) == 0 && len(r.Notes) == 0 {
+		return
+	}
+	page.Text(margin, y+9, pdf.HelveticaBold, 9, pdf.Black, "Legende")
+	y += legendRow
+	x := margin
+	for _, entry := range r.Legend {
+		width := legendWidth(entry)
+		if x+width > pageWidth-margin {
+			x, y = margin, y+legendRow
+		}
+		if entry.Color != "" {
+			page.Rect(x, y+1, 10, 10, parseColor(entry.Color))
+		} else {
+			page.Frame(x, y+1, 10, 10, 0.5, gridLine)
+		}
+		page.Text(x+14, y+9, pdf.HelveticaBold, 8, pdf.Black, entry.Label)
+		page.Text(x+18+pdf.TextWidth(pdf.HelveticaBold, 8, entry.Label), y+9, pdf.Helvetica, 8, mutedText, entry.Description)
+		x += width
+	}
+	for _, note := range r.Notes {
+		y += legendRow
+		page.Text(margin, y+9, pdf.Helvetica, 8, mutedText, note)
+	}
+}
+
+func legendWidth(entry LegendEntry) float64 {
+	return 14 + pdf.TextWidth(pdf.HelveticaBold, 8, entry.Label) + 4 + pdf.TextWidth(pdf.Helvetica, 8, entry.Description) + 18
+}
+
+// rowHeight fits the most entries on a day of the row
+func rowHeight(row Row, entryHeight float64) float64 {
+	entries := 1
+	for _, cell := range row.Cells {
+		entries = max(entries, len(cell))
+	}
+	return max(float64(entries)*(entryHeight+cellPadding)+cellPadding, 20)
+}
+
+// dayFill returns the background of weekends and holidays
+func dayFill(day Day) (pdf.Color, bool) {
+	switch {
+	case day.Holiday != "":
+		return holidayFill, true
+	case day.Date.Weekday() == time.Saturday || day.Date.Weekday() == time.Sunday:
+		return weekendFill, true
+	}
+	return pdf.Color{}, false
+}
+
+func parseColor(value string) pdf.Color {
+	if color, ok := pdf.ParseColor(value); ok {
+		return color
+	}
+	return fallback
+}
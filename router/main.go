@@ -3,32 +3,191 @@ package router
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/ptmmeiningen/schichtplaner/handlers"
+	"github.com/ptmmeiningen/schichtplaner/middleware"
 )
 
 func SetupRoutes(app *fiber.App) {
 	app.Get("/health", handlers.HandleHealthCheck)
 
+	// setup the auth group, login and refresh are public
+	protected := middleware.RequireAuth()
+	authGroup := app.Group("/auth")
+	authGroup.Post("/login", handlers.HandleLogin)
+	authGroup.Post("/refresh", handlers.HandleRefresh)
+	authGroup.Post("/logout", protected, handlers.HandleLogout)
+	authGroup.Get("/me", protected, handlers.HandleCurrentUser)
+
 	// setup the todos group
-	todos := app.Group("/todos")
+	todos := app.Group("/todos", protected)
 	todos.Get("/", handlers.HandleAllTodos)
 	todos.Post("/", handlers.HandleCreateTodo)
 	todos.Put("/:id", handlers.HandleUpdateTodo)
 	todos.Get("/:id", handlers.HandleGetOneTodo)
 	todos.Delete("/:id", handlers.HandleDeleteTodo)
 
-	// setup the users group
-	users := app.Group("/users")
+	// setup the users group, only administrators manage accounts
+	adminOnly := middleware.RequireAdmin()
+	users := app.Group("/users", protected)
 	users.Get("/", handlers.HandleAllUsers)
-	users.Post("/", handlers.HandleCreateUser)
+	users.Post("/", adminOnly, handlers.HandleCreateUser)
+	users.Get("/export", handlers.HandleExportUsers)
+	users.Post("/import", adminOnly, handlers.HandleImportUsers)
+	users.Get("/payroll", adminOnly, handlers.HandleExportPayroll)
 	users.Get("/:id", handlers.HandleGetOneUser)
-	users.Put("/:id", handlers.HandleUpdateUser)
-	users.Delete("/:id", handlers.HandleDeleteUser)
+	users.Put("/:id", adminOnly, handlers.HandleUpdateUser)
+	users.Delete("/:id", adminOnly, handlers.HandleDeleteUser)
+	users.Get("/:id/vacation", handlers.HandleGetVacationBalance)
+	users.Put("/:id/vacation", handlers.HandleSetVacationEntitlement)
+	users.Put("/:id/password", handlers.HandleChangePassword)
+	users.Get("/:id/work-time", handlers.HandleGetWorkTimeAccount)
+	users.Post("/:id/work-time/adjustments", adminOnly, handlers.HandleCreateWorkTimeAdjustment)
+	users.Post("/:id/work-time/closings", adminOnly, handlers.HandleCloseWorkTimeMonth)
+	users.Delete("/:id/work-time/closings/:month", adminOnly, handlers.HandleReopenWorkTimeMonth)
+	users.Get("/:id/payroll", handlers.HandleGetUserPayroll)
+	users.Post("/:id/calendar-feed", handlers.HandleCreateUserCalendarFeed)
+	users.Delete("/:id/calendar-feed", handlers.HandleDeleteUserCalendarFeed)
 
 	// setup the departments group
-	departments := app.Group(("/departments"))
+	departments := app.Group("/departments", protected)
 	departments.Get("/", handlers.HandleAllDepartments)
-	departments.Post("/", handlers.HandleCreateDepartment)
+	departments.Post("/", adminOnly, handlers.HandleCreateDepartment)
+	departments.Get("/export", handlers.HandleExportDepartments)
 	departments.Get("/:id", handlers.HandleGetOneDepartment)
-	departments.Put("/:id", handlers.HandleUpdateDepartment)
-	departments.Delete("/:id", handlers.HandleDeleteDepartment)
+	departments.Put("/:id", adminOnly, handlers.HandleUpdateDepartment)
+	departments.Delete("/:id", adminOnly, handlers.HandleDeleteDepartment)
+	departments.Get("/:id/members", handlers.HandleDepartmentMembers)
+	departments.Put("/:id/members/:userId", adminOnly, handlers.HandleSetDepartmentMember)
+	departments.Delete("/:id/members/:userId", adminOnly, handlers.HandleRemoveDepartmentMember)
+	departments.Get("/:id/validate", handlers.HandleValidateDepartmentPlan)
+	departments.Get("/:id/coverage", handlers.HandleDepartmentCoverage)
+	departments.Get("/:id/availability", handlers.HandleDepartmentAvailability)
+	departments.Get("/:id/roster.pdf", handlers.HandleDepartmentRoster)
+	departments.Post("/:id/schedule/generate", handlers.HandleGenerateSchedule)
+	departments.Post("/:id/calendar-feed", handlers.HandleCreateDepartmentCalendarFeed)
+	departments.Delete("/:id/calendar-feed", handlers.HandleDeleteDepartmentCalendarFeed)
+	departments.Post("/:id/calendar-import", handlers.HandleImportCalendar)
+
+	// setup the shifts group
+	shifts := app.Group("/shifts", protected)
+	shifts.Get("/", handlers.HandleAllShifts)
+	shifts.Get("/export", handlers.HandleExportShifts)
+	shifts.Post("/", handlers.HandleCreateShift)
+	shifts.Get("/:id", handlers.HandleGetOneShift)
+	shifts.Put("/:id", handlers.HandleUpdateShift)
+	shifts.Delete("/:id", handlers.HandleDeleteShift)
+	shifts.Post("/:id/claim", handlers.HandleClaimShift)
+
+	// setup the shift claims group
+	claims := app.Group("/shift-claims", protected)
+	claims.Get("/", handlers.HandleAllShiftClaims)
+	claims.Delete("/:id", handlers.HandleWithdrawShiftClaim)
+	claims.Post("/:id/approve", handlers.HandleApproveShiftClaim)
+	claims.Post("/:id/reject", handlers.HandleRejectShiftClaim)
+
+	// setup the shift series group
+	series := app.Group("/shift-series", protected)
+	series.Get("/", handlers.HandleAllShiftSeries)
+	series.Post("/", handlers.HandleCreateShiftSeries)
+	series.Get("/:id", handlers.HandleGetOneShiftSeries)
+	series.Put("/:id", handlers.HandleUpdateShiftSeries)
+	series.Delete("/:id", handlers.HandleDeleteShiftSeries)
+	series.Post("/:id/expand", handlers.HandleExpandShiftSeries)
+	series.Put("/:id/occurrences/:shiftId", handlers.HandleUpdateShiftOccurrence)
+	series.Delete("/:id/occurrences/:shiftId", handlers.HandleDeleteShiftOccurrence)
+
+	// setup the shift types group
+	shiftTypes := app.Group("/shift-types", protected)
+	shiftTypes.Get("/", handlers.HandleAllShiftTypes)
+	shiftTypes.Post("/", handlers.HandleCreateShiftType)
+	shiftTypes.Get("/:id", handlers.HandleGetOneShiftType)
+	shiftTypes.Put("/:id", handlers.HandleUpdateShiftType)
+	shiftTypes.Delete("/:id", handlers.HandleDeleteShiftType)
+
+	// setup the qualifications group
+	qualifications := app.Group("/qualifications", protected)
+	qualifications.Get("/", handlers.HandleAllQualifications)
+	qualifications.Post("/", adminOnly, handlers.HandleCreateQualification)
+	qualifications.Put("/:id", adminOnly, handlers.HandleUpdateQualification)
+	qualifications.Delete("/:id", adminOnly, handlers.HandleDeleteQualification)
+
+	// setup the staffing requirements group
+	requirements := app.Group("/staffing-requirements", protected)
+	requirements.Get("/", handlers.HandleAllStaffingRequirements)
+	requirements.Post("/", handlers.HandleCreateStaffingRequirement)
+	requirements.Put("/:id", handlers.HandleUpdateStaffingRequirement)
+	requirements.Delete("/:id", handlers.HandleDeleteStaffingRequirement)
+
+	// setup the availabilities group
+	availabilities := app.Group("/availabilities", protected)
+	availabilities.Get("/", handlers.HandleAllAvailabilities)
+	availabilities.Post("/", handlers.HandleCreateAvailability)
+	availabilities.Get("/:id", handlers.HandleGetOneAvailability)
+	availabilities.Put("/:id", handlers.HandleUpdateAvailability)
+	availabilities.Delete("/:id", handlers.HandleDeleteAvailability)
+
+	// setup the contracts group, only administrators maintain contracts
+	contracts := app.Group("/contracts", protected)
+	contracts.Get("/", handlers.HandleAllContracts)
+	contracts.Post("/", adminOnly, handlers.HandleCreateContract)
+	contracts.Get("/:id", handlers.HandleGetOneContract)
+	contracts.Put("/:id", adminOnly, handlers.HandleUpdateContract)
+	contracts.Delete("/:id", adminOnly, handlers.HandleDeleteContract)
+
+	// setup the absences group
+	absences := app.Group("/absences", protected)
+	absences.Get("/", handlers.HandleAllAbsences)
+	absences.Post("/", handlers.HandleCreateAbsence)
+	absences.Get("/:id", handlers.HandleGetOneAbsence)
+	absences.Put("/:id", handlers.HandleUpdateAbsence)
+	absences.Delete("/:id", handlers.HandleDeleteAbsence)
+	absences.Post("/:id/approve", handlers.HandleApproveAbsence)
+	absences.Post("/:id/reject", handlers.HandleRejectAbsence)
+
+	// setup the plan periods group, only administrators unlock paid out periods
+	planPeriods := app.Group("/plan-periods", protected)
+	planPeriods.Get("/", handlers.HandleAllPlanPeriods)
+	planPeriods.Post("/", handlers.HandleCreatePlanPeriod)
+	planPeriods.Get("/:id", handlers.HandleGetOnePlanPeriod)
+	planPeriods.Put("/:id", handlers.HandleUpdatePlanPeriod)
+	planPeriods.Delete("/:id", handlers.HandleDeletePlanPeriod)
+	planPeriods.Post("/:id/publish", handlers.HandlePublishPlanPeriod)
+	planPeriods.Post("/:id/lock", handlers.HandleLockPlanPeriod)
+	planPeriods.Post("/:id/unlock", adminOnly, handlers.HandleUnlockPlanPeriod)
+
+	// setup the notifications group
+	notifications := app.Group("/notifications", protected)
+	notifications.Get("/", handlers.HandleAllNotifications)
+	notifications.Post("/read-all", handlers.HandleReadAllNotifications)
+	notifications.Post("/:id/read", handlers.HandleReadNotification)
+
+	// setup the swap requests group
+	swaps := app.Group("/swap-requests", protected)
+	swaps.Get("/", handlers.HandleAllSwapRequests)
+	swaps.Post("/", handlers.HandleCreateSwapRequest)
+	swaps.Get("/:id", handlers.HandleGetOneSwapRequest)
+	swaps.Post("/:id/cancel", handlers.HandleCancelSwapRequest)
+	swaps.Post("/:id/approve", handlers.HandleApproveSwapRequest)
+	swaps.Post("/:id/reject", handlers.HandleRejectSwapRequest)
+	swaps.Post("/:id/offers", handlers.HandleCreateSwapOffer)
+	swaps.Delete("/:id/offers/:offerId", handlers.HandleWithdrawSwapOffer)
+	swaps.Post("/:id/offers/:offerId/accept", handlers.HandleAcceptSwapOffer)
+	swaps.Post("/:id/offers/:offerId/decline", handlers.HandleDeclineSwapOffer)
+
+	// setup the time entries group
+	timeEntries := app.Group("/time-entries", protected)
+	timeEntries.Get("/", handlers.HandleAllTimeEntries)
+	timeEntries.Post("/", handlers.HandleCreateTimeEntry)
+	timeEntries.Get("/deviations", handlers.HandleTimeDeviations)
+	timeEntries.Post("/clock-in", handlers.HandleClockIn)
+	timeEntries.Post("/clock-out", handlers.HandleClockOut)
+	timeEntries.Post("/break-start", handlers.HandleStartBreak)
+	timeEntries.Post("/break-end", handlers.HandleEndBreak)
+	timeEntries.Get("/:id", handlers.HandleGetOneTimeEntry)
+	timeEntries.Put("/:id", handlers.HandleCorrectTimeEntry)
+
+	// setup the holidays route
+	app.Get("/holidays", protected, handlers.HandleAllHolidays)
+
+	// setup the calendar feeds, authenticated by the secret token in the link
+	app.Get("/calendar/:token.ics", handlers.HandleCalendarFeed)
 }
@@ -0,0 +1,207 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// Names of the built-in Arbeitszeitgesetz rules
+const (
+	RuleMaxShiftDuration = "max_shift_duration"
+	RuleRestPeriod       = "rest_period"
+	RuleBreaks           = "breaks"
+	RuleWeeklyAverage    = "weekly_average"
+)
+
+// MaxShiftDuration limits the working time of a single shift and of all shifts
+// starting on the same calendar day (§3 ArbZG)
+type MaxShiftDuration struct {
+	Severity string
+	MaxHours float64
+}
+
+func (r *MaxShiftDuration) Name() string { return RuleMaxShiftDuration }
+
+func (r *MaxShiftDuration) Check(ctx *Context) []models.Violation {
+	var violations []models.Violation
+	limit := hours(r.MaxHours)
+	for _, shift := range ctx.Shifts {
+		if worked := WorkingTime(shift); worked > limit {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: []uint{shift.ID},
+				Message:  fmt.Sprintf("Working time of %s exceeds the maximum of %gh per shift", formatHours(worked), r.MaxHours),
+			})
+		}
+	}
+
+	// split shifts on one day share the daily limit
+	for i := 0; i < len(ctx.Shifts); {
+		j := i + 1
+		worked := WorkingTime(ctx.Shifts[i])
+		ids := []uint{ctx.Shifts[i].ID}
+		for j < len(ctx.Shifts) && sameDay(ctx.Shifts[i].StartTime, ctx.Shifts[j].StartTime, ctx.Location) {
+			worked += WorkingTime(ctx.Shifts[j])
+			ids = append(ids, ctx.Shifts[j].ID)
+			j++
+		}
+		if len(ids) > 1 && worked > limit {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: ids,
+				Message:  fmt.Sprintf("Working time of %s on one day exceeds the maximum of %gh", formatHours(worked), r.MaxHours),
+			})
+		}
+		i = j
+	}
+	return violations
+}
+
+// RestPeriod requires an uninterrupted rest between two working days (§5 ArbZG).
+// Shifts starting on the same calendar day count as one split working day.
+type RestPeriod struct {
+	Severity string
+	MinHours float64
+}
+
+func (r *RestPeriod) Name() string { return RuleRestPeriod }
+
+func (r *RestPeriod) Check(ctx *Context) []models.Violation {
+	var violations []models.Violation
+	limit := hours(r.MinHours)
+	for i := 1; i < len(ctx.Shifts); i++ {
+		prev, next := ctx.Shifts[i-1], ctx.Shifts[i]
+		if sameDay(prev.StartTime, next.StartTime, ctx.Location) {
+			continue
+		}
+
+		rest := next.StartTime.Sub(prev.EndTime)
+		if rest >= 0 && rest < limit {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: []uint{prev.ID, next.ID},
+				Message:  fmt.Sprintf("Rest period of %s between shifts is shorter than %gh", formatHours(rest), r.MinHours),
+			})
+		}
+	}
+	return violations
+}
+
+// Breaks checks the mandatory rest breaks of §4 ArbZG:
+// 30 minutes after more than 6 hours, 45 minutes after more than 9 hours.
+type Breaks struct {
+	Severity string
+}
+
+func (r *Breaks) Name() string { return RuleBreaks }
+
+func (r *Breaks) Check(ctx *Context) []models.Violation {
+	var violations []models.Violation
+	for _, shift := range ctx.Shifts {
+		required := RequiredBreakMinutes(WorkingTime(shift))
+		if shift.BreakMinutes < required {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: []uint{shift.ID},
+				Message:  fmt.Sprintf("Break of %d minutes is shorter than the required %d minutes", shift.BreakMinutes, required),
+			})
+		}
+	}
+	return violations
+}
+
+// RequiredBreakMinutes returns the minimum break for the given working time
+func RequiredBreakMinutes(worked time.Duration) uint {
+	switch {
+	case worked > 9*time.Hour:
+		return 45
+	case worked > 6*time.Hour:
+		return 30
+	default:
+		return 0
+	}
+}
+
+// WeeklyAverage limits the average weekly working time over a reference
+// period of weeks (§3 ArbZG: 8h per working day on average, i.e. 48h per week).
+// Each week containing shifts is judged by the average of the reference period ending with it.
+type WeeklyAverage struct {
+	Severity       string
+	MaxHours       float64
+	ReferenceWeeks int
+}
+
+func (r *WeeklyAverage) Name() string { return RuleWeeklyAverage }
+
+func (r *WeeklyAverage) Check(ctx *Context) []models.Violation {
+	weeks := r.ReferenceWeeks
+	if weeks < 1 {
+		weeks = 1
+	}
+
+	var violations []models.Violation
+	var weekStarts []time.Time
+	shiftsByWeek := map[time.Time][]uint{}
+	for _, shift := range ctx.Shifts {
+		week := WeekStart(shift.StartTime, ctx.Location)
+		if _, ok := shiftsByWeek[week]; !ok {
+			weekStarts = append(weekStarts, week)
+		}
+		shiftsByWeek[week] = append(shiftsByWeek[week], shift.ID)
+	}
+
+	for _, week := range weekStarts {
+		windowStart := week.AddDate(0, 0, -7*(weeks-1))
+		windowEnd := week.AddDate(0, 0, 7)
+
+		var total time.Duration
+		for _, shift := range ctx.Shifts {
+			if !shift.StartTime.Before(windowStart) && shift.StartTime.Before(windowEnd) {
+				total += WorkingTime(shift)
+			}
+		}
+
+		average := total.Hours() / float64(weeks)
+		if average > r.MaxHours {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: shiftsByWeek[week],
+				Message: fmt.Sprintf("Average weekly working time of %.1fh over %d weeks up to the week of %s exceeds %gh",
+					average, weeks, week.Format("2006-01-02"), r.MaxHours),
+			})
+		}
+	}
+	return violations
+}
+
+// WeekStart returns midnight of the Monday of the week containing t
+func WeekStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	offset := (int(local.Weekday()) + 6) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()-offset, 0, 0, 0, 0, loc)
+}
+
+func sameDay(a, b time.Time, loc *time.Location) bool {
+	a, b = a.In(loc), b.In(loc)
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+func formatHours(d time.Duration) string {
+	return fmt.Sprintf("%.1fh", d.Hours())
+}
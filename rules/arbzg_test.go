@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+func TestMaxShiftDuration(t *testing.T) {
+	tests := []struct {
+		name   string
+		shifts []models.Shift
+		want   [][]uint
+	}{
+		{"within limit", []models.Shift{shift(1, "2024-05-06 08:00", 10.5, 30)}, nil},
+		{"too long", []models.Shift{shift(1, "2024-05-06 08:00", 11, 30)}, [][]uint{{1}}},
+		{"split shifts on one day", []models.Shift{
+			shift(1, "2024-05-06 06:00", 6, 0),
+			shift(2, "2024-05-06 16:00", 5, 0),
+		}, [][]uint{{1, 2}}},
+		// 00:30 in Berlin is still the previous day in UTC
+		{"split by local midnight", []models.Shift{
+			shift(1, "2024-05-06 14:00", 6, 0),
+			shift(2, "2024-05-07 00:30", 5, 0),
+		}, nil},
+	}
+	rule := &MaxShiftDuration{Severity: models.SeverityViolation, MaxHours: 10}
+	for _, tt := range tests {
+		if got := shiftIDs(check(rule, tt.shifts...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations for %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRestPeriod(t *testing.T) {
+	tests := []struct {
+		name   string
+		shifts []models.Shift
+		want   [][]uint
+	}{
+		{"eleven hours", []models.Shift{
+			shift(1, "2024-05-06 14:00", 8, 0),
+			shift(2, "2024-05-07 09:00", 8, 0),
+		}, nil},
+		{"too short", []models.Shift{
+			shift(1, "2024-05-06 14:00", 8, 0),
+			shift(2, "2024-05-07 06:00", 8, 0),
+		}, [][]uint{{1, 2}}},
+		{"split shift on one day", []models.Shift{
+			shift(1, "2024-05-06 06:00", 4, 0),
+			shift(2, "2024-05-06 14:00", 4, 0),
+		}, nil},
+		{"overlapping", []models.Shift{
+			shift(1, "2024-05-06 20:00", 8, 0),
+			shift(2, "2024-05-07 02:00", 4, 0),
+		}, nil},
+		// the rest across the change to summer time is one hour shorter than on the clock
+		{"daylight saving time", []models.Shift{
+			shift(1, "2024-03-30 14:00", 8, 0),
+			shift(2, "2024-03-31 09:00", 8, 0),
+		}, [][]uint{{1, 2}}},
+	}
+	rule := &RestPeriod{Severity: models.SeverityViolation, MinHours: 11}
+	for _, tt := range tests {
+		if got := shiftIDs(check(rule, tt.shifts...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations for %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBreaks(t *testing.T) {
+	tests := []struct {
+		hours        float64
+		breakMinutes uint
+		violated     bool
+	}{
+		{6, 0, false},
+		{6.5, 0, true},
+		{7, 30, false},
+		{9.75, 30, true},
+		{9.75, 45, false},
+	}
+	for _, tt := range tests {
+		violations := check(&Breaks{Severity: models.SeverityWarning}, shift(1, "2024-05-06 08:00", tt.hours, tt.breakMinutes))
+		if got := len(violations) > 0; got != tt.violated {
+			t.Errorf("%gh with %d min break: violated = %v, want %v", tt.hours, tt.breakMinutes, got, tt.violated)
+		}
+	}
+}
+
+func TestRequiredBreakMinutes(t *testing.T) {
+	tests := []struct {
+		worked time.Duration
+		want   uint
+	}{
+		{6 * time.Hour, 0},
+		{6*time.Hour + time.Minute, 30},
+		{9 * time.Hour, 30},
+		{9*time.Hour + time.Minute, 45},
+	}
+	for _, tt := range tests {
+		if got := RequiredBreakMinutes(tt.worked); got != tt.want {
+			t.Errorf("RequiredBreakMinutes(%v) = %d, want %d", tt.worked, got, tt.want)
+		}
+	}
+}
+
+func TestWeeklyAverage(t *testing.T) {
+	// five shifts of 10 hours in the week of 2024-05-13
+	var week []models.Shift
+	for i := 0; i < 5; i++ {
+		week = append(week, shift(uint(i+1), at("2024-05-13 08:00").AddDate(0, 0, i).Format("2006-01-02 15:04"), 10, 0))
+	}
+	previous := shift(6, "2024-05-08 08:00", 10, 0)
+
+	tests := []struct {
+		name   string
+		weeks  int
+		shifts []models.Shift
+		want   [][]uint
+	}{
+		{"single week", 1, week, [][]uint{{1, 2, 3, 4, 5}}},
+		{"averaged over two weeks", 2, week, nil},
+		{"only the week above the average", 1, append([]models.Shift{previous}, week...), [][]uint{{1, 2, 3, 4, 5}}},
+	}
+	for _, tt := range tests {
+		rule := &WeeklyAverage{Severity: models.SeverityWarning, MaxHours: 48, ReferenceWeeks: tt.weeks}
+		if got := shiftIDs(check(rule, tt.shifts...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations for %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{at("2024-05-06 00:00"), "2024-05-06"},
+		{at("2024-05-12 23:30"), "2024-05-06"},
+		// Monday 00:30 in Berlin is still Sunday in UTC
+		{at("2024-05-13 00:30"), "2024-05-13"},
+	}
+	for _, tt := range tests {
+		got := WeekStart(tt.t.UTC(), berlin)
+		if got.Format("2006-01-02 15:04") != tt.want+" 00:00" || got.Location() != berlin {
+			t.Errorf("WeekStart(%v) = %v, want %s in Berlin", tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/config"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// RuleConfig switches a rule on or off and decides whether it blocks saving
+type RuleConfig struct {
+	Enabled  bool `json:"enabled"`
+	Blocking bool `json:"blocking"`
+}
+
+func (rc RuleConfig) severity() string {
+	if rc.Blocking {
+		return models.SeverityViolation
+	}
+	return models.SeverityWarning
+}
+
+// Config holds the settings of the built-in working time rules
+type Config struct {
+	MaxShiftDuration   RuleConfig `json:"max_shift_duration"`
+	RestPeriod         RuleConfig `json:"rest_period"`
+	Breaks             RuleConfig `json:"breaks"`
+	WeeklyAverage      RuleConfig `json:"weekly_average"`
+	ContractHours      RuleConfig `json:"contract_hours"`
+	MinijobLimit       RuleConfig `json:"minijob_limit"`
+	YouthProtection    RuleConfig `json:"youth_protection"`
+	MaxShiftHours      float64    `json:"max_shift_hours"`
+	MinRestHours       float64    `json:"min_rest_hours"`
+	WeeklyAverageHours float64    `json:"weekly_average_hours"`
+	ReferenceWeeks     int        `json:"reference_weeks"`
+	MinijobMaxEarnings float64    `json:"minijob_max_earnings"` // euros
+
+	Location *time.Location `json:"-"`
+	// Contracts holds the employment contracts per user for the contract rules
+	Contracts map[uint][]models.Contract `json:"-"`
+	// BirthDates holds the known birth dates per user for the youth protection rule
+	BirthDates map[uint]string `json:"-"`
+}
+
+// DefaultConfig returns the limits of the Arbeitszeitgesetz
+func DefaultConfig() Config {
+	return Config{
+		MaxShiftDuration:   RuleConfig{Enabled: true, Blocking: true},
+		RestPeriod:         RuleConfig{Enabled: true, Blocking: true},
+		Breaks:             RuleConfig{Enabled: true},
+		WeeklyAverage:      RuleConfig{Enabled: true},
+		ContractHours:      RuleConfig{Enabled: true},
+		MinijobLimit:       RuleConfig{Enabled: true},
+		YouthProtection:    RuleConfig{Enabled: true, Blocking: true},
+		MaxShiftHours:      10,
+		MinRestHours:       11,
+		WeeklyAverageHours: 48,
+		ReferenceWeeks:     24,
+		MinijobMaxEarnings: 603,
+		Location:           time.UTC,
+	}
+}
+
+// ConfigFromEnv reads the rule settings from the environment.
+// ARBZG_DISABLED_RULES and ARBZG_BLOCKING_RULES take comma separated rule names.
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	cfg.Location = config.Location()
+
+	if v, err := strconv.ParseFloat(os.Getenv("ARBZG_MAX_SHIFT_HOURS"), 64); err == nil {
+		cfg.MaxShiftHours = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("ARBZG_MIN_REST_HOURS"), 64); err == nil {
+		cfg.MinRestHours = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("ARBZG_WEEKLY_AVERAGE_HOURS"), 64); err == nil {
+		cfg.WeeklyAverageHours = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ARBZG_REFERENCE_WEEKS")); err == nil && v > 0 {
+		cfg.ReferenceWeeks = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("MINIJOB_MONTHLY_LIMIT"), 64); err == nil && v >= 0 {
+		cfg.MinijobMaxEarnings = v
+	}
+
+	rules := map[string]*RuleConfig{
+		RuleMaxShiftDuration: &cfg.MaxShiftDuration,
+		RuleRestPeriod:       &cfg.RestPeriod,
+		RuleBreaks:           &cfg.Breaks,
+		RuleWeeklyAverage:    &cfg.WeeklyAverage,
+		RuleContractHours:    &cfg.ContractHours,
+		RuleMinijobLimit:     &cfg.MinijobLimit,
+		RuleYouthProtection:  &cfg.YouthProtection,
+	}
+	if blocking, ok := os.LookupEnv("ARBZG_BLOCKING_RULES"); ok {
+		names := splitList(blocking)
+		for name, rc := range rules {
+			rc.Blocking = names[name]
+		}
+	}
+	for name := range splitList(os.Getenv("ARBZG_DISABLED_RULES")) {
+		if rc, ok := rules[name]; ok {
+			rc.Enabled = false
+		}
+	}
+	return cfg
+}
+
+func splitList(value string) map[string]bool {
+	result := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result[part] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/contract"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// RuleContractHours is the name of the employment contract rule
+const RuleContractHours = "contract_hours"
+
+// ContractHours checks the shifts against the contract valid on their day: no
+// more planned hours per week than agreed and no shifts outside the working
+// days. Users without contract and contracts without weekly hours are not limited.
+type ContractHours struct {
+	Severity  string
+	Contracts map[uint][]models.Contract
+}
+
+func (r *ContractHours) Name() string { return RuleContractHours }
+
+func (r *ContractHours) Check(ctx *Context) []models.Violation {
+	contracts := r.Contracts[ctx.UserID]
+	if len(contracts) == 0 {
+		return nil
+	}
+
+	var violations []models.Violation
+	var weekStarts []time.Time
+	shiftsByWeek := map[time.Time][]models.Shift{}
+	for _, shift := range ctx.Shifts {
+		c := contract.ValidAt(contracts, shift.StartTime, ctx.Location)
+		if c == nil {
+			continue
+		}
+		if day := shift.StartTime.In(ctx.Location); len(c.WorkingDays) > 0 && !contract.WorksOn(*c, day.Weekday()) {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: []uint{shift.ID},
+				Message:  fmt.Sprintf("%s is not a working day of the contract", day.Format("Monday 2006-01-02")),
+			})
+		}
+		week := WeekStart(shift.StartTime, ctx.Location)
+		if _, ok := shiftsByWeek[week]; !ok {
+			weekStarts = append(weekStarts, week)
+		}
+		shiftsByWeek[week] = append(shiftsByWeek[week], shift)
+	}
+
+	for _, week := range weekStarts {
+		var worked time.Duration
+		var ids []uint
+		limit := 0.0
+		for _, shift := range shiftsByWeek[week] {
+			worked += WorkingTime(shift)
+			ids = append(ids, shift.ID)
+			if c := contract.ValidAt(contracts, shift.StartTime, ctx.Location); c != nil && c.WeeklyHours > 0 && worked > hours(c.WeeklyHours) {
+				limit = c.WeeklyHours
+			}
+		}
+		if limit > 0 {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: ids,
+				Message: fmt.Sprintf("Planned working time of %s in the week of %s exceeds the contractual %gh",
+					formatHours(worked), week.Format("2006-01-02"), limit),
+			})
+		}
+	}
+	return violations
+}
+
+// RuleMinijobLimit is the name of the Minijob earnings rule
+const RuleMinijobLimit = "minijob_limit"
+
+// MinijobLimit warns when the planned shifts of a calendar month, paid with the
+// hourly wage of the Minijob contract valid on their day, earn more than the
+// monthly limit of a Minijob
+type MinijobLimit struct {
+	Severity     string
+	MonthlyLimit float64
+	Contracts    map[uint][]models.Contract
+}
+
+func (r *MinijobLimit) Name() string { return RuleMinijobLimit }
+
+func (r *MinijobLimit) Check(ctx *Context) []models.Violation {
+	contracts := r.Contracts[ctx.UserID]
+	if len(contracts) == 0 || r.MonthlyLimit <= 0 {
+		return nil
+	}
+
+	var months []string
+	earnings := map[string]float64{}
+	shiftsByMonth := map[string][]uint{}
+	for _, shift := range ctx.Shifts {
+		c := contract.ValidAt(contracts, shift.StartTime, ctx.Location)
+		if c == nil || c.Type != models.ContractMinijob {
+			continue
+		}
+		month := shift.StartTime.In(ctx.Location).Format("2006-01")
+		if _, ok := shiftsByMonth[month]; !ok {
+			months = append(months, month)
+		}
+		earnings[month] += WorkingTime(shift).Hours() * c.HourlyWage
+		shiftsByMonth[month] = append(shiftsByMonth[month], shift.ID)
+	}
+
+	var violations []models.Violation
+	for _, month := range months {
+		if earnings[month] > r.MonthlyLimit {
+			violations = append(violations, models.Violation{
+				Rule:     r.Name(),
+				Severity: r.Severity,
+				UserID:   ctx.UserID,
+				ShiftIDs: shiftsByMonth[month],
+				Message: fmt.Sprintf("Planned earnings of %.2f EUR in %s exceed the Minijob limit of %.2f EUR",
+					earnings[month], month, r.MonthlyLimit),
+			})
+		}
+	}
+	return violations
+}
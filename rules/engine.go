@@ -0,0 +1,141 @@
+package rules
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// Context holds the shifts of a single user a rule is evaluated against
+type Context struct {
+	UserID   uint
+	Shifts   []models.Shift // sorted by start time
+	Location *time.Location
+}
+
+// Rule checks the shifts of one user and reports violations
+type Rule interface {
+	Name() string
+	Check(ctx *Context) []models.Violation
+}
+
+// Engine evaluates a set of rules against shift plans
+type Engine struct {
+	rules    []Rule
+	location *time.Location
+}
+
+// NewEngine creates an engine with the built-in rules enabled in cfg
+func NewEngine(cfg Config) *Engine {
+	e := &Engine{location: cfg.Location}
+	if e.location == nil {
+		e.location = time.UTC
+	}
+
+	if cfg.MaxShiftDuration.Enabled {
+		e.Register(&MaxShiftDuration{Severity: cfg.MaxShiftDuration.severity(), MaxHours: cfg.MaxShiftHours})
+	}
+	if cfg.RestPeriod.Enabled {
+		e.Register(&RestPeriod{Severity: cfg.RestPeriod.severity(), MinHours: cfg.MinRestHours})
+	}
+	if cfg.Breaks.Enabled {
+		e.Register(&Breaks{Severity: cfg.Breaks.severity()})
+	}
+	if cfg.WeeklyAverage.Enabled {
+		e.Register(&WeeklyAverage{Severity: cfg.WeeklyAverage.severity(), MaxHours: cfg.WeeklyAverageHours, ReferenceWeeks: cfg.ReferenceWeeks})
+	}
+	if cfg.ContractHours.Enabled {
+		e.Register(&ContractHours{Severity: cfg.ContractHours.severity(), Contracts: cfg.Contracts})
+	}
+	if cfg.MinijobLimit.Enabled {
+		e.Register(&MinijobLimit{Severity: cfg.MinijobLimit.severity(), MonthlyLimit: cfg.MinijobMaxEarnings, Contracts: cfg.Contracts})
+	}
+	if cfg.YouthProtection.Enabled {
+		e.Register(&YouthProtection{Severity: cfg.YouthProtection.severity(), BirthDates: cfg.BirthDates})
+	}
+	return e
+}
+
+// Register adds a rule to the engine
+func (e *Engine) Register(rule Rule) {
+	e.rules = append(e.rules, rule)
+}
+
+// Rules returns the names of all registered rules
+func (e *Engine) Rules() []string {
+	names := make([]string, len(e.rules))
+	for i, rule := range e.rules {
+		names[i] = rule.Name()
+	}
+	return names
+}
+
+// Evaluate groups the shifts by user and runs every rule on each group.
+// Shifts without an assigned user are ignored.
+func (e *Engine) Evaluate(shifts []models.Shift) []models.Violation {
+	byUser := map[uint][]models.Shift{}
+	var userIDs []uint
+	for _, shift := range shifts {
+		if shift.UserID == 0 {
+			continue
+		}
+		if _, ok := byUser[shift.UserID]; !ok {
+			userIDs = append(userIDs, shift.UserID)
+		}
+		byUser[shift.UserID] = append(byUser[shift.UserID], shift)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	violations := []models.Violation{}
+	for _, userID := range userIDs {
+		userShifts := byUser[userID]
+		sort.SliceStable(userShifts, func(i, j int) bool {
+			return userShifts[i].StartTime.Before(userShifts[j].StartTime)
+		})
+
+		ctx := &Context{UserID: userID, Shifts: userShifts, Location: e.location}
+		for _, rule := range e.rules {
+			violations = append(violations, rule.Check(ctx)...)
+		}
+	}
+	return violations
+}
+
+// Involving keeps only the violations that concern at least one of the given shifts
+func Involving(violations []models.Violation, shiftIDs ...uint) []models.Violation {
+	wanted := map[uint]bool{}
+	for _, id := range shiftIDs {
+		wanted[id] = true
+	}
+
+	result := []models.Violation{}
+	for _, v := range violations {
+		for _, id := range v.ShiftIDs {
+			if wanted[id] {
+				result = append(result, v)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// HasBlocking reports whether any violation has violation severity
+func HasBlocking(violations []models.Violation) bool {
+	for _, v := range violations {
+		if v.Severity == models.SeverityViolation {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkingTime returns the shift duration without its break
+func WorkingTime(shift models.Shift) time.Duration {
+	d := shift.EndTime.Sub(shift.StartTime) - time.Duration(shift.BreakMinutes)*time.Minute
+	if d < 0 {
+		return 0
+	}
+	return d
+}
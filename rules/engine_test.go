@@ -0,0 +1,141 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// at parses a local time in Berlin
+func at(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, berlin)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// shift returns a shift of user 1 starting at the local time
+func shift(id uint, start string, hours float64, breakMinutes uint) models.Shift {
+	t := at(start)
+	return models.Shift{
+		ID:           id,
+		UserID:       1,
+		StartTime:    t,
+		EndTime:      t.Add(time.Duration(hours * float64(time.Hour))),
+		BreakMinutes: breakMinutes,
+	}
+}
+
+// check runs the rule against the shifts of user 1
+func check(rule Rule, shifts ...models.Shift) []models.Violation {
+	return rule.Check(&Context{UserID: 1, Shifts: shifts, Location: berlin})
+}
+
+// shiftIDs returns the shifts concerned by each violation
+func shiftIDs(violations []models.Violation) [][]uint {
+	var ids [][]uint
+	for _, v := range violations {
+		ids = append(ids, v.ShiftIDs)
+	}
+	return ids
+}
+
+func TestNewEngine(t *testing.T) {
+	all := []string{RuleMaxShiftDuration, RuleRestPeriod, RuleBreaks, RuleWeeklyAverage, RuleContractHours, RuleMinijobLimit, RuleYouthProtection}
+	if got := NewEngine(DefaultConfig()).Rules(); !reflect.DeepEqual(got, all) {
+		t.Errorf("Rules() = %v, want %v", got, all)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Breaks.Enabled = false
+	cfg.MinijobLimit.Enabled = false
+	want := []string{RuleMaxShiftDuration, RuleRestPeriod, RuleWeeklyAverage, RuleContractHours, RuleYouthProtection}
+	if got := NewEngine(cfg).Rules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Rules() with disabled rules = %v, want %v", got, want)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Location = berlin
+	engine := NewEngine(cfg)
+
+	open := shift(1, "2024-05-06 06:00", 12, 45)
+	open.UserID = 0
+	second := shift(2, "2024-05-07 08:00", 11, 45)
+	second.UserID = 2
+	first := shift(3, "2024-05-06 08:00", 11, 45)
+
+	violations := engine.Evaluate([]models.Shift{open, second, first})
+	var users []uint
+	for _, v := range violations {
+		if v.Rule != RuleMaxShiftDuration {
+			t.Errorf("unexpected violation %+v", v)
+		}
+		users = append(users, v.UserID)
+	}
+	// open shifts are skipped, users are evaluated in the order of their IDs
+	if want := []uint{1, 2}; !reflect.DeepEqual(users, want) {
+		t.Errorf("violations of users %v, want %v", users, want)
+	}
+	if !HasBlocking(violations) {
+		t.Error("HasBlocking() = false for blocking maximum shift duration")
+	}
+	if got := Involving(violations, 2); len(got) != 1 || got[0].UserID != 2 {
+		t.Errorf("Involving(2) = %+v, want the violation of shift 2", got)
+	}
+	if got := Involving(violations, 1); len(got) != 0 {
+		t.Errorf("Involving(1) = %+v, want none", got)
+	}
+}
+
+func TestHasBlocking(t *testing.T) {
+	tests := []struct {
+		name       string
+		severities []string
+		want       bool
+	}{
+		{"none", nil, false},
+		{"warnings", []string{models.SeverityWarning, models.SeverityWarning}, false},
+		{"violation", []string{models.SeverityWarning, models.SeverityViolation}, true},
+	}
+	for _, tt := range tests {
+		var violations []models.Violation
+		for _, severity := range tt.severities {
+			violations = append(violations, models.Violation{Severity: severity})
+		}
+		if got := HasBlocking(violations); got != tt.want {
+			t.Errorf("%s: HasBlocking() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWorkingTime(t *testing.T) {
+	tests := []struct {
+		hours        float64
+		breakMinutes uint
+		want         time.Duration
+	}{
+		{8, 0, 8 * time.Hour},
+		{8, 30, 7*time.Hour + 30*time.Minute},
+		{0.25, 30, 0},
+	}
+	for _, tt := range tests {
+		if got := WorkingTime(shift(1, "2024-05-06 08:00", tt.hours, tt.breakMinutes)); got != tt.want {
+			t.Errorf("WorkingTime(%gh, %d min) = %v, want %v", tt.hours, tt.breakMinutes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// RuleYouthProtection is the name of the rule for employees under 18
+const RuleYouthProtection = "youth_protection"
+
+// Limits of the Jugendarbeitsschutzgesetz
+const (
+	minorMaxDailyHours  = 8  // §8 JArbSchG
+	minorMaxWorkingDays = 5  // §15 JArbSchG
+	minorNightStartHour = 20 // §14 JArbSchG: work only between 6 and 20 o'clock
+	minorNightEndHour   = 6
+)
+
+// YouthProtection enforces the Jugendarbeitsschutzgesetz for users who are
+// not yet 18 on the day of a shift: no night work, at most 8 hours per day
+// and 5 working days per week. Users without birth date are not checked.
+type YouthProtection struct {
+	Severity   string
+	BirthDates map[uint]string // YYYY-MM-DD
+}
+
+func (r *YouthProtection) Name() string { return RuleYouthProtection }
+
+func (r *YouthProtection) Check(ctx *Context) []models.Violation {
+	birth, err := time.ParseInLocation("2006-01-02", r.BirthDates[ctx.UserID], ctx.Location)
+	if err != nil {
+		return nil
+	}
+	adult := birth.AddDate(18, 0, 0)
+
+	var minorShifts []models.Shift
+	for _, shift := range ctx.Shifts {
+		if shift.StartTime.Before(adult) {
+			minorShifts = append(minorShifts, shift)
+		}
+	}
+
+	var violations []models.Violation
+	violation := func(ids []uint, message string) {
+		violations = append(violations, models.Violation{
+			Rule:     r.Name(),
+			Severity: r.Severity,
+			UserID:   ctx.UserID,
+			ShiftIDs: ids,
+			Message:  message,
+		})
+	}
+
+	for _, shift := range minorShifts {
+		start := shift.StartTime.In(ctx.Location)
+		midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, ctx.Location)
+		if start.Hour() < minorNightEndHour || shift.EndTime.After(midnight.Add(minorNightStartHour*time.Hour)) {
+			violation([]uint{shift.ID}, fmt.Sprintf("Minors must not work before %d or after %d o'clock", minorNightEndHour, minorNightStartHour))
+		}
+	}
+
+	var days []time.Time
+	var weekStarts []time.Time
+	workedByDay := map[time.Time]time.Duration{}
+	shiftsByDay := map[time.Time][]uint{}
+	daysByWeek := map[time.Time]int{}
+	shiftsByWeek := map[time.Time][]uint{}
+	for _, shift := range minorShifts {
+		start := shift.StartTime.In(ctx.Location)
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, ctx.Location)
+		week := WeekStart(shift.StartTime, ctx.Location)
+		if _, ok := shiftsByDay[day]; !ok {
+			days = append(days, day)
+			daysByWeek[week]++
+		}
+		if _, ok := shiftsByWeek[week]; !ok {
+			weekStarts = append(weekStarts, week)
+		}
+		workedByDay[day] += WorkingTime(shift)
+		shiftsByDay[day] = append(shiftsByDay[day], shift.ID)
+		shiftsByWeek[week] = append(shiftsByWeek[week], shift.ID)
+	}
+	for _, day := range days {
+		if workedByDay[day] > minorMaxDailyHours*time.Hour {
+			violation(shiftsByDay[day], fmt.Sprintf("Working time of %s on %s exceeds the maximum of %dh per day for minors",
+				formatHours(workedByDay[day]), day.Format("2006-01-02"), minorMaxDailyHours))
+		}
+	}
+	for _, week := range weekStarts {
+		if daysByWeek[week] > minorMaxWorkingDays {
+			violation(shiftsByWeek[week], fmt.Sprintf("%d working days in the week of %s exceed the maximum of %d for minors",
+				daysByWeek[week], week.Format("2006-01-02"), minorMaxWorkingDays))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,240 @@
+package scheduler
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/coverage"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+)
+
+// Constraint is a hard condition a user has to meet to take a proposed shift
+type Constraint interface {
+	Name() string
+	Allows(userID uint, shift models.Shift) bool
+}
+
+// Preference scores how much a user wants a shift; among users with equal
+// hours the higher total score wins
+type Preference interface {
+	Score(userID uint, shift models.Shift) int
+}
+
+// Input describes the scheduling problem of one department
+type Input struct {
+	DepartmentID uint
+	// Slots are the staffing needs, e.g. from coverage.Slots; shifts in Existing are counted against them
+	Slots      []coverage.Slot
+	Users      []models.User
+	ShiftTypes []models.ShiftType
+	// Existing holds the shifts of the users in and around the period, across all departments
+	Existing []models.Shift
+	// Qualifications maps user IDs to the IDs of their qualifications
+	Qualifications map[uint]map[uint]bool
+	// TargetHours maps user IDs to their contractual hours within the period, reported with the hours
+	TargetHours map[uint]float64
+	Engine      *rules.Engine
+	Constraints []Constraint
+	Preferences []Preference
+	Seed        int64
+}
+
+// Unfilled is a slot that could not be staffed completely
+type Unfilled struct {
+	Slot    coverage.Slot  `json:"slot"`
+	Missing int            `json:"missing"`
+	Reasons map[string]int `json:"reasons"` // number of users rejected per reason for the first missing position
+}
+
+// UserHours sums the working time of a user within the period
+type UserHours struct {
+	UserID        uint    `json:"user_id"`
+	TargetHours   float64 `json:"target_hours"`
+	PlannedHours  float64 `json:"planned_hours"`
+	ProposedHours float64 `json:"proposed_hours"`
+	TotalHours    float64 `json:"total_hours"`
+}
+
+// Result is the proposed draft for the period
+type Result struct {
+	Shifts   []models.Shift `json:"shifts"`
+	Unfilled []Unfilled     `json:"unfilled"`
+	Hours    []UserHours    `json:"hours"`
+}
+
+// Reasons for rejecting a candidate
+const (
+	ReasonQualification = "qualification"
+	ReasonOverlap       = "overlap"
+	ReasonWorkingTime   = "working_time"
+)
+
+// firstTemporaryID numbers proposed shifts so the rule engine can tell them apart
+const firstTemporaryID = 1 << 30
+
+// Generate greedily fills every understaffed slot in chronological order. Each
+// open position goes to the allowed user with the fewest hours in the period;
+// ties are broken by the preferences and then by a random order derived from
+// the seed, so equal input and seed always produce the same draft.
+func Generate(in Input) Result {
+	users := append([]models.User(nil), in.Users...)
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	rng := rand.New(rand.NewSource(in.Seed))
+	rng.Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+	rank := map[uint]int{}
+	for i, user := range users {
+		rank[user.ID] = i
+	}
+
+	types := map[uint]models.ShiftType{}
+	for _, t := range in.ShiftTypes {
+		types[t.ID] = t
+	}
+
+	var from, to time.Time
+	for _, slot := range in.Slots {
+		if from.IsZero() || slot.Start.Before(from) {
+			from = slot.Start
+		}
+		if slot.End.After(to) {
+			to = slot.End
+		}
+	}
+
+	shiftsByUser := map[uint][]models.Shift{}
+	planned := map[uint]time.Duration{}
+	for _, shift := range in.Existing {
+		shiftsByUser[shift.UserID] = append(shiftsByUser[shift.UserID], shift)
+		if shift.EndTime.After(from) && shift.StartTime.Before(to) {
+			planned[shift.UserID] += rules.WorkingTime(shift)
+		}
+	}
+	proposed := map[uint]time.Duration{}
+
+	result := Result{Shifts: []models.Shift{}, Unfilled: []Unfilled{}}
+	nextID := uint(firstTemporaryID)
+
+	slots := append([]coverage.Slot(nil), in.Slots...)
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+
+	for _, slot := range slots {
+		assigned := map[uint]bool{}
+		covered := 0
+		for _, shift := range append(append([]models.Shift(nil), in.Existing...), result.Shifts...) {
+			if shift.DepartmentID != in.DepartmentID || shift.UserID == 0 || !coverage.Covers(slot, shift) {
+				continue
+			}
+			if slot.QualificationID != nil && !in.Qualifications[shift.UserID][*slot.QualificationID] {
+				continue
+			}
+			assigned[shift.UserID] = true
+			covered++
+		}
+
+		missing := slot.Required - covered
+		var reasons map[string]int
+		for ; missing > 0; missing-- {
+			reasons = map[string]int{}
+			candidate := models.Shift{
+				ID:           nextID,
+				StartTime:    slot.Start,
+				EndTime:      slot.End,
+				DepartmentID: in.DepartmentID,
+				ShiftTypeID:  slot.ShiftTypeID,
+			}
+			if slot.ShiftTypeID != nil {
+				candidate.BreakMinutes = types[*slot.ShiftTypeID].BreakMinutes
+			}
+			if candidate.BreakMinutes == 0 {
+				candidate.BreakMinutes = rules.RequiredBreakMinutes(slot.End.Sub(slot.Start))
+			}
+
+			var best *models.User
+			bestScore := 0
+			for i := range users {
+				user := &users[i]
+				if assigned[user.ID] {
+					continue
+				}
+				candidate.UserID = user.ID
+				if reason := reject(in, slot, candidate, shiftsByUser[user.ID]); reason != "" {
+					reasons[reason]++
+					continue
+				}
+				score := 0
+				for _, preference := range in.Preferences {
+					score += preference.Score(user.ID, candidate)
+				}
+				if best == nil || less(planned[user.ID]+proposed[user.ID], planned[best.ID]+proposed[best.ID], score, bestScore, rank[user.ID], rank[best.ID]) {
+					best = user
+					bestScore = score
+				}
+			}
+			if best == nil {
+				break
+			}
+
+			candidate.UserID = best.ID
+			result.Shifts = append(result.Shifts, candidate)
+			shiftsByUser[best.ID] = append(shiftsByUser[best.ID], candidate)
+			proposed[best.ID] += rules.WorkingTime(candidate)
+			assigned[best.ID] = true
+			nextID++
+		}
+
+		if missing > 0 {
+			result.Unfilled = append(result.Unfilled, Unfilled{Slot: slot, Missing: missing, Reasons: reasons})
+		}
+	}
+
+	for i := range result.Shifts {
+		result.Shifts[i].ID = 0
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	for _, user := range users {
+		result.Hours = append(result.Hours, UserHours{
+			UserID:        user.ID,
+			TargetHours:   in.TargetHours[user.ID],
+			PlannedHours:  planned[user.ID].Hours(),
+			ProposedHours: proposed[user.ID].Hours(),
+			TotalHours:    (planned[user.ID] + proposed[user.ID]).Hours(),
+		})
+	}
+	return result
+}
+
+// reject returns why the user cannot take the candidate shift, or "" if they can
+func reject(in Input, slot coverage.Slot, candidate models.Shift, userShifts []models.Shift) string {
+	if slot.QualificationID != nil && !in.Qualifications[candidate.UserID][*slot.QualificationID] {
+		return ReasonQualification
+	}
+	for _, shift := range userShifts {
+		if shift.StartTime.Before(candidate.EndTime) && shift.EndTime.After(candidate.StartTime) {
+			return ReasonOverlap
+		}
+	}
+	for _, constraint := range in.Constraints {
+		if !constraint.Allows(candidate.UserID, candidate) {
+			return constraint.Name()
+		}
+	}
+	if in.Engine != nil {
+		context := append(append([]models.Shift(nil), userShifts...), candidate)
+		if rules.HasBlocking(rules.Involving(in.Engine.Evaluate(context), candidate.ID)) {
+			return ReasonWorkingTime
+		}
+	}
+	return ""
+}
+
+func less(hoursA, hoursB time.Duration, scoreA, scoreB, rankA, rankB int) bool {
+	if hoursA != hoursB {
+		return hoursA < hoursB
+	}
+	if scoreA != scoreB {
+		return scoreA > scoreB
+	}
+	return rankA < rankB
+}
@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/coverage"
+	"github.com/ptmmeiningen/schichtplaner/models"
+	"github.com/ptmmeiningen/schichtplaner/rules"
+)
+
+const department = 1
+
+// day returns 00:00 UTC of the n-th day of May 2024
+func day(n int) time.Time {
+	return time.Date(2024, 5, n, 0, 0, 0, 0, time.UTC)
+}
+
+// slot needs required people from 06:00 to 14:00 on the n-th day of May 2024
+func slot(n, required int) coverage.Slot {
+	return coverage.Slot{Start: day(n).Add(6 * time.Hour), End: day(n).Add(14 * time.Hour), Required: required}
+}
+
+func users(ids ...uint) []models.User {
+	var result []models.User
+	for _, id := range ids {
+		result = append(result, models.User{ID: id})
+	}
+	return result
+}
+
+// assignees returns the users of the proposed shifts in order
+func assignees(result Result) []uint {
+	var ids []uint
+	for _, shift := range result.Shifts {
+		ids = append(ids, shift.UserID)
+	}
+	return ids
+}
+
+type unavailable struct{ userID uint }
+
+func (u unavailable) Name() string { return "unavailable" }
+
+func (u unavailable) Allows(userID uint, shift models.Shift) bool { return userID != u.userID }
+
+type prefers struct{ userID uint }
+
+func (p prefers) Score(userID uint, shift models.Shift) int {
+	if userID == p.userID {
+		return 1
+	}
+	return 0
+}
+
+func TestGenerateBalancesHours(t *testing.T) {
+	in := Input{
+		DepartmentID: department,
+		Slots:        []coverage.Slot{slot(8, 1), slot(6, 1), slot(7, 1), slot(9, 2)},
+		Users:        users(1, 2, 3),
+		Seed:         42,
+	}
+	result := Generate(in)
+	if len(result.Shifts) != 5 || len(result.Unfilled) != 0 {
+		t.Fatalf("Generate() proposed %d shifts with %d unfilled slots, want 5 and none", len(result.Shifts), len(result.Unfilled))
+	}
+
+	hours := map[uint]float64{}
+	for _, h := range result.Hours {
+		hours[h.UserID] = h.ProposedHours
+	}
+	// 8 hours minus the required break of 30 minutes each, nobody gets a third shift before all have two
+	for _, id := range []uint{1, 2, 3} {
+		if hours[id] < 7.5 || hours[id] > 15 {
+			t.Errorf("user %d gets %g hours, want one or two shifts", id, hours[id])
+		}
+	}
+	for i, shift := range result.Shifts {
+		if shift.ID != 0 || shift.DepartmentID != department || shift.BreakMinutes != 30 {
+			t.Errorf("shift %d = %+v, want no ID, the department and a break of 30 minutes", i, shift)
+		}
+		if i > 0 && shift.StartTime.Before(result.Shifts[i-1].StartTime) {
+			t.Errorf("shift %d starts before the previous one", i)
+		}
+	}
+	if last := result.Shifts[3:]; last[0].UserID == last[1].UserID {
+		t.Errorf("both positions of one slot went to user %d", last[0].UserID)
+	}
+
+	if again := Generate(in); !reflect.DeepEqual(again, result) {
+		t.Errorf("Generate() with the same seed proposed %v, then %v", assignees(result), assignees(again))
+	}
+}
+
+func TestGenerateCountsExistingShifts(t *testing.T) {
+	existing := []models.Shift{
+		// covers the slot of the 6th
+		{ID: 1, UserID: 1, DepartmentID: department, StartTime: day(6).Add(6 * time.Hour), EndTime: day(6).Add(14 * time.Hour)},
+		// after the period, so it does not count for the hours of user 2
+		{ID: 2, UserID: 2, DepartmentID: 2, StartTime: day(9).Add(6 * time.Hour), EndTime: day(9).Add(14 * time.Hour)},
+		// an open shift does not staff the slot
+		{ID: 3, DepartmentID: department, StartTime: day(8).Add(6 * time.Hour), EndTime: day(8).Add(14 * time.Hour)},
+	}
+	result := Generate(Input{
+		DepartmentID: department,
+		Slots:        []coverage.Slot{slot(6, 1), slot(8, 1)},
+		Users:        users(1, 2),
+		Existing:     existing,
+	})
+	// user 1 already works 8 hours in the period, user 2 none
+	if want := []uint{2}; !reflect.DeepEqual(assignees(result), want) {
+		t.Errorf("Generate() assigned %v, want %v", assignees(result), want)
+	}
+	if result.Hours[0].PlannedHours != 8 {
+		t.Errorf("planned hours of user 1 = %g, want 8", result.Hours[0].PlannedHours)
+	}
+}
+
+func TestGenerateRejections(t *testing.T) {
+	qualification := uint(5)
+	qualified := slot(7, 1)
+	qualified.QualificationID = &qualification
+
+	tests := []struct {
+		name     string
+		in       Input
+		assigned []uint
+		reasons  map[string]int
+	}{
+		{"qualification", Input{Slots: []coverage.Slot{qualified}, Users: users(1, 2), Qualifications: map[uint]map[uint]bool{2: {qualification: true}}},
+			[]uint{2}, nil},
+		{"nobody qualified", Input{Slots: []coverage.Slot{qualified}, Users: users(1, 2)},
+			nil, map[string]int{ReasonQualification: 2}},
+		{"overlap", Input{Slots: []coverage.Slot{slot(7, 1)}, Users: users(1), Existing: []models.Shift{
+			{ID: 1, UserID: 1, DepartmentID: 2, StartTime: day(7).Add(12 * time.Hour), EndTime: day(7).Add(20 * time.Hour)},
+		}}, nil, map[string]int{ReasonOverlap: 1}},
+		{"rest period", Input{Slots: []coverage.Slot{slot(7, 1)}, Users: users(1), Engine: rules.NewEngine(rules.DefaultConfig()), Existing: []models.Shift{
+			{ID: 1, UserID: 1, DepartmentID: 2, StartTime: day(6).Add(14 * time.Hour), EndTime: day(6).Add(22 * time.Hour)},
+		}}, nil, map[string]int{ReasonWorkingTime: 1}},
+		{"constraint", Input{Slots: []coverage.Slot{slot(7, 2)}, Users: users(1, 2), Constraints: []Constraint{unavailable{1}}},
+			[]uint{2}, map[string]int{"unavailable": 1}},
+	}
+	for _, tt := range tests {
+		tt.in.DepartmentID = department
+		result := Generate(tt.in)
+		if got := assignees(result); !reflect.DeepEqual(got, tt.assigned) {
+			t.Errorf("%s: Generate() assigned %v, want %v", tt.name, got, tt.assigned)
+		}
+		if tt.reasons == nil {
+			if len(result.Unfilled) != 0 {
+				t.Errorf("%s: unfilled slots %+v, want none", tt.name, result.Unfilled)
+			}
+			continue
+		}
+		if len(result.Unfilled) != 1 || result.Unfilled[0].Missing != 1 || !reflect.DeepEqual(result.Unfilled[0].Reasons, tt.reasons) {
+			t.Errorf("%s: unfilled slots %+v, want one missing position with reasons %v", tt.name, result.Unfilled, tt.reasons)
+		}
+	}
+}
+
+func TestGeneratePreferences(t *testing.T) {
+	for _, preferred := range []uint{1, 2, 3} {
+		result := Generate(Input{
+			DepartmentID: department,
+			Slots:        []coverage.Slot{slot(7, 1)},
+			Users:        users(1, 2, 3),
+			Preferences:  []Preference{prefers{preferred}},
+		})
+		if got := assignees(result); !reflect.DeepEqual(got, []uint{preferred}) {
+			t.Errorf("Generate() assigned %v, want the preferring user %d", got, preferred)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// bom is the UTF-8 byte order mark Excel needs to recognize the encoding
+var bom = []byte("\xef\xbb\xbf")
+
+// ReadCSV parses CSV data separated by semicolons, commas or tabs, whichever
+// the first line uses most. Files that are no valid UTF-8 are read as
+// Windows-1252, the encoding of CSV files saved by Excel on German systems.
+func ReadCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, bom)
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	separator := ';'
+	for _, candidate := range []rune{',', '\t'} {
+		if bytes.Count(firstLine, []byte(string(candidate))) > bytes.Count(firstLine, []byte(string(separator))) {
+			separator = candidate
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// blank lines are skipped by the reader but count for the row numbers
+		line, _ := reader.FieldPos(0)
+		for len(rows) < line-1 {
+			rows = append(rows, nil)
+		}
+		rows = append(rows, record)
+	}
+}
+
+// WriteCSV encodes the rows separated by semicolons with byte order mark and
+// CRLF line endings
+func WriteCSV(rows [][]string) ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(bom)
+	writer := csv.NewWriter(&b)
+	writer.Comma = ';'
+	writer.UseCRLF = true
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
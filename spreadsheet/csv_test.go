@@ -0,0 +1,51 @@
+package spreadsheet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"semicolons", "a;b\n1;2\n", [][]string{{"a", "b"}, {"1", "2"}}},
+		{"commas", "a,b,c;d\n1,2,3;4\n", [][]string{{"a", "b", "c;d"}, {"1", "2", "3;4"}}},
+		{"semicolons on a tie", "a,b;c\n1,2;3\n", [][]string{{"a,b", "c"}, {"1,2", "3"}}},
+		{"tabs", "a\tb\n1\t2\n", [][]string{{"a", "b"}, {"1", "2"}}},
+		{"byte order mark and CRLF", "\xef\xbb\xbfa;b\r\n1;2\r\n", [][]string{{"a", "b"}, {"1", "2"}}},
+		{"quoted separators and line breaks", "a;b\n\"x;y\";\"1\n2\"\n", [][]string{{"a", "b"}, {"x;y", "1\n2"}}},
+		{"rows of different length", "a;b;c\n1\n", [][]string{{"a", "b", "c"}, {"1"}}},
+		// blank lines keep the row numbers of the following rows
+		{"blank lines", "a\n\n\nb\n", [][]string{{"a"}, nil, nil, {"b"}}},
+		{"Windows-1252", "Stra\xdfe;M\xfcller\n", [][]string{{"Straße", "Müller"}}},
+	}
+	for _, tt := range tests {
+		got, err := ReadCSV([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: ReadCSV() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadCSV() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	data, err := WriteCSV([][]string{{"Name", "Notiz"}, {"Müller", "a;b \"c\""}})
+	if err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "\xef\xbb\xbfName;Notiz\r\nMüller;\"a;b \"\"c\"\"\"\r\n"
+	if string(data) != want {
+		t.Errorf("WriteCSV() = %q, want %q", data, want)
+	}
+
+	rows, err := ReadCSV(data)
+	if err != nil || !reflect.DeepEqual(rows, [][]string{{"Name", "Notiz"}, {"Müller", "a;b \"c\""}}) {
+		t.Errorf("ReadCSV(WriteCSV()) = %q, %v", rows, err)
+	}
+}
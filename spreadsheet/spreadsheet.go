@@ -0,0 +1,164 @@
+// Package spreadsheet reads and writes tables as CSV and Excel (XLSX) files
+// with the conventions of German spreadsheet programs: semicolon separated,
+// UTF-8 with byte order mark, dates as dd.mm.yyyy and decimal commas.
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of exported files
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// DateFormat is the layout of dates in files
+const DateFormat = "02.01.2006"
+
+// Table is a header row followed by data rows
+type Table struct {
+	Header    []string
+	Rows      [][]string
+	HeaderRow int // line of the header in the file, counted from 1
+}
+
+// Read parses a CSV or XLSX file, recognized by its content, into a table.
+// The first non-empty row is the header, trailing empty rows are dropped.
+func Read(data []byte) (Table, error) {
+	var rows [][]string
+	var err error
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		rows, err = ReadXLSX(data)
+	} else {
+		rows, err = ReadCSV(data)
+	}
+	if err != nil {
+		return Table{}, err
+	}
+
+	headerRow := 1
+	for len(rows) > 0 && IsEmpty(rows[0]) {
+		rows = rows[1:]
+		headerRow++
+	}
+	for len(rows) > 0 && IsEmpty(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	if len(rows) == 0 {
+		return Table{}, errors.New("The file contains no rows")
+	}
+	header := make([]string, len(rows[0]))
+	for i, name := range rows[0] {
+		header[i] = strings.TrimSpace(name)
+	}
+	return Table{Header: header, Rows: rows[1:], HeaderRow: headerRow}, nil
+}
+
+// Write encodes the table in the format, CSV unless XLSX is requested
+func Write(format, sheet string, table Table) ([]byte, error) {
+	rows := append([][]string{table.Header}, table.Rows...)
+	if format == XLSX {
+		return WriteXLSX(sheet, rows)
+	}
+	return WriteCSV(rows)
+}
+
+// ContentType returns the MIME type and file extension of the format
+func ContentType(format string) (string, string) {
+	if format == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx"
+	}
+	return "text/csv; charset=utf-8", ".csv"
+}
+
+// Column returns the index of the first header matching one of the names,
+// ignoring case, or -1
+func (t Table) Column(names ...string) int {
+	for _, name := range names {
+		for i, header := range t.Header {
+			if name != "" && strings.EqualFold(header, name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Cell returns the trimmed value of the row in the column, empty for missing cells
+func Cell(row []string, column int) string {
+	if column < 0 || column >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[column])
+}
+
+// ParseDate reads dd.mm.yyyy, d.m.yy, yyyy-mm-dd and Excel date numbers
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range []string{DateFormat, "2.1.2006", "2.1.06", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	// Excel counts days since 30.12.1899
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial >= 1 && serial < 2958466 {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(math.Floor(serial))), nil
+	}
+	return time.Time{}, errors.New("Invalid date " + value + ", expected dd.mm.yyyy")
+}
+
+// FormatDate formats the day of t as dd.mm.yyyy
+func FormatDate(t time.Time) string {
+	return t.Format(DateFormat)
+}
+
+// ParseNumber reads numbers with decimal comma or point
+func ParseNumber(value string) (float64, error) {
+	if strings.Contains(value, ",") {
+		value = strings.ReplaceAll(strings.ReplaceAll(value, ".", ""), ",", ".")
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.New("Invalid number " + value)
+	}
+	return n, nil
+}
+
+// FormatNumber formats n with decimal comma and at most two decimals
+func FormatNumber(n float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(math.Round(n*100)/100, 'f', -1, 64), ".", ",")
+}
+
+// ParseBool reads ja/nein, yes/no, true/false, x and 1/0
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "ja", "j", "yes", "y", "true", "wahr", "x", "1":
+		return true, nil
+	case "nein", "n", "no", "false", "falsch", "0", "":
+		return false, nil
+	}
+	return false, errors.New("Invalid yes/no value " + value)
+}
+
+// FormatBool formats b as ja or nein
+func FormatBool(b bool) string {
+	if b {
+		return "ja"
+	}
+	return "nein"
+}
+
+// IsEmpty reports whether all cells of the row are blank
+func IsEmpty(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
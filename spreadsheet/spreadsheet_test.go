@@ -0,0 +1,150 @@
+package spreadsheet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	table, err := Read([]byte("\n;;\n Vorname ;Nachname\nMax;Mustermann\n\nErika;Musterfrau\n;\n\n"))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := Table{
+		Header:    []string{"Vorname", "Nachname"},
+		Rows:      [][]string{{"Max", "Mustermann"}, nil, {"Erika", "Musterfrau"}},
+		HeaderRow: 3,
+	}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("Read() = %#v, want %#v", table, want)
+	}
+
+	if _, err := Read([]byte("\n;\n")); err == nil {
+		t.Error("Read() accepted a file without rows")
+	}
+}
+
+func TestReadWriteXLSX(t *testing.T) {
+	data, err := Write(XLSX, "Benutzer", Table{Header: []string{"Name", "E-Mail"}, Rows: [][]string{{"Max", "max@example.com"}}})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	table, err := Read(data)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := Table{Header: []string{"Name", "E-Mail"}, Rows: [][]string{{"Max", "max@example.com"}}, HeaderRow: 1}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("Read(Write()) = %#v, want %#v", table, want)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	table := Table{Header: []string{"Vorname", "E-Mail", ""}}
+	tests := []struct {
+		names []string
+		want  int
+	}{
+		{[]string{"vorname"}, 0},
+		{[]string{"Email", "e-mail"}, 1},
+		{[]string{"Nachname"}, -1},
+		{[]string{""}, -1},
+	}
+	for _, tt := range tests {
+		if got := table.Column(tt.names...); got != tt.want {
+			t.Errorf("Column(%q) = %d, want %d", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestCell(t *testing.T) {
+	row := []string{" a ", "b"}
+	for column, want := range map[int]string{-1: "", 0: "a", 1: "b", 2: ""} {
+		if got := Cell(row, column); got != want {
+			t.Errorf("Cell(%d) = %q, want %q", column, got, want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		valid bool
+	}{
+		{"01.05.2024", "2024-05-01", true},
+		{"1.5.2024", "2024-05-01", true},
+		{"1.5.24", "2024-05-01", true},
+		{"2024-05-01", "2024-05-01", true},
+		{"45413", "2024-05-01", true},
+		{"45413.75", "2024-05-01", true},
+		{"31.02.2024", "", false},
+		{"0", "", false},
+		{"morgen", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.value)
+		if (err == nil) != tt.valid || (tt.valid && got.Format("2006-01-02") != tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v, want %s", tt.value, got, err, tt.want)
+		}
+	}
+	if got := FormatDate(time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)); got != "01.05.2024" {
+		t.Errorf("FormatDate() = %q, want 01.05.2024", got)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		valid bool
+	}{
+		{"38,5", 38.5, true},
+		{"38.5", 38.5, true},
+		{"1.234,5", 1234.5, true},
+		{"-2", -2, true},
+		{"", 0, false},
+		{"zehn", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseNumber(tt.value)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("ParseNumber(%q) = %g, %v, want %g", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := map[float64]string{
+		40:      "40",
+		38.5:    "38,5",
+		12.345:  "12,35",
+		-0.125:  "-0,13",
+		1234.56: "1234,56",
+	}
+	for n, want := range tests {
+		if got := FormatNumber(n); got != want {
+			t.Errorf("FormatNumber(%g) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	for _, value := range []string{"ja", "Yes", "TRUE", "x", "1", "wahr"} {
+		if got, err := ParseBool(value); err != nil || !got {
+			t.Errorf("ParseBool(%q) = %v, %v, want true", value, got, err)
+		}
+	}
+	for _, value := range []string{"nein", "No", "false", "0", ""} {
+		if got, err := ParseBool(value); err != nil || got {
+			t.Errorf("ParseBool(%q) = %v, %v, want false", value, got, err)
+		}
+	}
+	if _, err := ParseBool("vielleicht"); err == nil {
+		t.Error("ParseBool() accepted vielleicht")
+	}
+	if FormatBool(true) != "ja" || FormatBool(false) != "nein" {
+		t.Error("FormatBool() does not write ja and nein")
+	}
+}
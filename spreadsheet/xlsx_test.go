@@ -0,0 +1,159 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xlsx packs a worksheet and its shared strings into a minimal Excel file
+func xlsx(t *testing.T, sheetData, sharedStrings string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	archive := zip.NewWriter(&b)
+	parts := map[string]string{
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	if sharedStrings != "" {
+		parts["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sharedStrings + `</sst>`
+	}
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	tests := []struct {
+		name      string
+		sheetData string
+		want      [][]string
+	}{
+		{"shared, rich and inline strings and numbers",
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>inline</t></is></c><c r="D1"><v>45413</v></c></row>`,
+			[][]string{{"Name", "rich text", "inline", "45413"}}},
+		{"missing cells and rows",
+			`<row r="2"><c r="C2"><v>1</v></c></row><row r="4"><c r="B4"><v>2</v></c></row>`,
+			[][]string{nil, {"", "", "1"}, nil, {"", "2"}}},
+		{"cells and rows out of order",
+			`<row r="2"><c r="C2"><v>3</v></c><c r="A2"><v>1</v></c></row><row r="1"><c r="A1"><v>h</v></c></row>`,
+			[][]string{{"h"}, {"1", "", "3"}}},
+		{"rows without number", `<row><c><v>1</v></c><c><v>2</v></c></row><row><c><v>3</v></c></row>`,
+			[][]string{{"1", "2"}, {"3"}}},
+	}
+	shared := `<si><t>Name</t></si><si><r><t>rich </t></r><r><t>text</t></r></si>`
+	for _, tt := range tests {
+		got, err := ReadXLSX(xlsx(t, tt.sheetData, shared))
+		if err != nil {
+			t.Errorf("%s: ReadXLSX() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadXLSX() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadXLSXInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no archive", []byte("PK\x03\x04 broken")},
+		{"without worksheet", func() []byte {
+			var b bytes.Buffer
+			zip.NewWriter(&b).Close()
+			return b.Bytes()
+		}()},
+		{"unknown shared string", xlsx(t, `<row r="1"><c r="A1" t="s"><v>5</v></c></row>`, `<si><t>a</t></si>`)},
+		{"row beyond the limit", xlsx(t, `<row r="1048577"><c r="A1048577"><v>1</v></c></row>`, "")},
+		{"column beyond the limit", xlsx(t, `<row r="1"><c r="XFE1"><v>1</v></c></row>`, "")},
+		{"overlong column", xlsx(t, `<row r="1"><c r="`+strings.Repeat("Z", 20)+`1"><v>1</v></c></row>`, "")},
+	}
+	for _, tt := range tests {
+		if _, err := ReadXLSX(tt.data); err == nil {
+			t.Errorf("%s: ReadXLSX() accepted the file", tt.name)
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	rows := [][]string{{"Name", "Notiz"}, {"Müller", `<b>&"fett"</b>`}, {}, {"", "  leer davor"}}
+	data, err := WriteXLSX("Mitarbeiter: [alle] / aktiv und sehr lange benannt", rows)
+	if err != nil {
+		t.Fatalf("WriteXLSX() error = %v", err)
+	}
+	got, err := ReadXLSX(data)
+	if err != nil {
+		t.Fatalf("ReadXLSX() error = %v", err)
+	}
+	want := [][]string{{"Name", "Notiz"}, {"Müller", `<b>&"fett"</b>`}, nil, {"", "  leer davor"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadXLSX(WriteXLSX()) = %q, want %q", got, want)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archive.File {
+		if f.Name != "xl/workbook.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var workbook bytes.Buffer
+		workbook.ReadFrom(r)
+		r.Close()
+		// sheet names are at most 31 characters without []:*?/\
+		if want := `name="Mitarbeiter_ _alle_ _ aktiv und"`; !strings.Contains(workbook.String(), want) {
+			t.Errorf("workbook %s lacks %s", workbook.String(), want)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := map[string]int{
+		"A1":   0,
+		"Z9":   25,
+		"AA10": 26,
+		"AB12": 27,
+		"XFD1": maxColumns - 1,
+		"1":    -1,
+		"":     -1,
+		"a1":   -1,
+	}
+	for ref, want := range tests {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("columnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+	// overlong references stop counting beyond the limit instead of overflowing
+	if got := columnIndex(strings.Repeat("Z", 30) + "1"); got < maxColumns {
+		t.Errorf("columnIndex() of an overlong reference = %d, want at least %d", got, maxColumns)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for column, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA", maxColumns - 1: "XFD"} {
+		if got := columnName(column); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", column, got, want)
+		}
+		if got := columnIndex(want + "1"); got != column {
+			t.Errorf("columnIndex(%q) = %d, want %d", want+"1", got, column)
+		}
+	}
+}
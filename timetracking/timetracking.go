@@ -0,0 +1,188 @@
+package timetracking
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// Deviation types
+const (
+	DeviationLateArrival = "late_arrival"
+	DeviationEarlyLeave  = "early_leave"
+	DeviationOvertime    = "overtime"
+	DeviationUnplanned   = "unplanned_work"
+	DeviationNoShow      = "no_show"
+)
+
+// Deviation is a difference between a planned shift and the recorded times
+type Deviation struct {
+	Type        string     `json:"type" example:"late_arrival"`
+	UserID      uint       `json:"user_id"`
+	ShiftID     *uint      `json:"shift_id,omitempty"`
+	TimeEntryID *uint      `json:"time_entry_id,omitempty"`
+	Planned     *time.Time `json:"planned,omitempty"`
+	Actual      *time.Time `json:"actual,omitempty"`
+	Minutes     int        `json:"minutes"`
+}
+
+// WorkedTime returns the time between clock in and clock out minus the breaks,
+// open entries are counted up to now
+func WorkedTime(entry models.TimeEntry, now time.Time) time.Duration {
+	end := now
+	if entry.ClockOut != nil {
+		end = *entry.ClockOut
+	}
+	worked := end.Sub(entry.ClockIn) - time.Duration(entry.BreakMinutes)*time.Minute
+	if worked < 0 {
+		return 0
+	}
+	return worked
+}
+
+// BreakMinutes sums the finished breaks
+func BreakMinutes(breaks []models.TimeBreak) uint {
+	var total time.Duration
+	for _, b := range breaks {
+		if b.End != nil {
+			total += b.End.Sub(b.Start)
+		}
+	}
+	return uint(total.Round(time.Minute) / time.Minute)
+}
+
+// Deviations compares the planned shifts with the time entries of the same
+// users. Entries are matched to shifts by their ShiftID; a shift may be worked
+// in several entries, then the first clock in and the last clock out count.
+// Differences up to tolerance are ignored, shifts that have not ended before
+// now are not reported as missed and open entries not as early leave.
+func Deviations(shifts []models.Shift, entries []models.TimeEntry, tolerance time.Duration, now time.Time) []Deviation {
+	byShift := map[uint][]models.TimeEntry{}
+	var deviations []Deviation
+	for _, entry := range entries {
+		if entry.ShiftID == nil {
+			entryID := entry.ID
+			clockIn := entry.ClockIn
+			deviations = append(deviations, Deviation{
+				Type:        DeviationUnplanned,
+				UserID:      entry.UserID,
+				TimeEntryID: &entryID,
+				Actual:      &clockIn,
+				Minutes:     minutes(WorkedTime(entry, now)),
+			})
+			continue
+		}
+		byShift[*entry.ShiftID] = append(byShift[*entry.ShiftID], entry)
+	}
+
+	for _, shift := range shifts {
+		if shift.UserID == 0 {
+			continue
+		}
+		shiftID := shift.ID
+		start, end := shift.StartTime, shift.EndTime
+		worked := byShift[shift.ID]
+		if len(worked) == 0 {
+			if end.Before(now) {
+				deviations = append(deviations, Deviation{
+					Type:    DeviationNoShow,
+					UserID:  shift.UserID,
+					ShiftID: &shiftID,
+					Planned: &start,
+					Minutes: minutes(end.Sub(start)),
+				})
+			}
+			continue
+		}
+
+		first, last := worked[0], worked[0]
+		open := false
+		for _, entry := range worked {
+			if entry.ClockIn.Before(first.ClockIn) {
+				first = entry
+			}
+			if entry.ClockOut == nil {
+				open = true
+			} else if last.ClockOut == nil || entry.ClockOut.After(*last.ClockOut) {
+				last = entry
+			}
+		}
+
+		firstID := first.ID
+		clockIn := first.ClockIn
+		if late := clockIn.Sub(start); late > tolerance {
+			deviations = append(deviations, Deviation{
+				Type:        DeviationLateArrival,
+				UserID:      shift.UserID,
+				ShiftID:     &shiftID,
+				TimeEntryID: &firstID,
+				Planned:     &start,
+				Actual:      &clockIn,
+				Minutes:     minutes(late),
+			})
+		}
+		if open || last.ClockOut == nil {
+			continue
+		}
+		lastID := last.ID
+		clockOut := *last.ClockOut
+		if early := end.Sub(clockOut); early > tolerance {
+			deviations = append(deviations, Deviation{
+				Type:        DeviationEarlyLeave,
+				UserID:      shift.UserID,
+				ShiftID:     &shiftID,
+				TimeEntryID: &lastID,
+				Planned:     &end,
+				Actual:      &clockOut,
+				Minutes:     minutes(early),
+			})
+		}
+		if over := clockOut.Sub(end); over > tolerance {
+			deviations = append(deviations, Deviation{
+				Type:        DeviationOvertime,
+				UserID:      shift.UserID,
+				ShiftID:     &shiftID,
+				TimeEntryID: &lastID,
+				Planned:     &end,
+				Actual:      &clockOut,
+				Minutes:     minutes(over),
+			})
+		}
+	}
+
+	sort.SliceStable(deviations, func(i, j int) bool {
+		return deviationTime(deviations[i]).Before(deviationTime(deviations[j]))
+	})
+	return deviations
+}
+
+// MatchShift returns the shift an entry clocked in at t belongs to: the one
+// running at t or starting within window after t, the earliest if several do
+func MatchShift(shifts []models.Shift, t time.Time, window time.Duration) *models.Shift {
+	var match *models.Shift
+	for i := range shifts {
+		shift := &shifts[i]
+		if !shift.StartTime.Before(t.Add(window)) || !shift.EndTime.After(t) {
+			continue
+		}
+		if match == nil || shift.StartTime.Before(match.StartTime) {
+			match = shift
+		}
+	}
+	return match
+}
+
+func deviationTime(d Deviation) time.Time {
+	if d.Planned != nil {
+		return *d.Planned
+	}
+	if d.Actual != nil {
+		return *d.Actual
+	}
+	return time.Time{}
+}
+
+func minutes(d time.Duration) int {
+	return int(d.Round(time.Minute) / time.Minute)
+}
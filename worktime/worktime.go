@@ -0,0 +1,85 @@
+package worktime
+
+import (
+	"math"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/absence"
+	"github.com/ptmmeiningen/schichtplaner/contract"
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+// WorkingDaysPerWeek is the number of days the weekly hours of users without contract are spread over
+const WorkingDaysPerWeek = 5
+
+// Month is the account of a user for a calendar month or the part of it up to now
+type Month struct {
+	Month           string                      `json:"month" example:"2024-05"`
+	Closed          bool                        `json:"closed"`
+	OpeningBalance  float64                     `json:"opening_balance"`
+	TargetHours     float64                     `json:"target_hours"`
+	PlannedHours    float64                     `json:"planned_hours"`
+	ActualHours     float64                     `json:"actual_hours"`
+	AdjustmentHours float64                     `json:"adjustment_hours"`
+	Balance         float64                     `json:"balance"`    // opening balance plus actual and adjusted minus target hours
+	CarryOver       float64                     `json:"carry_over"` // balance taken over into the next month
+	Adjustments     []models.WorkTimeAdjustment `json:"adjustments"`
+}
+
+// TargetHours returns the hours to be worked in [from, to). Each day counts
+// with the daily hours of the contract valid on it; days without contract
+// count weeklyHours spread over Monday to Friday. Public holidays and approved
+// absences are credited and reduce the target.
+func TargetHours(contracts []models.Contract, weeklyHours float64, from, to time.Time, loc *time.Location, isHoliday func(time.Time) bool, absences []models.Absence) float64 {
+	var target float64
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if isHoliday != nil && isHoliday(day) {
+			continue
+		}
+		hours := weeklyHours / WorkingDaysPerWeek
+		if c := contract.ValidAt(contracts, day, loc); c != nil {
+			hours = contract.DailyHours(*c, day.Weekday())
+		} else if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		target += hours * (1 - absentShare(absences, day, loc))
+	}
+	return target
+}
+
+// absentShare returns 1 if the user is absent the whole day, 0.5 for half days and 0 otherwise
+func absentShare(absences []models.Absence, day time.Time, loc *time.Location) float64 {
+	share := 0.0
+	for _, a := range absences {
+		if a.Status != models.AbsenceApproved {
+			continue
+		}
+		start, end, err := absence.Span(a, loc)
+		if err != nil || day.Before(start) || !day.Before(end) {
+			continue
+		}
+		if !a.HalfDay {
+			return 1
+		}
+		share = 0.5
+	}
+	return share
+}
+
+// Close computes the balance of the month and what is carried over. Positive
+// balances above maxCarryOver are forfeited, 0 carries over everything.
+func (m *Month) Close(maxCarryOver float64) {
+	m.TargetHours = Round(m.TargetHours)
+	m.PlannedHours = Round(m.PlannedHours)
+	m.ActualHours = Round(m.ActualHours)
+	m.Balance = Round(m.OpeningBalance + m.ActualHours + m.AdjustmentHours - m.TargetHours)
+	m.CarryOver = m.Balance
+	if maxCarryOver > 0 && m.CarryOver > maxCarryOver {
+		m.CarryOver = maxCarryOver
+	}
+}
+
+// Round rounds to hundredths of an hour
+func Round(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
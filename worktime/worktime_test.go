@@ -0,0 +1,85 @@
+package worktime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ptmmeiningen/schichtplaner/models"
+)
+
+func TestTargetHours(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the week from Monday 2024-05-06 to Sunday 2024-05-12
+	from := time.Date(2024, 5, 6, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 7)
+	holiday := func(day time.Time) bool { return day.Format("2006-01-02") == "2024-05-08" }
+	contract := models.Contract{WeeklyHours: 30, WorkingDays: []string{"MO", "WE", "FR"}, ValidFrom: "2024-01-01"}
+	weekend := models.Contract{WeeklyHours: 20, WorkingDays: []string{"SA", "SU"}, ValidFrom: "2024-01-01"}
+	later := models.Contract{WeeklyHours: 30, WorkingDays: []string{"MO", "WE", "FR"}, ValidFrom: "2024-05-08"}
+	absent := func(start, end string, halfDay bool, status string) []models.Absence {
+		return []models.Absence{{StartDate: start, EndDate: end, HalfDay: halfDay, Status: status}}
+	}
+
+	tests := []struct {
+		name      string
+		contracts []models.Contract
+		isHoliday func(time.Time) bool
+		absences  []models.Absence
+		want      float64
+	}{
+		{"without contract", nil, nil, nil, 40},
+		{"holiday", nil, holiday, nil, 32},
+		{"contract working days", []models.Contract{contract}, nil, nil, 30},
+		{"holiday on a contract working day", []models.Contract{contract}, holiday, nil, 20},
+		{"contract on weekends", []models.Contract{weekend}, nil, nil, 20},
+		{"contract beginning within the week", []models.Contract{later}, nil, nil, 16 + 20},
+		{"absent for a day", nil, nil, absent("2024-05-07", "2024-05-07", false, models.AbsenceApproved), 32},
+		{"absent for half a day", nil, nil, absent("2024-05-07", "2024-05-07", true, models.AbsenceApproved), 36},
+		{"absence not approved", nil, nil, absent("2024-05-07", "2024-05-07", false, models.AbsenceRequested), 40},
+		{"absent for the week", nil, nil, absent("2024-05-01", "2024-05-31", false, models.AbsenceApproved), 0},
+	}
+	for _, tt := range tests {
+		if got := TargetHours(tt.contracts, 40, from, to, loc, tt.isHoliday, tt.absences); got != tt.want {
+			t.Errorf("%s: TargetHours() = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	tests := []struct {
+		name         string
+		month        Month
+		maxCarryOver float64
+		balance      float64
+		carryOver    float64
+	}{
+		{"everything carried over", Month{OpeningBalance: 2, TargetHours: 160, ActualHours: 170.004, AdjustmentHours: -1}, 0, 11, 11},
+		{"capped", Month{OpeningBalance: 2, TargetHours: 160, ActualHours: 170.004, AdjustmentHours: -1}, 8, 11, 8},
+		{"negative balance is kept", Month{TargetHours: 160, ActualHours: 150}, 8, -10, -10},
+	}
+	for _, tt := range tests {
+		m := tt.month
+		m.Close(tt.maxCarryOver)
+		if m.Balance != tt.balance || m.CarryOver != tt.carryOver {
+			t.Errorf("%s: balance %g and carry over %g, want %g and %g", tt.name, m.Balance, m.CarryOver, tt.balance, tt.carryOver)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		hours, want float64
+	}{
+		{1.234, 1.23},
+		{1.235, 1.24},
+		{-0.004, 0},
+	}
+	for _, tt := range tests {
+		if got := Round(tt.hours); got != tt.want {
+			t.Errorf("Round(%g) = %g, want %g", tt.hours, got, tt.want)
+		}
+	}
+}